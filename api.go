@@ -24,6 +24,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/url"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -48,6 +49,16 @@ type API struct {
 	importWorkerPoolSize int
 	importWork           chan importJob
 
+	// Set while the node is being drained before removal.
+	drainMu  sync.RWMutex
+	draining bool
+
+	// Limits the rate of accepted queries while the node warms up.
+	warmup *warmupGate
+
+	// Closed when the API is closed.
+	closing chan struct{}
+
 	Serializer Serializer
 }
 
@@ -60,13 +71,25 @@ func OptAPIServer(s *Server) apiOption {
 		a.holder = s.holder
 		a.cluster = s.cluster
 		a.Serializer = s.serializer
+		a.importWorkerPoolSize = s.importPoolSize
 		return nil
 	}
 }
 
 func OptAPIImportWorkerPoolSize(size int) apiOption {
 	return func(a *API) error {
-		a.importWorkerPoolSize = size
+		a.importWorkerPoolSize = resolvePoolSize(size)
+		return nil
+	}
+}
+
+// OptAPIWarmupPeriod is a functional option on API used to set the period
+// over which the rate of accepted queries ramps up once the node is ready.
+func OptAPIWarmupPeriod(d time.Duration) apiOption {
+	return func(a *API) error {
+		if d > 0 {
+			a.warmup = newWarmupGate(d)
+		}
 		return nil
 	}
 }
@@ -75,6 +98,7 @@ func OptAPIImportWorkerPoolSize(size int) apiOption {
 func NewAPI(opts ...apiOption) (*API, error) {
 	api := &API{
 		importWorkerPoolSize: 2,
+		closing:              make(chan struct{}),
 	}
 
 	for _, opt := range opts {
@@ -93,6 +117,12 @@ func NewAPI(opts ...apiOption) (*API, error) {
 		}()
 	}
 
+	// The warmup period starts when the node becomes ready, rather than when
+	// it first sees a query.
+	if api.warmup != nil && api.holder != nil {
+		go api.warmup.beginOnOpen(&api.holder.opened, api.closing)
+	}
+
 	return api, nil
 }
 
@@ -126,6 +156,7 @@ func (api *API) validate(f apiMethod) error {
 
 // Close closes the api and waits for it to shutdown.
 func (api *API) Close() error {
+	close(api.closing)
 	close(api.importWork)
 	api.importWorkersWG.Wait()
 	return nil
@@ -140,15 +171,34 @@ func (api *API) Query(ctx context.Context, req *QueryRequest) (QueryResponse, er
 		return QueryResponse{}, errors.Wrap(err, "validating api method")
 	}
 
+	if !api.Ready() {
+		return QueryResponse{}, ErrNodeStarting
+	}
+	if !req.Remote && api.Draining() {
+		return QueryResponse{}, ErrDraining
+	}
+	if !req.Remote && api.warmup != nil && !api.warmup.allow() {
+		return QueryResponse{}, ErrWarmingUp
+	}
+
 	q, err := pql.NewParser(strings.NewReader(req.Query)).Parse()
 	if err != nil {
 		return QueryResponse{}, errors.Wrap(err, "parsing")
 	}
+	if req.Bitmap != nil {
+		bm := roaring.NewBitmap()
+		if err := bm.UnmarshalBinary(req.Bitmap); err != nil {
+			return QueryResponse{}, errors.Wrap(err, "decoding uploaded bitmap")
+		}
+		setUploadedColumns(q.Calls, bm.Slice())
+	}
 	execOpts := &execOptions{
 		Remote:          req.Remote,
 		ExcludeRowAttrs: req.ExcludeRowAttrs, // NOTE: Kept for Pilosa 1.x compat.
 		ExcludeColumns:  req.ExcludeColumns,  // NOTE: Kept for Pilosa 1.x compat.
 		ColumnAttrs:     req.ColumnAttrs,     // NOTE: Kept for Pilosa 1.x compat.
+		NoTimeout:       req.NoTimeout,
+		IncludeTotal:    req.IncludeTotal,
 	}
 	resp, err := api.server.executor.Execute(ctx, req.Index, q, req.Shards, execOpts)
 	if err != nil {
@@ -158,6 +208,70 @@ func (api *API) Query(ctx context.Context, req *QueryRequest) (QueryResponse, er
 	return resp, nil
 }
 
+// ExplainQuery parses the query in req and returns how it would be executed:
+// the tree of calls with the fields each one touches, the shards the query
+// would run against and the node which would execute each shard. The query
+// is not executed.
+func (api *API) ExplainQuery(ctx context.Context, req *QueryRequest) (*QueryPlan, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ExplainQuery")
+	defer span.Finish()
+
+	if err := api.validate(apiQuery); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+	if !api.Ready() {
+		return nil, ErrNodeStarting
+	}
+
+	q, err := pql.NewParser(strings.NewReader(req.Query)).Parse()
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing")
+	}
+	idx := api.holder.Index(req.Index)
+	if idx == nil {
+		return nil, newNotFoundError(ErrIndexNotFound, req.Index)
+	}
+
+	plan := &QueryPlan{
+		Calls:  make([]*CallPlan, len(q.Calls)),
+		Shards: []uint64{},
+		Nodes:  make(map[string][]uint64),
+	}
+	for i, c := range q.Calls {
+		plan.Calls[i] = newCallPlan(idx, c)
+	}
+
+	// Shards are chosen the same way as by the executor.
+	if needsShards(q.Calls) {
+		plan.Shards = req.Shards
+		if len(plan.Shards) == 0 {
+			plan.Shards = idx.AvailableShards().Slice()
+		}
+		if len(plan.Shards) == 0 {
+			plan.Shards = []uint64{0}
+		}
+	}
+	for _, shard := range plan.Shards {
+		if nodes := api.cluster.shardNodes(req.Index, shard); len(nodes) > 0 {
+			plan.Nodes[nodes[0].ID] = append(plan.Nodes[nodes[0].ID], shard)
+		}
+	}
+	return plan, nil
+}
+
+// setUploadedColumns attaches columns to every Uploaded() call in calls.
+func setUploadedColumns(calls []*pql.Call, columns []uint64) {
+	for _, c := range calls {
+		if c.Name == "Uploaded" {
+			if c.Args == nil {
+				c.Args = make(map[string]interface{})
+			}
+			c.Args["columns"] = columns
+		}
+		setUploadedColumns(c.Children, columns)
+	}
+}
+
 // CreateIndex makes a new Pilosa index.
 func (api *API) CreateIndex(ctx context.Context, indexName string, options IndexOptions) (*Index, error) {
 	span, _ := tracing.StartSpanFromContext(ctx, "API.CreateIndex")
@@ -373,6 +487,12 @@ func (api *API) ImportRoaring(ctx context.Context, indexName, fieldName string,
 	if err = api.validate(apiField); err != nil {
 		return errors.Wrap(err, "validating api method")
 	}
+	if !api.Ready() {
+		return ErrNodeStarting
+	}
+	if !remote && api.Draining() {
+		return ErrDraining
+	}
 
 	nodes := api.cluster.shardNodes(indexName, shard)
 
@@ -691,6 +811,213 @@ func (api *API) RecalculateCaches(ctx context.Context) error {
 	return nil
 }
 
+// SetDraining marks the node as draining, or clears the mark. While draining,
+// the node rejects new queries and imports from clients with ErrDraining, but
+// keeps serving requests from other nodes so that in-flight queries complete
+// and its shards can be replicated elsewhere.
+func (api *API) SetDraining(ctx context.Context, draining bool) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.SetDraining")
+	defer span.Finish()
+
+	if err := api.validate(apiDrain); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	api.drainMu.Lock()
+	api.draining = draining
+	api.drainMu.Unlock()
+	return nil
+}
+
+// Ready returns true once the node has loaded its data and can serve queries
+// and imports.
+func (api *API) Ready() bool {
+	return api.holder.opened.isClosed()
+}
+
+// warmupGate accepts a growing share of requests over a warmup period,
+// starting when the node becomes ready. Rejecting the rest keeps a node with
+// cold caches from being overwhelmed as soon as it becomes ready.
+type warmupGate struct {
+	mu     sync.Mutex
+	period time.Duration
+	start  time.Time
+	credit float64
+
+	now func() time.Time
+}
+
+func newWarmupGate(period time.Duration) *warmupGate {
+	return &warmupGate{period: period, now: time.Now}
+}
+
+// begin starts the warmup period, unless it has already started.
+func (g *warmupGate) begin() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.start.IsZero() {
+		g.start = g.now()
+	}
+}
+
+// beginOnOpen starts the warmup period once opened is closed, which is when
+// the holder has opened and the node is ready. It gives up if done is closed
+// first.
+func (g *warmupGate) beginOnOpen(opened *lockedChan, done <-chan struct{}) {
+	// Don't hold the lock while waiting, since the holder takes it to reset
+	// the channel when it closes.
+	opened.mu.RLock()
+	ch := opened.ch
+	opened.mu.RUnlock()
+
+	select {
+	case <-ch:
+		g.begin()
+	case <-done:
+	}
+}
+
+// allow returns true if a request should be accepted. The share of accepted
+// requests grows linearly from zero to all of them over the warmup period.
+func (g *warmupGate) allow() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// Requests are only allowed through once the node is ready, but that
+	// may be just before beginOnOpen notices.
+	now := g.now()
+	if g.start.IsZero() {
+		g.start = now
+	}
+	elapsed := now.Sub(g.start)
+	if elapsed >= g.period {
+		return true
+	}
+
+	// Accumulate the accepted share of each request, accepting a request
+	// whenever a whole request's worth has built up.
+	g.credit += float64(elapsed) / float64(g.period)
+	if g.credit < 1 {
+		return false
+	}
+	g.credit--
+	return true
+}
+
+// Draining returns true if the node is being drained.
+func (api *API) Draining() bool {
+	api.drainMu.RLock()
+	defer api.drainMu.RUnlock()
+	return api.draining
+}
+
+// RemapField adds offset to the column ID of every bit in the named set
+// field, moving bits between shards as needed. It is intended for
+// administrative use after columns have been renumbered. The field is
+// rewritten one row at a time: the remapped bits of a row are imported before
+// its old columns are cleared, so concurrent queries may observe a partially
+// remapped field, but never a row which is missing bits.
+func (api *API) RemapField(ctx context.Context, indexName, fieldName string, offset uint64) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.RemapField")
+	defer span.Finish()
+
+	if err := api.validate(apiRemapField); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return newNotFoundError(ErrIndexNotFound, indexName)
+	}
+	field := index.Field(fieldName)
+	if field == nil {
+		return newNotFoundError(ErrFieldNotFound, fieldName)
+	}
+	if field.Type() != FieldTypeSet {
+		return NewBadRequestError(errors.Errorf("cannot remap a %s field", field.Type()))
+	} else if index.Keys() || field.keys() {
+		return NewBadRequestError(errors.New("cannot remap a field using string keys"))
+	}
+	if offset == 0 {
+		return nil
+	}
+
+	result, err := api.executeInternal(ctx, indexName, fmt.Sprintf("Rows(%s)", fieldName))
+	if err != nil {
+		return errors.Wrap(err, "listing rows")
+	}
+	rows := result.(RowIdentifiers).Rows
+
+	for _, rowID := range rows {
+		result, err := api.executeInternal(ctx, indexName, fmt.Sprintf("Row(%s=%d)", fieldName, rowID))
+		if err != nil {
+			return errors.Wrapf(err, "reading row %d", rowID)
+		}
+		columns := result.(*Row).Columns()
+
+		// Group the remapped bits by their new shard.
+		bits := make(map[uint64][]Bit)
+		for _, col := range columns {
+			if col+offset < col {
+				return NewBadRequestError(errors.Errorf("remapping column %d by %d overflows", col, offset))
+			}
+			shard := (col + offset) / ShardWidth
+			bits[shard] = append(bits[shard], Bit{RowID: rowID, ColumnID: col + offset})
+		}
+
+		// Group the old columns which aren't also remapped columns by their
+		// shard. Both lists are sorted, so they are merged in one pass.
+		stale := make(map[uint64][]Bit)
+		for i, j := 0, 0; i < len(columns); i++ {
+			for j < len(columns) && columns[j]+offset < columns[i] {
+				j++
+			}
+			if j < len(columns) && columns[j]+offset == columns[i] {
+				continue
+			}
+			shard := columns[i] / ShardWidth
+			stale[shard] = append(stale[shard], Bit{RowID: rowID, ColumnID: columns[i]})
+		}
+
+		if err := api.importRemapped(ctx, indexName, fieldName, bits); err != nil {
+			return errors.Wrapf(err, "importing row %d", rowID)
+		}
+		if err := api.importRemapped(ctx, indexName, fieldName, stale, OptImportOptionsClear(true)); err != nil {
+			return errors.Wrapf(err, "clearing row %d", rowID)
+		}
+	}
+	return nil
+}
+
+// executeInternal executes a single call on behalf of an administrative
+// operation. It bypasses the checks of Query which apply to client queries,
+// such as warm up, draining and the query timeout.
+func (api *API) executeInternal(ctx context.Context, indexName, query string) (interface{}, error) {
+	q, err := pql.ParseString(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing")
+	}
+	resp, err := api.server.executor.Execute(ctx, indexName, q, nil, &execOptions{NoTimeout: true})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Results[0], nil
+}
+
+// importRemapped imports bits, grouped by shard, to the nodes owning each
+// shard.
+func (api *API) importRemapped(ctx context.Context, indexName, fieldName string, bits map[uint64][]Bit, opts ...ImportOption) error {
+	opts = append(opts, OptImportOptionsRemote(true))
+	var eg errgroup.Group
+	for shard, bits := range bits {
+		shard, bits := shard, bits
+		eg.Go(func() error {
+			return api.server.defaultClient.Import(ctx, indexName, fieldName, shard, bits, opts...)
+		})
+	}
+	return eg.Wait()
+}
+
 // ClusterMessage is for internal use. It decodes a protobuf message out of
 // the body and forwards it to the BroadcastHandler.
 func (api *API) ClusterMessage(ctx context.Context, reqBody io.Reader) error {
@@ -893,6 +1220,10 @@ func (api *API) FieldAttrDiff(ctx context.Context, indexName string, fieldName s
 type ImportOptions struct {
 	Clear          bool
 	IgnoreKeyCheck bool
+
+	// Remote is set on imports forwarded from another node, which are
+	// accepted while this node is draining.
+	Remote bool
 }
 
 // ImportOption is a functional option type for API.Import.
@@ -916,6 +1247,15 @@ func OptImportOptionsIgnoreKeyCheck(b bool) ImportOption {
 	}
 }
 
+// OptImportOptionsRemote is a functional option on ImportOption used to
+// specify whether the import was forwarded from another node.
+func OptImportOptionsRemote(b bool) ImportOption {
+	return func(o *ImportOptions) error {
+		o.Remote = b
+		return nil
+	}
+}
+
 // Import bulk imports data into a particular index,field,shard.
 func (api *API) Import(ctx context.Context, req *ImportRequest, opts ...ImportOption) error {
 	span, _ := tracing.StartSpanFromContext(ctx, "API.Import")
@@ -924,12 +1264,18 @@ func (api *API) Import(ctx context.Context, req *ImportRequest, opts ...ImportOp
 	if err := api.validate(apiImport); err != nil {
 		return errors.Wrap(err, "validating api method")
 	}
+	if !api.Ready() {
+		return ErrNodeStarting
+	}
 
 	// Set up import options.
 	options, err := setUpImportOptions(opts...)
 	if err != nil {
 		return errors.Wrap(err, "setting up import options")
 	}
+	if !options.Remote && api.Draining() {
+		return ErrDraining
+	}
 
 	index, field, err := api.indexField(req.Index, req.Field, req.Shard)
 	if err != nil {
@@ -980,8 +1326,9 @@ func (api *API) Import(ctx context.Context, req *ImportRequest, opts ...ImportOp
 				m[shard] = append(m[shard], bit)
 			}
 
-			// Signal to the receiving nodes to ignore checking for key translation.
-			opts = append(opts, OptImportOptionsIgnoreKeyCheck(true))
+			// Signal to the receiving nodes to ignore checking for key
+			// translation, and that the import was forwarded.
+			opts = append(opts, OptImportOptionsIgnoreKeyCheck(true), OptImportOptionsRemote(true))
 
 			var eg errgroup.Group
 			for shard, bits := range m {
@@ -1001,6 +1348,15 @@ func (api *API) Import(ctx context.Context, req *ImportRequest, opts ...ImportOp
 		return errors.Wrap(err, "validating shard ownership")
 	}
 
+	// Reject imports whose rows and columns look like they were swapped.
+	if !options.Clear {
+		for i := range req.ColumnIDs {
+			if err := api.server.setValidation.check(index, field, req.RowIDs[i], req.ColumnIDs[i]); err != nil {
+				return NewBadRequestError(err)
+			}
+		}
+	}
+
 	// Convert timestamps to time.Time.
 	timestamps := make([]*time.Time, len(req.Timestamps))
 	for i, ts := range req.Timestamps {
@@ -1027,6 +1383,70 @@ func (api *API) Import(ctx context.Context, req *ImportRequest, opts ...ImportOp
 	return errors.Wrap(err, "importing")
 }
 
+// ImportBits imports bits which may fall in any shard into a field. Bits
+// with keys are translated by Import. Bits with IDs are grouped by shard and
+// sent to the nodes which own each shard.
+func (api *API) ImportBits(ctx context.Context, indexName, fieldName string, bits []Bit, opts ...ImportOption) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.ImportBits")
+	defer span.Finish()
+
+	if err := api.validate(apiImport); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+	if !api.Ready() {
+		return ErrNodeStarting
+	}
+	options, err := setUpImportOptions(opts...)
+	if err != nil {
+		return errors.Wrap(err, "setting up import options")
+	}
+	if !options.Remote && api.Draining() {
+		return ErrDraining
+	}
+
+	index, field, err := api.indexField(indexName, fieldName, 0)
+	if err != nil {
+		return errors.Wrap(err, "getting index and field")
+	}
+
+	if index.Keys() || field.keys() {
+		req := &ImportRequest{Index: indexName, Field: fieldName}
+		for _, bit := range bits {
+			if field.keys() {
+				req.RowKeys = append(req.RowKeys, bit.RowKey)
+			} else {
+				req.RowIDs = append(req.RowIDs, bit.RowID)
+			}
+			if index.Keys() {
+				req.ColumnKeys = append(req.ColumnKeys, bit.ColumnKey)
+			} else {
+				req.ColumnIDs = append(req.ColumnIDs, bit.ColumnID)
+			}
+			req.Timestamps = append(req.Timestamps, bit.Timestamp)
+		}
+		return api.Import(ctx, req, opts...)
+	}
+
+	m := make(map[uint64][]Bit)
+	for _, bit := range bits {
+		shard := bit.ColumnID / ShardWidth
+		m[shard] = append(m[shard], bit)
+	}
+
+	// Signal to the receiving nodes that the import was forwarded.
+	opts = append(opts, OptImportOptionsRemote(true))
+
+	var eg errgroup.Group
+	for shard, bits := range m {
+		shard := shard
+		bits := bits
+		eg.Go(func() error {
+			return api.server.defaultClient.Import(ctx, indexName, fieldName, shard, bits, opts...)
+		})
+	}
+	return eg.Wait()
+}
+
 // ImportValue bulk imports values into a particular field.
 func (api *API) ImportValue(ctx context.Context, req *ImportValueRequest, opts ...ImportOption) error {
 	span, _ := tracing.StartSpanFromContext(ctx, "API.ImportValue")
@@ -1035,12 +1455,18 @@ func (api *API) ImportValue(ctx context.Context, req *ImportValueRequest, opts .
 	if err := api.validate(apiImportValue); err != nil {
 		return errors.Wrap(err, "validating api method")
 	}
+	if !api.Ready() {
+		return ErrNodeStarting
+	}
 
 	// Set up import options.
 	options, err := setUpImportOptions(opts...)
 	if err != nil {
 		return errors.Wrap(err, "setting up import options")
 	}
+	if !options.Remote && api.Draining() {
+		return ErrDraining
+	}
 
 	index, field, err := api.indexField(req.Index, req.Field, req.Shard)
 	if err != nil {
@@ -1075,8 +1501,9 @@ func (api *API) ImportValue(ctx context.Context, req *ImportValueRequest, opts .
 				})
 			}
 
-			// Signal to the receiving nodes to ignore checking for key translation.
-			opts = append(opts, OptImportOptionsIgnoreKeyCheck(true))
+			// Signal to the receiving nodes to ignore checking for key
+			// translation, and that the import was forwarded.
+			opts = append(opts, OptImportOptionsIgnoreKeyCheck(true), OptImportOptionsRemote(true))
 
 			var eg errgroup.Group
 			for shard, vals := range m {
@@ -1282,6 +1709,13 @@ func (api *API) Info() serverInfo {
 		CPUMHz:           mhz,
 		CPUType:          si.CPUModel(),
 		Memory:           mem,
+		Draining:         api.Draining(),
+		Ready:            api.Ready(),
+		Concurrency: concurrencyInfo{
+			NumCPU:           runtime.NumCPU(),
+			ExecutorPoolSize: api.server.executorPoolSize,
+			ImportPoolSize:   api.importWorkerPoolSize,
+		},
 	}
 }
 
@@ -1335,6 +1769,10 @@ type serverInfo struct {
 	CPUPhysicalCores int    `json:"cpuPhysicalCores"`
 	CPULogicalCores  int    `json:"cpuLogicalCores"`
 	CPUMHz           int    `json:"cpuMHz"`
+	Draining         bool   `json:"draining"`
+	Ready            bool   `json:"ready"`
+
+	Concurrency concurrencyInfo `json:"concurrency"`
 }
 
 type apiMethod int
@@ -1348,6 +1786,7 @@ const (
 	apiDeleteAvailableShard
 	apiDeleteIndex
 	apiDeleteView
+	apiDrain
 	apiExportCSV
 	apiFragmentBlockData
 	apiFragmentBlocks
@@ -1364,6 +1803,7 @@ const (
 	//apiMaxShards // not implemented
 	apiQuery
 	apiRecalculateCaches
+	apiRemapField
 	apiRemoveNode
 	apiResizeAbort
 	//apiSchema // not implemented
@@ -1378,6 +1818,7 @@ const (
 
 var methodsCommon = map[apiMethod]struct{}{
 	apiClusterMessage: {},
+	apiDrain:          {},
 	apiSetCoordinator: {},
 }
 
@@ -1404,6 +1845,7 @@ var methodsNormal = map[apiMethod]struct{}{
 	apiIndexAttrDiff:        {},
 	apiQuery:                {},
 	apiRecalculateCaches:    {},
+	apiRemapField:           {},
 	apiRemoveNode:           {},
 	apiShardNodes:           {},
 	apiViews:                {},
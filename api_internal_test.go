@@ -0,0 +1,94 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"testing"
+	"time"
+)
+
+// startWarmupGate returns a warmup gate whose period has been started by
+// its holder opening at the time returned by now.
+func startWarmupGate(t *testing.T, period time.Duration, now func() time.Time) *warmupGate {
+	t.Helper()
+	g := newWarmupGate(period)
+	g.now = now
+
+	opened := lockedChan{ch: make(chan struct{})}
+	done := make(chan struct{})
+	defer close(done)
+	started := make(chan struct{})
+	go func() {
+		g.beginOnOpen(&opened, done)
+		close(started)
+	}()
+	opened.Close()
+	<-started
+
+	if g.start.IsZero() {
+		t.Fatal("expected warmup to start when the holder opened")
+	}
+	return g
+}
+
+// Ensure the share of requests accepted while warming up increases over the
+// warmup period.
+func TestWarmupGate(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := startWarmupGate(t, 10*time.Second, func() time.Time { return now })
+
+	var prev int
+	for i := 0; i <= 10; i++ {
+		var accepted int
+		for j := 0; j < 100; j++ {
+			if g.allow() {
+				accepted++
+			}
+		}
+		switch {
+		case i == 0 && accepted != 0:
+			t.Fatalf("expected no requests accepted at start, got %d", accepted)
+		case i == 10 && accepted != 100:
+			t.Fatalf("expected all requests accepted after warmup, got %d", accepted)
+		case accepted < prev:
+			t.Fatalf("accepted requests decreased from %d to %d at %ds", prev, accepted, i)
+		case i > 0 && i < 10 && (accepted < i*10-1 || accepted > i*10+1):
+			t.Fatalf("unexpected accepted requests at %ds: %d", i, accepted)
+		}
+		prev = accepted
+		now = now.Add(time.Second)
+	}
+}
+
+// Ensure the warmup period runs from when the node becomes ready, so a node
+// which was idle throughout accepts its first request.
+func TestWarmupGate_Idle(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := startWarmupGate(t, 10*time.Second, func() time.Time { return now })
+
+	now = now.Add(10 * time.Second)
+	if !g.allow() {
+		t.Fatal("expected request after an idle warmup period to be accepted")
+	}
+
+	// Until the node is ready, the gate doesn't start.
+	g = newWarmupGate(10 * time.Second)
+	done := make(chan struct{})
+	close(done)
+	g.beginOnOpen(&lockedChan{ch: make(chan struct{})}, done)
+	if !g.start.IsZero() {
+		t.Fatal("expected warmup not to start before the holder opened")
+	}
+}
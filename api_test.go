@@ -266,3 +266,121 @@ type offsetModHasher struct{}
 func (*offsetModHasher) Hash(key uint64, n int) int {
 	return int(key+1) % n
 }
+
+func TestAPI_RemapField(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m := c[0]
+	ctx := context.Background()
+
+	m.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m.MustCreateField(t, "i", "f")
+
+	for _, q := range []string{
+		fmt.Sprintf("Set(%d, f=1)", 1),
+		fmt.Sprintf("Set(%d, f=1)", pilosa.ShardWidth-2),
+		fmt.Sprintf("Set(%d, f=1)", pilosa.ShardWidth+5),
+		fmt.Sprintf("Set(%d, f=2)", pilosa.ShardWidth-1),
+		// Columns which are also remapped columns must survive the remap.
+		fmt.Sprintf("Set(%d, f=3)", 10),
+		fmt.Sprintf("Set(%d, f=3)", 13),
+		fmt.Sprintf("Set(%d, f=3)", 16),
+	} {
+		m.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: q})
+	}
+
+	// An offset of 3 moves the bits near the end of shard 0 into shard 1,
+	// and the bit in shard 1 further along.
+	if err := m.API.RemapField(ctx, "i", "f", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	for rowID, exp := range map[uint64][]uint64{
+		1: {4, pilosa.ShardWidth + 1, pilosa.ShardWidth + 8},
+		2: {pilosa.ShardWidth + 2},
+		3: {13, 16, 19},
+	} {
+		res := m.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf("Row(f=%d)", rowID)})
+		if columns := res.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(columns, exp) {
+			t.Fatalf("unexpected columns for row %d: %v", rowID, columns)
+		}
+	}
+
+	t.Run("ErrIntField", func(t *testing.T) {
+		m.MustCreateField(t, "i", "v", pilosa.OptFieldTypeInt(0, 100))
+		if err := m.API.RemapField(ctx, "i", "v", 3); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	// Remapping is administrative, so it isn't rejected while draining.
+	t.Run("Draining", func(t *testing.T) {
+		if err := m.API.SetDraining(ctx, true); err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := m.API.SetDraining(ctx, false); err != nil {
+				t.Fatal(err)
+			}
+		}()
+		if err := m.API.RemapField(ctx, "i", "f", 1); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestAPI_SetValidation(t *testing.T) {
+	c := test.MustNewCluster(t, 1)
+	c[0].Config.SetValidation.Enabled = true
+	c[0].Config.SetValidation.MaxRowID = 1000
+	c[0].Config.SetValidation.MaxColumnID = 1 << 40
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "segment")
+	m0.MustCreateField(t, "i", "n", pilosa.OptFieldTypeInt(0, 1<<20))
+
+	t.Run("Set", func(t *testing.T) {
+		for _, tt := range []struct {
+			query string
+			ok    bool
+		}{
+			{query: "Set(123456789, segment=5)", ok: true},
+			{query: "Set(5, segment=123456789)"},
+			{query: "Set(0, segment=0)"},
+			{query: "Set(0, segment=1)", ok: true},
+			{query: "Set(5, n=123456)", ok: true},
+			{query: fmt.Sprintf("Set(%d, segment=5)", uint64(1)<<41)},
+		} {
+			_, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: tt.query})
+			if tt.ok && err != nil {
+				t.Fatalf("%s: unexpected error: %v", tt.query, err)
+			} else if !tt.ok && (err == nil || !strings.Contains(err.Error(), "set validation")) {
+				t.Fatalf("%s: expected set validation error, got: %v", tt.query, err)
+			}
+		}
+
+		// The rejected row was not written.
+		if res, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: "Count(Row(segment=123456789))"}); err != nil {
+			t.Fatal(err)
+		} else if n := res.Results[0].(uint64); n != 0 {
+			t.Fatalf("unexpected count of rejected row: %d", n)
+		}
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		// Rows and columns swapped.
+		req := &pilosa.ImportRequest{Index: "i", Field: "segment", Shard: 0, RowIDs: []uint64{123456789, 5}, ColumnIDs: []uint64{5, 6}}
+		if err := m0.API.Import(ctx, req); err == nil || !strings.Contains(err.Error(), "set validation") {
+			t.Fatalf("expected set validation error, got: %v", err)
+		}
+		req = &pilosa.ImportRequest{Index: "i", Field: "segment", Shard: 0, RowIDs: []uint64{5, 6}, ColumnIDs: []uint64{5, 6}}
+		if err := m0.API.Import(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -15,29 +15,31 @@ func _() {
 	_ = x[apiDeleteAvailableShard-4]
 	_ = x[apiDeleteIndex-5]
 	_ = x[apiDeleteView-6]
-	_ = x[apiExportCSV-7]
-	_ = x[apiFragmentBlockData-8]
-	_ = x[apiFragmentBlocks-9]
-	_ = x[apiFragmentData-10]
-	_ = x[apiField-11]
-	_ = x[apiFieldAttrDiff-12]
-	_ = x[apiImport-13]
-	_ = x[apiImportValue-14]
-	_ = x[apiIndex-15]
-	_ = x[apiIndexAttrDiff-16]
-	_ = x[apiQuery-17]
-	_ = x[apiRecalculateCaches-18]
-	_ = x[apiRemoveNode-19]
-	_ = x[apiResizeAbort-20]
-	_ = x[apiSetCoordinator-21]
-	_ = x[apiShardNodes-22]
-	_ = x[apiViews-23]
-	_ = x[apiApplySchema-24]
+	_ = x[apiDrain-7]
+	_ = x[apiExportCSV-8]
+	_ = x[apiFragmentBlockData-9]
+	_ = x[apiFragmentBlocks-10]
+	_ = x[apiFragmentData-11]
+	_ = x[apiField-12]
+	_ = x[apiFieldAttrDiff-13]
+	_ = x[apiImport-14]
+	_ = x[apiImportValue-15]
+	_ = x[apiIndex-16]
+	_ = x[apiIndexAttrDiff-17]
+	_ = x[apiQuery-18]
+	_ = x[apiRecalculateCaches-19]
+	_ = x[apiRemapField-20]
+	_ = x[apiRemoveNode-21]
+	_ = x[apiResizeAbort-22]
+	_ = x[apiSetCoordinator-23]
+	_ = x[apiShardNodes-24]
+	_ = x[apiViews-25]
+	_ = x[apiApplySchema-26]
 }
 
-const _apiMethod_name = "apiClusterMessageapiCreateFieldapiCreateIndexapiDeleteFieldapiDeleteAvailableShardapiDeleteIndexapiDeleteViewapiExportCSVapiFragmentBlockDataapiFragmentBlocksapiFragmentDataapiFieldapiFieldAttrDiffapiImportapiImportValueapiIndexapiIndexAttrDiffapiQueryapiRecalculateCachesapiRemoveNodeapiResizeAbortapiSetCoordinatorapiShardNodesapiViewsapiApplySchema"
+const _apiMethod_name = "apiClusterMessageapiCreateFieldapiCreateIndexapiDeleteFieldapiDeleteAvailableShardapiDeleteIndexapiDeleteViewapiDrainapiExportCSVapiFragmentBlockDataapiFragmentBlocksapiFragmentDataapiFieldapiFieldAttrDiffapiImportapiImportValueapiIndexapiIndexAttrDiffapiQueryapiRecalculateCachesapiRemapFieldapiRemoveNodeapiResizeAbortapiSetCoordinatorapiShardNodesapiViewsapiApplySchema"
 
-var _apiMethod_index = [...]uint16{0, 17, 31, 45, 59, 82, 96, 109, 121, 141, 158, 173, 181, 197, 206, 220, 228, 244, 252, 272, 285, 299, 316, 329, 337, 351}
+var _apiMethod_index = [...]uint16{0, 17, 31, 45, 59, 82, 96, 109, 117, 129, 149, 166, 181, 189, 205, 214, 228, 236, 252, 260, 280, 293, 306, 320, 337, 350, 358, 372}
 
 func (i apiMethod) String() string {
 	if i < 0 || i >= apiMethod(len(_apiMethod_index)-1) {
@@ -28,6 +28,12 @@ func BuildServerFlags(cmd *cobra.Command, srv *server.Command) {
 	flags.StringVarP(&srv.Config.Bind, "bind", "b", srv.Config.Bind, "Default URI on which pilosa should listen.")
 	flags.StringVar(&srv.Config.Advertise, "advertise", srv.Config.Advertise, "Address to advertise externally.")
 	flags.IntVarP(&srv.Config.MaxWritesPerRequest, "max-writes-per-request", "", srv.Config.MaxWritesPerRequest, "Number of write commands per request.")
+	flags.DurationVarP((*time.Duration)(&srv.Config.ShutdownTimeout), "shutdown-timeout", "", time.Duration(srv.Config.ShutdownTimeout), "Time to wait for in-flight requests to complete on shutdown.")
+	flags.DurationVarP((*time.Duration)(&srv.Config.DrainRetryAfter), "drain-retry-after", "", time.Duration(srv.Config.DrainRetryAfter), "Retry-After sent with requests rejected while the node is draining.")
+	flags.DurationVarP((*time.Duration)(&srv.Config.WarmupPeriod), "warmup-period", "", time.Duration(srv.Config.WarmupPeriod), "Period over which the share of accepted queries ramps up once the node is ready.")
+	flags.IntVarP(&srv.Config.MaxRangeBuckets, "max-range-buckets", "", srv.Config.MaxRangeBuckets, "Maximum number of time buckets a range query may cover (0 for no limit).")
+	flags.DurationVarP((*time.Duration)(&srv.Config.QueryTimeout), "query-timeout", "", time.Duration(srv.Config.QueryTimeout), "Time a query may run for before it is aborted (0 for no limit).")
+	flags.IntVarP(&srv.Config.MaxImportBatch, "max-import-batch", "", srv.Config.MaxImportBatch, "Maximum number of columns per import request (0 for no limit).")
 	flags.StringVar(&srv.Config.LogPath, "log-path", srv.Config.LogPath, "Log path")
 	flags.BoolVar(&srv.Config.Verbose, "verbose", srv.Config.Verbose, "Enable verbose logging")
 	flags.Uint64Var(&srv.Config.MaxMapCount, "max-map-count", srv.Config.MaxMapCount, "Limits the maximum number of active mmaps. Pilosa will fall back to reading files once this is exhausted. Set below your system's vm.max_map_count.")
@@ -38,6 +44,19 @@ func BuildServerFlags(cmd *cobra.Command, srv *server.Command) {
 
 	// Handler
 	flags.StringSliceVarP(&srv.Config.Handler.AllowedOrigins, "handler.allowed-origins", "", []string{}, "Comma separated list of allowed origin URIs (for CORS/WebUI).")
+	flags.BoolVar(&srv.Config.Handler.ExcludeColumns, "handler.exclude-columns", srv.Config.Handler.ExcludeColumns, "Exclude columns from row results by default when a query doesn't set excludeColumns.")
+	flags.Uint64Var(&srv.Config.Handler.PageLimit, "handler.page-limit", srv.Config.Handler.PageLimit, "Number of columns returned for each row when a query sets offset but not limit. Zero means no limit.")
+	flags.DurationVarP((*time.Duration)(&srv.Config.Handler.QueryResultTTL), "handler.query-result-ttl", "", time.Duration(srv.Config.Handler.QueryResultTTL), "How long the result of an async query is kept once the query has finished.")
+	flags.IntVarP(&srv.Config.Handler.MaxAsyncQueries, "handler.max-async-queries", "", srv.Config.Handler.MaxAsyncQueries, "Maximum number of async queries running at once (0 for no limit).")
+	flags.DurationVarP((*time.Duration)(&srv.Config.Handler.AsyncQueryTimeout), "handler.async-query-timeout", "", time.Duration(srv.Config.Handler.AsyncQueryTimeout), "Maximum time an async query may run for (0 for no limit).")
+	flags.IntVarP(&srv.Config.Handler.RateLimitPerSec, "handler.rate-limit-per-sec", "", srv.Config.Handler.RateLimitPerSec, "Maximum import and write query requests per second from each client IP (0 for no limit).")
+	flags.StringVarP(&srv.Config.Handler.APIKey, "handler.api-key", "", srv.Config.Handler.APIKey, "Bearer token required by requests which modify data (empty for no authentication).")
+	flags.BoolVar(&srv.Config.Handler.AuthReads, "handler.auth-reads", srv.Config.Handler.AuthReads, "Require the API key for requests which only read data too.")
+	flags.BoolVar(&srv.Config.Handler.EnablePprof, "handler.enable-pprof", srv.Config.Handler.EnablePprof, "Serve pprof profiling endpoints under /debug/pprof/.")
+	flags.Int64Var(&srv.Config.Handler.MaxRequestBytes, "handler.max-request-bytes", srv.Config.Handler.MaxRequestBytes, "Maximum size of request bodies other than imports (0 for no limit).")
+	flags.BoolVar(&srv.Config.SetValidation.Enabled, "set-validation.enabled", srv.Config.SetValidation.Enabled, "Reject Set() calls and imports whose row and column look swapped.")
+	flags.Uint64Var(&srv.Config.SetValidation.MaxRowID, "set-validation.max-row-id", srv.Config.SetValidation.MaxRowID, "Largest row ID accepted when set validation is enabled (0 for no limit).")
+	flags.Uint64Var(&srv.Config.SetValidation.MaxColumnID, "set-validation.max-column-id", srv.Config.SetValidation.MaxColumnID, "Largest column ID accepted when set validation is enabled (0 for no limit).")
 
 	// Cluster
 	flags.BoolVarP(&srv.Config.Cluster.Disabled, "cluster.disabled", "", srv.Config.Cluster.Disabled, "Disabled multi-node cluster communication (used for testing)")
@@ -17,6 +17,7 @@ package proto
 import (
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/pilosa/pilosa/v2"
@@ -442,6 +443,34 @@ func encodeQueryResponse(m *pilosa.QueryResponse) *internal.QueryResponse {
 		case pilosa.Pair:
 			pb.Results[i].Type = queryResultTypePair
 			pb.Results[i].Pairs = []*internal.Pair{encodePair(result)}
+		case []pilosa.PercentileCount:
+			pb.Results[i].Type = queryResultTypePercentileCounts
+			pb.Results[i].Pairs = encodePercentileCounts(result)
+		case []pilosa.BucketCount:
+			pb.Results[i].Type = queryResultTypeBucketCounts
+			pb.Results[i].Pairs = encodeBucketCounts(result)
+		case pilosa.OverlapCount:
+			pb.Results[i].Type = queryResultTypeOverlapCount
+			pb.Results[i].RowIDs = encodeOverlapCount(result)
+		case []pilosa.ColumnTime:
+			pb.Results[i].Type = queryResultTypeColumnTimes
+			pb.Results[i].Pairs = encodeColumnTimes(result)
+		case pilosa.VennCount:
+			pb.Results[i].Type = queryResultTypeVennCount
+			pb.Results[i].RowIDs = encodeVennCount(result)
+		case []bool:
+			pb.Results[i].Type = queryResultTypeBools
+			pb.Results[i].RowIDs = encodeBools(result)
+		case pilosa.BucketDelta:
+			pb.Results[i].Type = queryResultTypeBucketDelta
+			pb.Results[i].RowIDs = encodeBucketDelta(result)
+		case []pilosa.RowTime:
+			pb.Results[i].Type = queryResultTypeRowTimes
+			pb.Results[i].Pairs = encodeRowTimes(result)
+		case pilosa.TopNTotal:
+			pb.Results[i].Type = queryResultTypeTopNTotal
+			pb.Results[i].Pairs = encodePairs(result.Pairs)
+			pb.Results[i].RowIDs = result.Rows
 		case nil:
 			pb.Results[i].Type = queryResultTypeNil
 		default:
@@ -1064,6 +1093,15 @@ const (
 	queryResultTypeGroupCounts
 	queryResultTypeRowIdentifiers
 	queryResultTypePair
+	queryResultTypeOverlapCount
+	queryResultTypePercentileCounts
+	queryResultTypeBucketCounts
+	queryResultTypeVennCount
+	queryResultTypeColumnTimes
+	queryResultTypeBools
+	queryResultTypeBucketDelta
+	queryResultTypeRowTimes
+	queryResultTypeTopNTotal
 )
 
 func decodeQueryResult(pb *internal.QueryResult) interface{} {
@@ -1088,6 +1126,24 @@ func decodeQueryResult(pb *internal.QueryResult) interface{} {
 		return decodeGroupCounts(pb.GroupCounts)
 	case queryResultTypePair:
 		return decodePair(pb.Pairs[0])
+	case queryResultTypeOverlapCount:
+		return decodeOverlapCount(pb.RowIDs)
+	case queryResultTypePercentileCounts:
+		return decodePercentileCounts(pb.Pairs)
+	case queryResultTypeBucketCounts:
+		return decodeBucketCounts(pb.Pairs)
+	case queryResultTypeVennCount:
+		return decodeVennCount(pb.RowIDs)
+	case queryResultTypeColumnTimes:
+		return decodeColumnTimes(pb.Pairs)
+	case queryResultTypeBools:
+		return decodeBools(pb.RowIDs)
+	case queryResultTypeBucketDelta:
+		return decodeBucketDelta(pb.RowIDs)
+	case queryResultTypeRowTimes:
+		return decodeRowTimes(pb.Pairs)
+	case queryResultTypeTopNTotal:
+		return pilosa.TopNTotal{Pairs: decodePairs(pb.Pairs), Rows: pb.RowIDs}
 	}
 	panic(fmt.Sprintf("unknown type: %d", pb.Type))
 }
@@ -1186,6 +1242,117 @@ func decodePair(pb *internal.Pair) pilosa.Pair {
 	}
 }
 
+// decodePercentileCounts converts percentile counts from the pairs written by
+// encodePercentileCounts.
+func decodePercentileCounts(a []*internal.Pair) []pilosa.PercentileCount {
+	other := make([]pilosa.PercentileCount, len(a))
+	for i := range a {
+		other[i] = pilosa.PercentileCount{
+			Percentile: a[i].ID,
+			Count:      a[i].Count,
+		}
+	}
+	return other
+}
+
+// decodeBucketCounts converts bucket counts from the pairs written by
+// encodeBucketCounts.
+func decodeBucketCounts(a []*internal.Pair) []pilosa.BucketCount {
+	other := make([]pilosa.BucketCount, len(a))
+	for i := range a {
+		other[i] = pilosa.BucketCount{
+			Time:  time.Unix(int64(a[i].ID), 0).UTC(),
+			Count: a[i].Count,
+		}
+	}
+	return other
+}
+
+// decodeOverlapCount converts an overlap count from the packed form written
+// by encodeOverlapCount.
+func decodeOverlapCount(a []uint64) pilosa.OverlapCount {
+	if len(a) != 5 {
+		return pilosa.OverlapCount{}
+	}
+	return pilosa.OverlapCount{
+		AOnly:  a[0],
+		BOnly:  a[1],
+		Both:   a[2],
+		ATotal: a[3],
+		BTotal: a[4],
+	}
+}
+
+// decodeColumnTimes converts column times from the pairs written by
+// encodeColumnTimes.
+func decodeColumnTimes(a []*internal.Pair) []pilosa.ColumnTime {
+	other := make([]pilosa.ColumnTime, len(a))
+	for i := range a {
+		other[i] = pilosa.ColumnTime{
+			Column: a[i].ID,
+			Key:    a[i].Key,
+			Time:   time.Unix(int64(a[i].Count), 0).UTC(),
+		}
+	}
+	return other
+}
+
+// decodeRowTimes converts row times from the pairs written by encodeRowTimes.
+func decodeRowTimes(a []*internal.Pair) []pilosa.RowTime {
+	other := make([]pilosa.RowTime, len(a))
+	for i := range a {
+		other[i] = pilosa.RowTime{
+			Row:  a[i].ID,
+			Key:  a[i].Key,
+			Time: time.Unix(int64(a[i].Count), 0).UTC(),
+		}
+	}
+	return other
+}
+
+// decodeVennCount converts a Venn count from the packed form written by
+// encodeVennCount.
+func decodeVennCount(a []uint64) pilosa.VennCount {
+	if len(a) != 7 {
+		return pilosa.VennCount{}
+	}
+	return pilosa.VennCount{
+		AOnly: a[0],
+		BOnly: a[1],
+		COnly: a[2],
+		AB:    a[3],
+		AC:    a[4],
+		BC:    a[5],
+		ABC:   a[6],
+	}
+}
+
+// decodeBools converts a list of booleans from the form written by
+// encodeBools.
+func decodeBools(a []uint64) []bool {
+	other := make([]bool, len(a))
+	for i := range a {
+		other[i] = a[i] != 0
+	}
+	return other
+}
+
+// decodeBucketDelta converts a bucket delta from the packed form written by
+// encodeBucketDelta.
+func decodeBucketDelta(a []uint64) pilosa.BucketDelta {
+	if len(a) < 3 || uint64(len(a)-3) < a[2] {
+		return pilosa.BucketDelta{}
+	}
+	d := pilosa.BucketDelta{Added: a[0], Removed: a[1]}
+	if n := a[2]; n > 0 {
+		d.AddedColumns = a[3 : 3+n]
+	}
+	if rest := a[3+a[2]:]; len(rest) > 0 {
+		d.RemovedColumns = rest
+	}
+	return d
+}
+
 func decodeValCount(pb *internal.ValCount) pilosa.ValCount {
 	return pilosa.ValCount{
 		Val:   pb.Val,
@@ -1275,6 +1442,94 @@ func encodePair(p pilosa.Pair) *internal.Pair {
 	}
 }
 
+// encodePercentileCounts stores percentile counts as pairs, using the pair ID
+// for the percentile.
+func encodePercentileCounts(a []pilosa.PercentileCount) []*internal.Pair {
+	other := make([]*internal.Pair, len(a))
+	for i := range a {
+		other[i] = &internal.Pair{
+			ID:    a[i].Percentile,
+			Count: a[i].Count,
+		}
+	}
+	return other
+}
+
+// encodeBucketCounts stores bucket counts as pairs, using the pair ID for the
+// Unix time of the start of the bucket.
+func encodeBucketCounts(a []pilosa.BucketCount) []*internal.Pair {
+	other := make([]*internal.Pair, len(a))
+	for i := range a {
+		other[i] = &internal.Pair{
+			ID:    uint64(a[i].Time.Unix()),
+			Count: a[i].Count,
+		}
+	}
+	return other
+}
+
+// encodeOverlapCount packs an overlap count into a slice of uint64 so that it
+// can be carried in the RowIDs field of a QueryResult.
+func encodeOverlapCount(oc pilosa.OverlapCount) []uint64 {
+	return []uint64{oc.AOnly, oc.BOnly, oc.Both, oc.ATotal, oc.BTotal}
+}
+
+// encodeColumnTimes stores column times as pairs, using the pair count for the
+// Unix time of the start of the bucket.
+func encodeColumnTimes(a []pilosa.ColumnTime) []*internal.Pair {
+	other := make([]*internal.Pair, len(a))
+	for i := range a {
+		other[i] = &internal.Pair{
+			ID:    a[i].Column,
+			Key:   a[i].Key,
+			Count: uint64(a[i].Time.Unix()),
+		}
+	}
+	return other
+}
+
+// encodeRowTimes stores row times as pairs, using the pair count for the
+// time in seconds since the Unix epoch.
+func encodeRowTimes(a []pilosa.RowTime) []*internal.Pair {
+	other := make([]*internal.Pair, len(a))
+	for i := range a {
+		other[i] = &internal.Pair{
+			ID:    a[i].Row,
+			Key:   a[i].Key,
+			Count: uint64(a[i].Time.Unix()),
+		}
+	}
+	return other
+}
+
+// encodeVennCount packs a Venn count into a slice of uint64 so that it can be
+// carried in the RowIDs field of a QueryResult.
+func encodeVennCount(vc pilosa.VennCount) []uint64 {
+	return []uint64{vc.AOnly, vc.BOnly, vc.COnly, vc.AB, vc.AC, vc.BC, vc.ABC}
+}
+
+// encodeBools converts a list of booleans to ones and zeros so that it can be
+// carried in the RowIDs field of a QueryResult.
+func encodeBools(a []bool) []uint64 {
+	other := make([]uint64, len(a))
+	for i := range a {
+		if a[i] {
+			other[i] = 1
+		}
+	}
+	return other
+}
+
+// encodeBucketDelta packs a bucket delta into a slice of uint64 so that it
+// can be carried in the RowIDs field of a QueryResult. The counts are followed
+// by the number of added columns, the added columns and the removed columns.
+func encodeBucketDelta(d pilosa.BucketDelta) []uint64 {
+	a := make([]uint64, 0, 3+len(d.AddedColumns)+len(d.RemovedColumns))
+	a = append(a, d.Added, d.Removed, uint64(len(d.AddedColumns)))
+	a = append(a, d.AddedColumns...)
+	return append(a, d.RemovedColumns...)
+}
+
 func encodeValCount(vc pilosa.ValCount) *internal.ValCount {
 	return &internal.ValCount{
 		Val:   vc.Val,
@@ -19,6 +19,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -38,6 +40,46 @@ const (
 
 	columnLabel = "col"
 	rowLabel    = "row"
+
+	// maxTopColumnsN is the largest number of columns a TopColumns() call
+	// can return.
+	maxTopColumnsN = 1000
+
+	// maxTopColumnsRows is the largest number of rows in a single shard of a
+	// field which a TopColumns() call will scan.
+	maxTopColumnsRows = 100000
+
+	// maxFirstSeenLimit is the largest number of columns a FirstSeen() call
+	// can return, and the default limit.
+	maxFirstSeenLimit = 1000
+
+	// maxSlidingCountWindow is the largest number of buckets in the window
+	// of a SlidingCount() call.
+	maxSlidingCountWindow = 10000
+
+	// maxContainsManyColumns is the largest number of columns a
+	// ContainsMany() call can check.
+	maxContainsManyColumns = 10000
+
+	// materializedQueryAttr is the row attribute in which Materialize()
+	// records the query stored in a row.
+	materializedQueryAttr = "materializedQuery"
+
+	// maxBucketDeltaLimit is the largest number of added and removed columns
+	// a BucketDelta() call can return, and the default limit.
+	maxBucketDeltaLimit = 1000
+
+	// maxRecentLimit is the largest number of rows a Recent() call can
+	// return, and the default limit.
+	maxRecentLimit = 1000
+
+	// maxOverlapManyRows is the largest number of rows an OverlapMany() call
+	// can compare against its input.
+	maxOverlapManyRows = 1000
+
+	// maxRetentionReturns is the largest number of return buckets a
+	// Retention() call can compare its cohort against.
+	maxRetentionReturns = 1000
 )
 
 // executor recursively executes calls in a PQL query across all shards.
@@ -54,9 +96,34 @@ type executor struct {
 	// Maximum number of Set() or Clear() commands per request.
 	MaxWritesPerRequest int
 
+	// Maximum number of time buckets or views a time range may expand to.
+	MaxRangeBuckets int
+
+	// Maximum time a query may run for before it is aborted.
+	QueryTimeout time.Duration
+
+	// Checks on the row and column IDs of Set() calls.
+	SetValidation SetValidation
+
 	workersWG      sync.WaitGroup
 	workerPoolSize int
 	work           chan job
+
+	// Sorted row counts per field and set of shards, cached for
+	// RowCountPercentile().
+	rowCountsMu    sync.Mutex
+	rowCountsCache map[string]rowCountsEntry
+}
+
+// rowCountsCacheTTL is how long the sorted row counts of a field are cached
+// for RowCountPercentile() calls. Expired entries are removed on the next
+// call.
+const rowCountsCacheTTL = time.Minute
+
+// rowCountsEntry holds the sorted row counts of a field and their expiry.
+type rowCountsEntry struct {
+	counts  []uint64
+	expires time.Time
 }
 
 // executorOption is a functional option type for pilosa.Executor
@@ -81,6 +148,7 @@ func newExecutor(opts ...executorOption) *executor {
 	e := &executor{
 		client:         newNopInternalQueryClient(),
 		workerPoolSize: 2,
+		rowCountsCache: make(map[string]rowCountsEntry),
 	}
 	for _, opt := range opts {
 		err := opt(e)
@@ -116,6 +184,19 @@ func (e *executor) Execute(ctx context.Context, index string, q *pql.Query, shar
 
 	resp := QueryResponse{}
 
+	// Default options.
+	if opt == nil {
+		opt = &execOptions{}
+	}
+
+	// Bound the query's run time. Remote calls are bounded by the
+	// coordinating node, which cancels them if it times out.
+	if e.QueryTimeout > 0 && !opt.Remote && !opt.NoTimeout {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.QueryTimeout)
+		defer cancel()
+	}
+
 	// Check for query cancellation.
 	if err := validateQueryContext(ctx); err != nil {
 		return resp, err
@@ -136,9 +217,11 @@ func (e *executor) Execute(ctx context.Context, index string, q *pql.Query, shar
 		return resp, ErrTooManyWrites
 	}
 
-	// Default options.
-	if opt == nil {
-		opt = &execOptions{}
+	// A query can only report the total of one TopN() call.
+	if opt.IncludeTotal && !opt.Remote {
+		if n := topNCallN(q.Calls); n != 1 {
+			return resp, fmt.Errorf("include_total requires exactly one TopN() call, found %d", n)
+		}
 	}
 
 	// Translate query keys to ids, if necessary.
@@ -153,11 +236,28 @@ func (e *executor) Execute(ctx context.Context, index string, q *pql.Query, shar
 
 	results, err := e.execute(ctx, index, q, shards, opt)
 	if err != nil {
+		// Report a timed out or cancelled query as such, rather than as
+		// whichever error the interrupted call happened to return.
+		if cerr := validateQueryContext(ctx); cerr != nil {
+			return resp, cerr
+		}
 		return resp, err
 	} else if err := validateQueryContext(ctx); err != nil {
 		return resp, err
 	}
 
+	// Return the total separately from the pairs of the TopN() call it
+	// belongs to. Remote results are merged by the coordinating node.
+	if !opt.Remote {
+		for i, result := range results {
+			if result, ok := result.(TopNTotal); ok {
+				total := uint64(len(result.Rows))
+				resp.Total = &total
+				results[i] = result.Pairs
+			}
+		}
+	}
+
 	resp.Results = results
 
 	// Fill column attributes if requested.
@@ -200,11 +300,58 @@ func (e *executor) Execute(ctx context.Context, index string, q *pql.Query, shar
 		} else if err := validateQueryContext(ctx); err != nil {
 			return resp, err
 		}
+
+		for i := range results {
+			results[i] = emptyResult(results[i])
+		}
 	}
 
 	return resp, nil
 }
 
+// emptyResult replaces a nil result with an empty value of the same type, so
+// that a call which finds nothing returns an empty list or row rather than
+// null. Only calls which return no value at all, such as SetRowAttrs(), have a
+// nil result.
+func emptyResult(result interface{}) interface{} {
+	switch result := result.(type) {
+	case *Row:
+		if result == nil {
+			return NewRow()
+		}
+	case []Pair:
+		if result == nil {
+			return []Pair{}
+		}
+	case []GroupCount:
+		if result == nil {
+			return []GroupCount{}
+		}
+	case []PercentileCount:
+		if result == nil {
+			return []PercentileCount{}
+		}
+	case []BucketCount:
+		if result == nil {
+			return []BucketCount{}
+		}
+	case []ColumnTime:
+		if result == nil {
+			return []ColumnTime{}
+		}
+	case []RowTime:
+		if result == nil {
+			return []RowTime{}
+		}
+	case RowIdentifiers:
+		if result.Rows == nil && result.Keys == nil {
+			result.Rows = []uint64{}
+			return result
+		}
+	}
+	return result
+}
+
 // readColumnAttrSets returns a list of column attribute objects by id.
 func (e *executor) readColumnAttrSets(index *Index, ids []uint64) ([]*ColumnAttrSet, error) {
 	if index == nil {
@@ -312,9 +459,58 @@ func (e *executor) executeCall(ctx context.Context, index string, c *pql.Call, s
 		return e.executeClearRow(ctx, index, c, shards, opt)
 	case "Store":
 		return e.executeSetRow(ctx, index, c, shards, opt)
+	case "Materialize":
+		return e.executeMaterialize(ctx, index, c, shards, opt)
+	case "Refresh":
+		return e.executeRefresh(ctx, index, c, shards, opt)
+	case "PreviewSet":
+		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		return e.executePreviewSet(ctx, index, c, shards, opt)
 	case "Count":
 		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
 		return e.executeCount(ctx, index, c, shards, opt)
+	case "Overlap":
+		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		return e.executeOverlap(ctx, index, c, shards, opt)
+	case "OverlapMany":
+		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		return e.executeOverlapMany(ctx, index, c, shards, opt)
+	case "Venn":
+		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		return e.executeVenn(ctx, index, c, shards, opt)
+	case "RowCountPercentile":
+		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		return e.executeRowCountPercentile(ctx, index, c, shards, opt)
+	case "CumulativeCount":
+		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		return e.executeCumulativeCount(ctx, index, c, shards, opt)
+	case "SlidingCount":
+		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		return e.executeSlidingCount(ctx, index, c, shards, opt)
+	case "BucketDelta":
+		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		return e.executeBucketDelta(ctx, index, c, shards, opt)
+	case "TopColumns":
+		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		return e.executeTopColumns(ctx, index, c, shards, opt)
+	case "FirstSeen":
+		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		return e.executeFirstSeen(ctx, index, c, shards, opt)
+	case "Retention":
+		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		return e.executeRetention(ctx, index, c, shards, opt)
+	case "Recent":
+		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		return e.executeRecent(ctx, index, c, shards, opt)
+	case "ShareAny":
+		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		return e.executeShareAny(ctx, index, c, shards, opt)
+	case "Contains":
+		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		return e.executeContains(ctx, index, c, shards, opt)
+	case "ContainsMany":
+		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		return e.executeContainsMany(ctx, index, c, shards, opt)
 	case "Set":
 		return e.executeSet(ctx, index, c, opt)
 	case "SetRowAttrs":
@@ -667,10 +863,16 @@ func (e *executor) executeBitmapCallShard(ctx context.Context, index string, c *
 		return e.executeUnionShard(ctx, index, c, shard)
 	case "Xor":
 		return e.executeXorShard(ctx, index, c, shard)
+	case "AtLeast":
+		return e.executeAtLeastShard(ctx, index, c, shard)
 	case "Not":
 		return e.executeNotShard(ctx, index, c, shard)
 	case "Shift":
 		return e.executeShiftShard(ctx, index, c, shard)
+	case "IntersectRange":
+		return e.executeIntersectRangeShard(ctx, index, c, shard)
+	case "Uploaded":
+		return e.executeUploadedShard(ctx, c, shard)
 	default:
 		return nil, fmt.Errorf("unknown call: %s", c.Name)
 	}
@@ -856,8 +1058,10 @@ func (e *executor) executeMaxRowShard(ctx context.Context, index string, c *pql.
 
 // executeTopN executes a TopN() call.
 // This first performs the TopN() to determine the top results and then
-// requeries to retrieve the full counts for each of the top results.
-func (e *executor) executeTopN(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) ([]Pair, error) {
+// requeries to retrieve the full counts for each of the top results. If the
+// call's total argument is set, it returns a TopNTotal which also holds the
+// rows overlapping the call's filter.
+func (e *executor) executeTopN(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) (interface{}, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeTopN")
 	defer span.Finish()
 
@@ -870,36 +1074,55 @@ func (e *executor) executeTopN(ctx context.Context, index string, c *pql.Call, s
 		return nil, fmt.Errorf("executeTopN: %v", err)
 	}
 
+	// The original caller only collects the overlapping rows if the query
+	// asked for the total, and asks remote nodes for them with the total
+	// argument.
+	if !opt.Remote {
+		c = c.Clone()
+		if opt.IncludeTotal {
+			c.Args["total"] = true
+		} else {
+			delete(c.Args, "total")
+		}
+	}
+	withTotal, _ := c.Args["total"].(bool)
+
 	// Execute original query.
-	pairs, err := e.executeTopNShards(ctx, index, c, shards, opt)
+	result, err := e.executeTopNShards(ctx, index, c, shards, opt)
 	if err != nil {
 		return nil, errors.Wrap(err, "finding top results")
 	}
 
 	// If this call is against specific ids, or we didn't get results,
 	// or we are part of a larger distributed query then don't refetch.
-	if len(pairs) == 0 || len(idsArg) > 0 || opt.Remote {
-		return pairs, nil
-	}
-	// Only the original caller should refetch the full counts.
-	other := c.Clone()
+	if len(result.Pairs) > 0 && len(idsArg) == 0 && !opt.Remote {
+		// Only the original caller should refetch the full counts.
+		other := c.Clone()
+		delete(other.Args, "total")
 
-	ids := Pairs(pairs).Keys()
-	sort.Sort(uint64Slice(ids))
-	other.Args["ids"] = ids
+		ids := Pairs(result.Pairs).Keys()
+		sort.Sort(uint64Slice(ids))
+		other.Args["ids"] = ids
 
-	trimmedList, err := e.executeTopNShards(ctx, index, other, shards, opt)
-	if err != nil {
-		return nil, errors.Wrap(err, "retrieving full counts")
+		trimmed, err := e.executeTopNShards(ctx, index, other, shards, opt)
+		if err != nil {
+			return nil, errors.Wrap(err, "retrieving full counts")
+		}
+
+		trimmedList := trimmed.Pairs
+		if n != 0 && int(n) < len(trimmedList) {
+			trimmedList = trimmedList[0:n]
+		}
+		result.Pairs = trimmedList
 	}
 
-	if n != 0 && int(n) < len(trimmedList) {
-		trimmedList = trimmedList[0:n]
+	if withTotal {
+		return result, nil
 	}
-	return trimmedList, nil
+	return result.Pairs, nil
 }
 
-func (e *executor) executeTopNShards(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) ([]Pair, error) {
+func (e *executor) executeTopNShards(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) (TopNTotal, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeTopNShards")
 	defer span.Finish()
 
@@ -908,50 +1131,59 @@ func (e *executor) executeTopNShards(ctx context.Context, index string, c *pql.C
 		return e.executeTopNShard(ctx, index, c, shard)
 	}
 
-	// Merge returned results at coordinating node.
+	// Merge returned results at coordinating node. Remote nodes return
+	// plain pairs unless the call asked for the total.
 	reduceFn := func(prev, v interface{}) interface{} {
-		other, _ := prev.([]Pair)
-		return Pairs(other).Add(v.([]Pair))
+		other, _ := prev.(TopNTotal)
+		switch v := v.(type) {
+		case TopNTotal:
+			other.Pairs = Pairs(other.Pairs).Add(v.Pairs)
+			other.Rows = uint64Slice(other.Rows).merge(v.Rows)
+		case []Pair:
+			other.Pairs = Pairs(other.Pairs).Add(v)
+		}
+		return other
 	}
 
 	other, err := e.mapReduce(ctx, index, shards, c, opt, mapFn, reduceFn)
 	if err != nil {
-		return nil, err
+		return TopNTotal{}, err
 	}
-	results, _ := other.([]Pair)
+	result, _ := other.(TopNTotal)
 
 	// Sort final merged results.
-	sort.Sort(Pairs(results))
+	sort.Sort(Pairs(result.Pairs))
 
-	return results, nil
+	return result, nil
 }
 
-// executeTopNShard executes a TopN call for a single shard.
-func (e *executor) executeTopNShard(ctx context.Context, index string, c *pql.Call, shard uint64) ([]Pair, error) {
+// executeTopNShard executes a TopN call for a single shard. The rows which
+// overlap the call's filter are only returned if its total argument is set.
+func (e *executor) executeTopNShard(ctx context.Context, index string, c *pql.Call, shard uint64) (TopNTotal, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeTopNShard")
 	defer span.Finish()
 
 	fieldName, _ := c.Args["_field"].(string)
 	n, _, err := c.UintArg("n")
 	if err != nil {
-		return nil, fmt.Errorf("executeTopNShard: %v", err)
+		return TopNTotal{}, fmt.Errorf("executeTopNShard: %v", err)
 	} else if f := e.Holder.Field(index, fieldName); f != nil && f.Type() == FieldTypeInt {
-		return nil, fmt.Errorf("cannot compute TopN() on integer field: %q", fieldName)
+		return TopNTotal{}, fmt.Errorf("cannot compute TopN() on integer field: %q", fieldName)
 	}
 
 	attrName, _ := c.Args["attrName"].(string)
 	rowIDs, _, err := c.UintSliceArg("ids")
 	if err != nil {
-		return nil, fmt.Errorf("executeTopNShard: %v", err)
+		return TopNTotal{}, fmt.Errorf("executeTopNShard: %v", err)
 	}
 	minThreshold, _, err := c.UintArg("threshold")
 	if err != nil {
-		return nil, fmt.Errorf("executeTopNShard: %v", err)
+		return TopNTotal{}, fmt.Errorf("executeTopNShard: %v", err)
 	}
 	attrValues, _ := c.Args["attrValues"].([]interface{})
 	tanimotoThreshold, _, err := c.UintArg("tanimotoThreshold")
 	if err != nil {
-		return nil, fmt.Errorf("executeTopNShard: %v", err)
+		return TopNTotal{}, fmt.Errorf("executeTopNShard: %v", err)
 	}
 
 	// Retrieve bitmap used to intersect.
@@ -959,11 +1191,11 @@ func (e *executor) executeTopNShard(ctx context.Context, index string, c *pql.Ca
 	if len(c.Children) == 1 {
 		row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
 		if err != nil {
-			return nil, err
+			return TopNTotal{}, err
 		}
 		src = row
 	} else if len(c.Children) > 1 {
-		return nil, errors.New("TopN() can only have one input bitmap")
+		return TopNTotal{}, errors.New("TopN() can only have one input bitmap")
 	}
 
 	// Set default field.
@@ -973,9 +1205,9 @@ func (e *executor) executeTopNShard(ctx context.Context, index string, c *pql.Ca
 
 	f := e.Holder.fragment(index, fieldName, viewStandard, shard)
 	if f == nil {
-		return nil, nil
+		return TopNTotal{}, nil
 	} else if f.CacheType == CacheTypeNone {
-		return nil, fmt.Errorf("cannot compute TopN(), field has no cache: %q", fieldName)
+		return TopNTotal{}, fmt.Errorf("cannot compute TopN(), field has no cache: %q", fieldName)
 	}
 
 	if minThreshold == 0 {
@@ -983,9 +1215,9 @@ func (e *executor) executeTopNShard(ctx context.Context, index string, c *pql.Ca
 	}
 
 	if tanimotoThreshold > 100 {
-		return nil, errors.New("Tanimoto Threshold is from 1 to 100 only")
+		return TopNTotal{}, errors.New("Tanimoto Threshold is from 1 to 100 only")
 	}
-	return f.top(topOptions{
+	pairs, err := f.top(topOptions{
 		N:                 int(n),
 		Src:               src,
 		RowIDs:            rowIDs,
@@ -994,6 +1226,17 @@ func (e *executor) executeTopNShard(ctx context.Context, index string, c *pql.Ca
 		MinThreshold:      minThreshold,
 		TanimotoThreshold: tanimotoThreshold,
 	})
+	if err != nil {
+		return TopNTotal{}, err
+	}
+	result := TopNTotal{Pairs: pairs}
+
+	// Read the overlapping rows from the fragment rather than the rank
+	// cache, which may not hold every row.
+	if withTotal, _ := c.Args["total"].(bool); withTotal {
+		result.Rows = f.overlappingRows(src)
+	}
+	return result, nil
 }
 
 // executeDifferenceShard executes a difference() call for a local shard.
@@ -1316,164 +1559,1362 @@ func (e *executor) executeRows(ctx context.Context, index string, c *pql.Call, s
 	return results, nil
 }
 
-func (e *executor) executeRowsShard(_ context.Context, index string, fieldName string, c *pql.Call, shard uint64) (RowIDs, error) {
-	// Fetch index.
-	idx := e.Holder.Index(index)
-	if idx == nil {
-		return nil, newNotFoundError(ErrIndexNotFound, index)
+// executeShareAny executes a ShareAny() call, which returns true if its two
+// Rows() inputs have any row in common.
+func (e *executor) executeShareAny(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) (bool, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeShareAny")
+	defer span.Finish()
+
+	if len(c.Children) != 2 {
+		return false, errors.New("ShareAny() requires exactly two Rows() inputs")
 	}
-	// Fetch field.
-	f := e.Holder.Field(index, fieldName)
-	if f == nil {
-		return nil, newNotFoundError(ErrFieldNotFound, fieldName)
+	for _, child := range c.Children {
+		if child.Name != "Rows" {
+			return false, fmt.Errorf("ShareAny() inputs must be Rows() calls, got %s()", child.Name)
+		}
+	}
+	if callArgString(c.Children[0], "_field") != callArgString(c.Children[1], "_field") {
+		return false, errors.New("ShareAny() inputs must use the same field")
 	}
 
-	// rowIDs is the result set.
-	var rowIDs RowIDs
-
-	// views contains the list of views to inspect (and merge)
-	// in order to represent `Rows` for the field.
-	var views = []string{viewStandard}
-
-	// Handle `time` fields.
-	if f.Type() == FieldTypeTime {
-		var err error
-
-		// Parse "from" time, if set.
-		var fromTime time.Time
-		if v, ok := c.Args["from"]; ok {
-			if fromTime, err = parseTime(v); err != nil {
-				return nil, errors.Wrap(err, "parsing from time")
-			}
-		}
+	a, err := e.executeRows(ctx, index, c.Children[0], shards, opt)
+	if err != nil {
+		return false, errors.Wrap(err, "executing first rows")
+	}
+	b, err := e.executeRows(ctx, index, c.Children[1], shards, opt)
+	if err != nil {
+		return false, errors.Wrap(err, "executing second rows")
+	}
 
-		// Parse "to" time, if set.
-		var toTime time.Time
-		if v, ok := c.Args["to"]; ok {
-			if toTime, err = parseTime(v); err != nil {
-				return nil, errors.Wrap(err, "parsing to time")
-			}
+	// Both lists are sorted so walk them together.
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			return true, nil
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
 		}
+	}
+	return false, nil
+}
 
-		// Calculate the views for a range as long as some piece of the range
-		// (from/to) are specified, or if there's no standard view to represent
-		// all dates.
-		if !fromTime.IsZero() || !toTime.IsZero() || f.options.NoStandardView {
-			// If no quantum exists then return an empty result set.
-			q := f.TimeQuantum()
-			if q == "" {
-				return rowIDs, nil
-			}
-
-			// Get min/max based on existing views.
-			var vs []string
-			for _, v := range f.views() {
-				vs = append(vs, v.name)
-			}
-			min, max := minMaxViews(vs, q)
-
-			// If min/max are empty, there were no time views.
-			if min == "" || max == "" {
-				return rowIDs, nil
-			}
-
-			// Convert min/max from string to time.Time.
-			minTime, err := timeOfView(min, false)
-			if err != nil {
-				return rowIDs, errors.Wrapf(err, "getting min time from view: %s", min)
-			}
-			if fromTime.IsZero() || fromTime.Before(minTime) {
-				fromTime = minTime
-			}
-
-			maxTime, err := timeOfView(max, true)
-			if err != nil {
-				return rowIDs, errors.Wrapf(err, "getting max time from view: %s", max)
-			}
-			if toTime.IsZero() || toTime.After(maxTime) {
-				toTime = maxTime
-			}
+// executeContains executes a Contains() call, which returns whether the
+// "column" argument is set in the input row. Only the shard holding the
+// column is read, and a plain Row() input is checked with a single bit lookup
+// rather than by reading the row.
+func (e *executor) executeContains(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) (bool, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeContains")
+	defer span.Finish()
 
-			// Determine the views based on the specified time range.
-			views = viewsByTimeRange(viewStandard, fromTime, toTime, q)
-		}
+	if len(c.Children) != 1 {
+		return false, errors.New("Contains() requires a single input row")
 	}
-
-	start := uint64(0)
-	if previous, ok, err := c.UintArg("previous"); err != nil {
-		return nil, errors.Wrap(err, "getting previous")
-	} else if ok {
-		start = previous + 1
+	col, ok, err := c.UintArg("column")
+	if err != nil {
+		return false, fmt.Errorf("Contains(): %v", err)
+	} else if !ok {
+		return false, errors.New("Contains(): column required")
 	}
 
-	filters := []rowFilter{}
-	if columnID, ok, err := c.UintArg("column"); err != nil {
-		return nil, err
-	} else if ok {
-		colShard := columnID >> shardwidth.Exponent
-		if colShard != shard {
-			return rowIDs, nil
+	child := c.Children[0]
+	_, hasFrom := child.Args["from"]
+	_, hasTo := child.Args["to"]
+	direct := child.Name == "Row" && !child.HasConditionArg() && !hasFrom && !hasTo
+	if direct {
+		fieldName, err := child.FieldArg()
+		if err != nil {
+			return false, errors.New("Row() argument required: field")
+		} else if e.Holder.Field(index, fieldName) == nil {
+			return false, newNotFoundError(ErrFieldNotFound, fieldName)
 		}
-		filters = append(filters, filterColumn(columnID))
 	}
 
-	limit := int(^uint(0) >> 1)
-	if lim, hasLimit, err := c.UintArg("limit"); err != nil {
-		return nil, errors.Wrap(err, "getting limit")
-	} else if hasLimit {
-		filters = append(filters, filterWithLimit(lim))
-		limit = int(lim)
+	// Only execute against the shard which holds the column.
+	var columnShards []uint64
+	for _, shard := range shards {
+		if shard == col/ShardWidth {
+			columnShards = append(columnShards, shard)
+		}
+	}
+	if len(columnShards) == 0 {
+		return false, nil
 	}
 
-	for _, view := range views {
-		frag := e.Holder.fragment(index, fieldName, view, shard)
-		if frag == nil {
-			continue
+	// Execute calls in bulk on each remote node and merge.
+	mapFn := func(shard uint64) (interface{}, error) {
+		if direct {
+			return e.executeContainsRowShard(ctx, index, child, col, shard)
+		}
+		row, err := e.executeBitmapCallShard(ctx, index, child, shard)
+		if err != nil {
+			return nil, err
 		}
+		return row.Intersect(NewRow(col)).Any(), nil
+	}
 
-		viewRows := frag.rows(start, filters...)
-		rowIDs = rowIDs.merge(viewRows, limit)
+	// Merge returned results at coordinating node.
+	reduceFn := func(prev, v interface{}) interface{} {
+		other, _ := prev.(bool)
+		return other || v.(bool)
 	}
 
-	return rowIDs, nil
+	result, err := e.mapReduce(ctx, index, columnShards, c, opt, mapFn, reduceFn)
+	if err != nil {
+		return false, err
+	}
+	contains, _ := result.(bool)
+	return contains, nil
 }
 
-func (e *executor) executeRowShard(ctx context.Context, index string, c *pql.Call, shard uint64) (*Row, error) {
-	span, _ := tracing.StartSpanFromContext(ctx, "Executor.executeRowShard")
+// executeContainsRowShard returns whether col is set in the row given by a
+// plain Row() call, by looking up the bit in the shard's standard view.
+func (e *executor) executeContainsRowShard(ctx context.Context, index string, c *pql.Call, col, shard uint64) (bool, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "Executor.executeContainsRowShard")
 	defer span.Finish()
 
-	// Handle bsiGroup ranges differently.
-	if c.HasConditionArg() {
-		return e.executeRowBSIGroupShard(ctx, index, c, shard)
-	}
-
-	// Fetch column label from index.
-	idx := e.Holder.Index(index)
-	if idx == nil {
-		return nil, newNotFoundError(ErrIndexNotFound, index)
-	}
-
-	// Fetch field name from argument.
 	fieldName, err := c.FieldArg()
 	if err != nil {
-		return nil, errors.New("Row() argument required: field")
+		return false, errors.New("Row() argument required: field")
 	}
 	f := e.Holder.Field(index, fieldName)
 	if f == nil {
-		return nil, newNotFoundError(ErrFieldNotFound, fieldName)
+		return false, newNotFoundError(ErrFieldNotFound, fieldName)
+	} else if f.Type() == FieldTypeInt {
+		return false, fmt.Errorf("Contains(): field %s is an int field", fieldName)
 	}
 
-	rowID, rowOK, rowErr := c.UintArg(fieldName)
-	if rowErr != nil {
-		return nil, fmt.Errorf("Row() error with arg for row: %v", rowErr)
-	} else if !rowOK {
-		return nil, fmt.Errorf("Row() must specify %v", rowLabel)
+	rowID, ok, err := c.UintArg(fieldName)
+	if err != nil {
+		return false, fmt.Errorf("Row() error with arg for row: %v", err)
+	} else if !ok {
+		return false, fmt.Errorf("Row() must specify %v", rowLabel)
 	}
 
-	// Parse "from" time, if set.
-	var fromTime time.Time
-	if v, ok := c.Args["from"]; ok {
-		if fromTime, err = parseTime(v); err != nil {
+	frag := e.Holder.fragment(index, fieldName, viewStandard, shard)
+	if frag == nil {
+		return false, nil
+	}
+	return frag.hasBit(rowID, col)
+}
+
+// executeContainsMany executes a ContainsMany() call, which returns whether
+// each column in the "columns" argument is set in the input row, in the order
+// given.
+func (e *executor) executeContainsMany(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) ([]bool, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeContainsMany")
+	defer span.Finish()
+
+	if len(c.Children) != 1 {
+		return nil, errors.New("ContainsMany() requires a single input row")
+	}
+	columns, ok, err := uint64SliceArg(c, "columns")
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, errors.New("ContainsMany(): columns required")
+	} else if len(columns) > maxContainsManyColumns {
+		return nil, fmt.Errorf("ContainsMany(): number of columns must not exceed %d", maxContainsManyColumns)
+	}
+
+	// Only execute against shards which hold one of the columns.
+	byShard := make(map[uint64][]uint64)
+	for _, col := range columns {
+		byShard[col/ShardWidth] = append(byShard[col/ShardWidth], col)
+	}
+	var columnShards []uint64
+	for _, shard := range shards {
+		if _, ok := byShard[shard]; ok {
+			columnShards = append(columnShards, shard)
+		}
+	}
+
+	// Execute calls in bulk on each remote node and merge.
+	mapFn := func(shard uint64) (interface{}, error) {
+		row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
+		if err != nil {
+			return nil, err
+		}
+		members := row.Intersect(NewRow(byShard[shard]...)).Columns()
+
+		results := make([]bool, len(columns))
+		for i, col := range columns {
+			j := sort.Search(len(members), func(j int) bool { return members[j] >= col })
+			results[i] = j < len(members) && members[j] == col
+		}
+		return results, nil
+	}
+
+	// Merge returned results at coordinating node.
+	reduceFn := func(prev, v interface{}) interface{} {
+		other := v.([]bool)
+		if prev == nil {
+			return other
+		}
+		results := prev.([]bool)
+		for i := range results {
+			results[i] = results[i] || other[i]
+		}
+		return results
+	}
+
+	result, err := e.mapReduce(ctx, index, columnShards, c, opt, mapFn, reduceFn)
+	if err != nil {
+		return nil, err
+	}
+	results, _ := result.([]bool)
+	if results == nil {
+		results = make([]bool, len(columns))
+	}
+	return results, nil
+}
+
+// executeCumulativeCount executes a CumulativeCount() call. Each bucket
+// between "from" and "to" holds the number of columns set in the row at any
+// time up to the end of that bucket.
+func (e *executor) executeCumulativeCount(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) ([]BucketCount, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeCumulativeCount")
+	defer span.Finish()
+
+	if len(c.Children) != 1 || c.Children[0].Name != "Row" {
+		return nil, errors.New("CumulativeCount() requires a single Row() input")
+	}
+
+	buckets, toTime, unit, err := e.bucketArgs(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// Execute calls in bulk on each remote node and merge.
+	mapFn := func(shard uint64) (interface{}, error) {
+		return e.executeCumulativeCountShard(ctx, index, c.Children[0], buckets, toTime, unit, shard)
+	}
+
+	// Merge returned results at coordinating node.
+	reduceFn := func(prev, v interface{}) interface{} {
+		other := v.([]BucketCount)
+		if prev == nil {
+			return other
+		}
+		results := prev.([]BucketCount)
+		for i := range results {
+			results[i].Count += other[i].Count
+		}
+		return results
+	}
+
+	result, err := e.mapReduce(ctx, index, shards, c, opt, mapFn, reduceFn)
+	if err != nil {
+		return nil, err
+	}
+	results, _ := result.([]BucketCount)
+	return results, nil
+}
+
+// bucketArgs returns the start of each time bucket between the "from" and
+// "to" arguments of c, along with the end time and the bucket unit set by the
+// "granularity" argument.
+func (e *executor) bucketArgs(c *pql.Call) (buckets []time.Time, toTime time.Time, unit rune, err error) {
+	var fromTime time.Time
+	if v, ok := c.Args["from"]; !ok {
+		return nil, toTime, 0, fmt.Errorf("%s(): from required", c.Name)
+	} else if fromTime, err = parseTime(v); err != nil {
+		return nil, toTime, 0, errors.Wrap(err, "parsing from time")
+	}
+	if v, ok := c.Args["to"]; !ok {
+		return nil, toTime, 0, fmt.Errorf("%s(): to required", c.Name)
+	} else if toTime, err = parseTime(v); err != nil {
+		return nil, toTime, 0, errors.Wrap(err, "parsing to time")
+	}
+	if !fromTime.Before(toTime) {
+		return nil, toTime, 0, fmt.Errorf("%s(): from must be before to", c.Name)
+	}
+
+	if unit, err = granularityArg(c); err != nil {
+		return nil, toTime, 0, err
+	}
+
+	if buckets, err = e.timeBuckets(c.Name, fromTime, toTime, unit); err != nil {
+		return nil, toTime, 0, err
+	}
+	return buckets, toTime, unit, nil
+}
+
+// timeBuckets returns the start of each time bucket of unit from fromTime up
+// to toTime, failing as soon as there are more than MaxRangeBuckets of them.
+func (e *executor) timeBuckets(name string, fromTime, toTime time.Time, unit rune) ([]time.Time, error) {
+	var buckets []time.Time
+	for t := fromTime; t.Before(toTime); t = addTimeUnit(t, unit) {
+		if e.MaxRangeBuckets > 0 && len(buckets) == e.MaxRangeBuckets {
+			return nil, e.rangeBucketsError(name, len(buckets)+1, unit)
+		}
+		buckets = append(buckets, t)
+	}
+	return buckets, nil
+}
+
+// rangeBucketsError returns the error for a range of the named call which
+// covers at least n buckets of unit, more than MaxRangeBuckets.
+func (e *executor) rangeBucketsError(name string, n int, unit rune) error {
+	return fmt.Errorf("%s(): range covers at least %d buckets of granularity %c, exceeding the maximum of %d; use a coarser granularity or a shorter range", name, n, unit, e.MaxRangeBuckets)
+}
+
+// granularityArg returns the time unit (Y, M, D, H or I) of the "granularity"
+// argument of a call, which defaults to days.
+func granularityArg(c *pql.Call) (rune, error) {
+	v, ok := c.Args["granularity"]
+	if !ok {
+		return 'D', nil
+	}
+	switch s, _ := v.(string); s {
+	case "Y", "M", "D", "H", "I":
+		return rune(s[0]), nil
+	default:
+		return 0, fmt.Errorf("%s(): invalid granularity: %v", c.Name, v)
+	}
+}
+
+// timeRangeViews returns the time views of a quantum covering a time range,
+// failing if the range expands to more than MaxRangeBuckets views.
+func (e *executor) timeRangeViews(c *pql.Call, fromTime, toTime time.Time, q TimeQuantum) ([]string, error) {
+	views := viewsByTimeRange(viewStandard, fromTime, toTime, q)
+	if e.MaxRangeBuckets > 0 && len(views) > e.MaxRangeBuckets {
+		return nil, fmt.Errorf("%s(): time range covers %d views of quantum %s, exceeding the maximum of %d; use a coarser time quantum or a shorter range", c.Name, len(views), q, e.MaxRangeBuckets)
+	}
+	return views, nil
+}
+
+// executeCumulativeCountShard progressively unions the time views of a row
+// for each bucket within a single shard.
+func (e *executor) executeCumulativeCountShard(_ context.Context, index string, c *pql.Call, buckets []time.Time, toTime time.Time, unit rune, shard uint64) ([]BucketCount, error) {
+	fieldName, rowID, q, err := e.timeRowArgs(index, c, "CumulativeCount")
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BucketCount, len(buckets))
+	acc := NewRow()
+	for i, start := range buckets {
+		end := addTimeUnit(start, unit)
+		if end.After(toTime) {
+			end = toTime
+		}
+		for _, view := range viewsByTimeRange(viewStandard, start, end, q) {
+			frag := e.Holder.fragment(index, fieldName, view, shard)
+			if frag == nil {
+				continue
+			}
+			acc = acc.Union(frag.row(rowID))
+		}
+		results[i] = BucketCount{Time: start, Count: acc.Count()}
+	}
+	return results, nil
+}
+
+// timeRowArgs returns the field, row ID and time quantum of a Row() call
+// passed to the call named name, which requires a row in a time field.
+func (e *executor) timeRowArgs(index string, c *pql.Call, name string) (string, uint64, TimeQuantum, error) {
+	fieldName, err := c.FieldArg()
+	if err != nil {
+		return "", 0, "", fmt.Errorf("%s(): Row() field required", name)
+	}
+	rowID, ok, err := c.UintArg(fieldName)
+	if err != nil {
+		return "", 0, "", errors.Wrap(err, "getting row id")
+	} else if !ok {
+		return "", 0, "", fmt.Errorf("%s(): Row() row id required", name)
+	}
+
+	f := e.Holder.Field(index, fieldName)
+	if f == nil {
+		return "", 0, "", newNotFoundError(ErrFieldNotFound, fieldName)
+	}
+	q := f.TimeQuantum()
+	if q == "" {
+		return "", 0, "", fmt.Errorf("%s(): field has no time quantum: %q", name, fieldName)
+	}
+	return fieldName, rowID, q, nil
+}
+
+// executeSlidingCount executes a SlidingCount() call. Each bucket between
+// "from" and "to" holds the number of columns set in the row at any time
+// within the "window" buckets ending with that bucket.
+func (e *executor) executeSlidingCount(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) ([]BucketCount, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeSlidingCount")
+	defer span.Finish()
+
+	if len(c.Children) != 1 || c.Children[0].Name != "Row" {
+		return nil, errors.New("SlidingCount() requires a single Row() input")
+	}
+
+	buckets, toTime, unit, err := e.bucketArgs(c)
+	if err != nil {
+		return nil, err
+	}
+	window, ok, err := c.UintArg("window")
+	if err != nil {
+		return nil, errors.Wrap(err, "getting window")
+	} else if !ok || window == 0 {
+		return nil, errors.New("SlidingCount(): window must be a positive integer")
+	} else if window > maxSlidingCountWindow {
+		return nil, fmt.Errorf("SlidingCount(): window must not exceed %d", maxSlidingCountWindow)
+	}
+
+	// Execute calls in bulk on each remote node and merge.
+	mapFn := func(shard uint64) (interface{}, error) {
+		return e.executeSlidingCountShard(ctx, index, c.Children[0], buckets, toTime, unit, int(window), shard)
+	}
+
+	// Merge returned results at coordinating node.
+	reduceFn := func(prev, v interface{}) interface{} {
+		other := v.([]BucketCount)
+		if prev == nil {
+			return other
+		}
+		results := prev.([]BucketCount)
+		for i := range results {
+			results[i].Count += other[i].Count
+		}
+		return results
+	}
+
+	result, err := e.mapReduce(ctx, index, shards, c, opt, mapFn, reduceFn)
+	if err != nil {
+		return nil, err
+	}
+	results, _ := result.([]BucketCount)
+	return results, nil
+}
+
+// executeSlidingCountShard counts the columns of a row within the window
+// ending with each bucket for a single shard.
+//
+// Removing the bucket which leaves the window from a running union would
+// require a reference count for every column, so instead the union is
+// recomputed for each window. The time range of a window is read from the
+// fewest views which cover it, so a window of many buckets is mostly read
+// from coarser views rather than from each bucket's view.
+func (e *executor) executeSlidingCountShard(_ context.Context, index string, c *pql.Call, buckets []time.Time, toTime time.Time, unit rune, window int, shard uint64) ([]BucketCount, error) {
+	fieldName, rowID, q, err := e.timeRowArgs(index, c, "SlidingCount")
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BucketCount, len(buckets))
+	for i, start := range buckets {
+		end := addTimeUnit(start, unit)
+		if end.After(toTime) {
+			end = toTime
+		}
+		row := NewRow()
+		for _, view := range viewsByTimeRange(viewStandard, subTimeUnits(start, unit, window-1), end, q) {
+			frag := e.Holder.fragment(index, fieldName, view, shard)
+			if frag == nil {
+				continue
+			}
+			row = row.Union(frag.row(rowID))
+		}
+		results[i] = BucketCount{Time: start, Count: row.Count()}
+	}
+	return results, nil
+}
+
+// executeBucketDelta executes a BucketDelta() call, which compares the
+// columns set in a row within the time bucket containing "from" to those set
+// within the bucket containing "to".
+func (e *executor) executeBucketDelta(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) (BucketDelta, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeBucketDelta")
+	defer span.Finish()
+
+	if len(c.Children) != 1 || c.Children[0].Name != "Row" {
+		return BucketDelta{}, errors.New("BucketDelta() requires a single Row() input")
+	}
+
+	var err error
+	var fromTime, toTime time.Time
+	if v, ok := c.Args["from"]; !ok {
+		return BucketDelta{}, errors.New("BucketDelta(): from required")
+	} else if fromTime, err = parseTime(v); err != nil {
+		return BucketDelta{}, errors.Wrap(err, "parsing from time")
+	}
+	if v, ok := c.Args["to"]; !ok {
+		return BucketDelta{}, errors.New("BucketDelta(): to required")
+	} else if toTime, err = parseTime(v); err != nil {
+		return BucketDelta{}, errors.Wrap(err, "parsing to time")
+	}
+
+	unit, err := granularityArg(c)
+	if err != nil {
+		return BucketDelta{}, err
+	}
+
+	withColumns, _, err := c.BoolArg("columns")
+	if err != nil {
+		return BucketDelta{}, errors.Wrap(err, "BucketDelta()")
+	}
+	limit := maxBucketDeltaLimit
+	if n, ok, err := c.UintArg("limit"); err != nil {
+		return BucketDelta{}, errors.Wrap(err, "BucketDelta()")
+	} else if ok {
+		if n == 0 || n > maxBucketDeltaLimit {
+			return BucketDelta{}, fmt.Errorf("BucketDelta(): limit must be between 1 and %d", maxBucketDeltaLimit)
+		}
+		limit = int(n)
+	}
+	previous, hasPrevious, err := c.UintArg("previous")
+	if err != nil {
+		return BucketDelta{}, errors.Wrap(err, "BucketDelta()")
+	}
+	if !withColumns {
+		limit = 0
+	}
+
+	// Only two buckets are read, but the range between them is still held
+	// to the limit on time ranges.
+	fromBucket, toBucket := truncateTimeUnit(fromTime, unit), truncateTimeUnit(toTime, unit)
+	if toBucket.Before(fromBucket) {
+		_, err = e.timeBuckets(c.Name, toBucket, fromBucket, unit)
+	} else {
+		_, err = e.timeBuckets(c.Name, fromBucket, toBucket, unit)
+	}
+	if err != nil {
+		return BucketDelta{}, err
+	}
+
+	// Execute calls in bulk on each remote node and merge.
+	mapFn := func(shard uint64) (interface{}, error) {
+		return e.executeBucketDeltaShard(ctx, index, c.Children[0], fromBucket, toBucket, unit, previous, hasPrevious, limit, shard)
+	}
+
+	// Merge returned results at coordinating node.
+	reduceFn := func(prev, v interface{}) interface{} {
+		other, _ := prev.(BucketDelta)
+		return other.add(v.(BucketDelta), limit)
+	}
+
+	result, err := e.mapReduce(ctx, index, shards, c, opt, mapFn, reduceFn)
+	if err != nil {
+		return BucketDelta{}, err
+	}
+	delta, _ := result.(BucketDelta)
+	return delta, nil
+}
+
+// executeBucketDeltaShard compares the columns of a row in two time buckets
+// within a single shard. At most limit added and removed columns after
+// previous are listed.
+func (e *executor) executeBucketDeltaShard(_ context.Context, index string, c *pql.Call, fromBucket, toBucket time.Time, unit rune, previous uint64, hasPrevious bool, limit int, shard uint64) (BucketDelta, error) {
+	fieldName, rowID, q, err := e.timeRowArgs(index, c, "BucketDelta")
+	if err != nil {
+		return BucketDelta{}, err
+	}
+	if !strings.ContainsRune(string(q), unit) {
+		return BucketDelta{}, fmt.Errorf("BucketDelta(): field time quantum %q does not include granularity %q", q, unit)
+	}
+
+	bucketRow := func(start time.Time) *Row {
+		frag := e.Holder.fragment(index, fieldName, viewByTimeUnit(viewStandard, start, unit), shard)
+		if frag == nil {
+			return NewRow()
+		}
+		return frag.row(rowID)
+	}
+	fromRow, toRow := bucketRow(fromBucket), bucketRow(toBucket)
+
+	added, removed := toRow.Difference(fromRow), fromRow.Difference(toRow)
+	delta := BucketDelta{Added: added.Count(), Removed: removed.Count()}
+	if limit > 0 {
+		delta.AddedColumns = limitColumnsAfter(added.Columns(), previous, hasPrevious, limit)
+		delta.RemovedColumns = limitColumnsAfter(removed.Columns(), previous, hasPrevious, limit)
+	}
+	return delta, nil
+}
+
+// executeRetention executes a Retention() call. The cohort is the set of
+// columns set in the row within the bucket containing "cohort". The first
+// result is the size of the cohort, followed by the number of cohort columns
+// also set within the bucket containing each of the "returns" times.
+func (e *executor) executeRetention(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) ([]BucketCount, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeRetention")
+	defer span.Finish()
+
+	if len(c.Children) != 1 || c.Children[0].Name != "Row" {
+		return nil, errors.New("Retention() requires a single Row() input")
+	}
+	unit, err := granularityArg(c)
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := c.Args["cohort"]
+	if !ok {
+		return nil, errors.New("Retention(): cohort required")
+	}
+	cohort, err := parseTime(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing cohort time")
+	}
+	returns, ok := c.Args["returns"].([]interface{})
+	if !ok || len(returns) == 0 {
+		return nil, errors.New("Retention(): returns required")
+	} else if len(returns) > maxRetentionReturns {
+		return nil, fmt.Errorf("Retention(): number of returns must not exceed %d", maxRetentionReturns)
+	}
+	buckets := make([]time.Time, 0, len(returns)+1)
+	buckets = append(buckets, truncateTimeUnit(cohort, unit))
+	for _, v := range returns {
+		t, err := parseTime(v)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing return time")
+		}
+		buckets = append(buckets, truncateTimeUnit(t, unit))
+	}
+
+	// Execute calls in bulk on each remote node and merge.
+	mapFn := func(shard uint64) (interface{}, error) {
+		return e.executeRetentionShard(ctx, index, c.Children[0], buckets, unit, shard)
+	}
+
+	// Merge returned results at coordinating node.
+	reduceFn := func(prev, v interface{}) interface{} {
+		other := v.([]BucketCount)
+		if prev == nil {
+			return other
+		}
+		results := prev.([]BucketCount)
+		for i := range results {
+			results[i].Count += other[i].Count
+		}
+		return results
+	}
+
+	result, err := e.mapReduce(ctx, index, shards, c, opt, mapFn, reduceFn)
+	if err != nil {
+		return nil, err
+	}
+	results, _ := result.([]BucketCount)
+	return results, nil
+}
+
+// executeRetentionShard intersects the cohort bucket of a row with each
+// return bucket within a single shard. The first bucket is the cohort.
+func (e *executor) executeRetentionShard(_ context.Context, index string, c *pql.Call, buckets []time.Time, unit rune, shard uint64) ([]BucketCount, error) {
+	fieldName, rowID, q, err := e.timeRowArgs(index, c, "Retention")
+	if err != nil {
+		return nil, err
+	}
+	if !strings.ContainsRune(string(q), unit) {
+		return nil, fmt.Errorf("Retention(): field time quantum %q does not include granularity %q", q, unit)
+	}
+
+	bucketRow := func(start time.Time) *Row {
+		frag := e.Holder.fragment(index, fieldName, viewByTimeUnit(viewStandard, start, unit), shard)
+		if frag == nil {
+			return NewRow()
+		}
+		return frag.row(rowID)
+	}
+
+	cohort := bucketRow(buckets[0])
+	results := make([]BucketCount, len(buckets))
+	results[0] = BucketCount{Time: buckets[0], Count: cohort.Count()}
+	for i := 1; i < len(buckets); i++ {
+		results[i] = BucketCount{Time: buckets[i], Count: bucketRow(buckets[i]).intersectionCount(cohort)}
+	}
+	return results, nil
+}
+
+// limitColumnsAfter returns at most limit of the sorted columns which are
+// greater than previous.
+func limitColumnsAfter(columns []uint64, previous uint64, hasPrevious bool, limit int) []uint64 {
+	if hasPrevious {
+		i := sort.Search(len(columns), func(i int) bool { return columns[i] > previous })
+		columns = columns[i:]
+	}
+	if len(columns) > limit {
+		columns = columns[:limit]
+	}
+	return columns
+}
+
+// executeFirstSeen executes a FirstSeen() call, which returns the earliest
+// time bucket in which each column of a row in a time field is set. Results
+// are ordered by column and paginated with the "previous" and "limit"
+// arguments.
+func (e *executor) executeFirstSeen(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) ([]ColumnTime, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeFirstSeen")
+	defer span.Finish()
+
+	if len(c.Children) != 1 || c.Children[0].Name != "Row" {
+		return nil, errors.New("FirstSeen() requires a single Row() input")
+	}
+
+	var err error
+	var fromTime, toTime time.Time
+	if v, ok := c.Args["from"]; !ok {
+		return nil, errors.New("FirstSeen(): from required")
+	} else if fromTime, err = parseTime(v); err != nil {
+		return nil, errors.Wrap(err, "parsing from time")
+	}
+	if v, ok := c.Args["to"]; !ok {
+		return nil, errors.New("FirstSeen(): to required")
+	} else if toTime, err = parseTime(v); err != nil {
+		return nil, errors.Wrap(err, "parsing to time")
+	}
+	if !fromTime.Before(toTime) {
+		return nil, errors.New("FirstSeen(): from must be before to")
+	}
+
+	limit := maxFirstSeenLimit
+	if n, ok, err := c.UintArg("limit"); err != nil {
+		return nil, errors.Wrap(err, "FirstSeen()")
+	} else if ok {
+		if n == 0 || n > maxFirstSeenLimit {
+			return nil, fmt.Errorf("FirstSeen(): limit must be between 1 and %d", maxFirstSeenLimit)
+		}
+		limit = int(n)
+	}
+	previous, hasPrevious, err := c.UintArg("previous")
+	if err != nil {
+		return nil, errors.Wrap(err, "FirstSeen()")
+	}
+
+	// Scan the finest time unit the field stores.
+	fieldName, err := c.Children[0].FieldArg()
+	if err != nil {
+		return nil, errors.New("FirstSeen(): Row() field required")
+	}
+	f := e.Holder.Field(index, fieldName)
+	if f == nil {
+		return nil, newNotFoundError(ErrFieldNotFound, fieldName)
+	}
+	unit, ok := finestTimeUnit(f.TimeQuantum())
+	if !ok {
+		return nil, fmt.Errorf("FirstSeen(): field has no time quantum: %q", fieldName)
+	}
+	buckets, err := e.timeBuckets(c.Name, truncateTimeUnit(fromTime, unit), toTime, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Execute calls in bulk on each remote node and merge.
+	mapFn := func(shard uint64) (interface{}, error) {
+		if hasPrevious && shard < previous/ShardWidth {
+			return []ColumnTime(nil), nil
+		}
+		return e.executeFirstSeenShard(ctx, index, c.Children[0], buckets, unit, previous, hasPrevious, limit, shard)
+	}
+
+	// Merge returned results at coordinating node.
+	reduceFn := func(prev, v interface{}) interface{} {
+		other, _ := prev.([]ColumnTime)
+		merged := append(other, v.([]ColumnTime)...)
+		sort.Slice(merged, func(i, j int) bool { return merged[i].Column < merged[j].Column })
+		if len(merged) > limit {
+			merged = merged[:limit]
+		}
+		return merged
+	}
+
+	result, err := e.mapReduce(ctx, index, shards, c, opt, mapFn, reduceFn)
+	if err != nil {
+		return nil, err
+	}
+	results, _ := result.([]ColumnTime)
+	return results, nil
+}
+
+// executeFirstSeenShard scans the time views of a row in buckets, from oldest
+// to newest, within a single shard, recording the first bucket in which each
+// column is set.
+func (e *executor) executeFirstSeenShard(_ context.Context, index string, c *pql.Call, buckets []time.Time, unit rune, previous uint64, hasPrevious bool, limit int, shard uint64) ([]ColumnTime, error) {
+	fieldName, err := c.FieldArg()
+	if err != nil {
+		return nil, errors.New("FirstSeen(): Row() field required")
+	}
+	rowID, ok, err := c.UintArg(fieldName)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting row id")
+	} else if !ok {
+		return nil, errors.New("FirstSeen(): Row() row id required")
+	}
+
+	seen := make(map[uint64]time.Time)
+	for _, t := range buckets {
+		frag := e.Holder.fragment(index, fieldName, viewByTimeUnit(viewStandard, t, unit), shard)
+		if frag == nil {
+			continue
+		}
+		for _, col := range frag.row(rowID).Columns() {
+			if hasPrevious && col <= previous {
+				continue
+			}
+			if _, ok := seen[col]; !ok {
+				seen[col] = t
+			}
+		}
+	}
+
+	results := make([]ColumnTime, 0, len(seen))
+	for col, t := range seen {
+		results = append(results, ColumnTime{Column: col, Time: t})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Column < results[j].Column })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// executeRecent executes a Recent() call, which returns the rows of a time
+// field that are set in any time bucket starting at or after "since". Rows are
+// ordered by their most recent bucket, newest first, and paginated with the
+// "offset" and "limit" arguments.
+func (e *executor) executeRecent(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) ([]RowTime, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeRecent")
+	defer span.Finish()
+
+	fieldName, ok := c.Args["field"].(string)
+	if !ok || fieldName == "" {
+		return nil, errors.New("Recent(): field required")
+	}
+	f := e.Holder.Field(index, fieldName)
+	if f == nil {
+		return nil, newNotFoundError(ErrFieldNotFound, fieldName)
+	}
+	unit, ok := finestTimeUnit(f.TimeQuantum())
+	if !ok {
+		return nil, fmt.Errorf("Recent(): field has no time quantum: %q", fieldName)
+	}
+
+	v, ok := c.Args["since"]
+	if !ok {
+		return nil, errors.New("Recent(): since required")
+	}
+	since, err := parseTime(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing since time")
+	}
+	since = truncateTimeUnit(since, unit)
+
+	limit := maxRecentLimit
+	if n, ok, err := c.UintArg("limit"); err != nil {
+		return nil, errors.Wrap(err, "Recent()")
+	} else if ok {
+		if n == 0 || n > maxRecentLimit {
+			return nil, fmt.Errorf("Recent(): limit must be between 1 and %d", maxRecentLimit)
+		}
+		limit = int(n)
+	}
+	offset, _, err := c.UintArg("offset")
+	if err != nil {
+		return nil, errors.Wrap(err, "Recent()")
+	}
+
+	// Execute calls in bulk on each remote node and merge.
+	mapFn := func(shard uint64) (interface{}, error) {
+		return e.executeRecentShard(ctx, index, fieldName, since, unit, shard)
+	}
+
+	// Merge returned results at coordinating node, keeping the most recent
+	// bucket of each row.
+	reduceFn := func(prev, v interface{}) interface{} {
+		other, _ := prev.([]RowTime)
+		return mergeRowTimes(other, v.([]RowTime))
+	}
+
+	result, err := e.mapReduce(ctx, index, shards, c, opt, mapFn, reduceFn)
+	if err != nil {
+		return nil, err
+	}
+	results, _ := result.([]RowTime)
+
+	// Only the coordinating node paginates, since the order of the merged
+	// results is not known until every node has responded.
+	if opt.Remote {
+		return results, nil
+	}
+	if offset >= uint64(len(results)) {
+		return nil, nil
+	}
+	results = results[offset:]
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// executeRecentShard returns the most recent time bucket, starting at or after
+// since, in which each row of a time field is set within a single shard. It
+// fails if more than MaxRangeBuckets buckets exist since then.
+func (e *executor) executeRecentShard(_ context.Context, index string, fieldName string, since time.Time, unit rune, shard uint64) ([]RowTime, error) {
+	f := e.Holder.Field(index, fieldName)
+	if f == nil {
+		return nil, newNotFoundError(ErrFieldNotFound, fieldName)
+	}
+
+	// Find the existing views of the unit, newest first.
+	var buckets []time.Time
+	for _, view := range f.views() {
+		if !strings.HasPrefix(view.name, viewStandard+"_") {
+			continue
+		}
+		t, err := timeOfView(view.name, false)
+		if err != nil || viewByTimeUnit(viewStandard, t, unit) != view.name {
+			continue
+		}
+		if !t.Before(since) {
+			buckets = append(buckets, t)
+		}
+	}
+	if e.MaxRangeBuckets > 0 && len(buckets) > e.MaxRangeBuckets {
+		return nil, e.rangeBucketsError("Recent", len(buckets), unit)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].After(buckets[j]) })
+
+	seen := make(map[uint64]struct{})
+	var results []RowTime
+	for _, t := range buckets {
+		frag := e.Holder.fragment(index, fieldName, viewByTimeUnit(viewStandard, t, unit), shard)
+		if frag == nil {
+			continue
+		}
+		for _, rowID := range frag.rows(0) {
+			if _, ok := seen[rowID]; !ok {
+				seen[rowID] = struct{}{}
+				results = append(results, RowTime{Row: rowID, Time: t})
+			}
+		}
+	}
+	return results, nil
+}
+
+// mergeRowTimes merges two lists of rows ordered by most recent time bucket,
+// keeping the most recent bucket of rows which appear in both.
+func mergeRowTimes(a, b []RowTime) []RowTime {
+	latest := make(map[uint64]time.Time, len(a)+len(b))
+	for _, list := range [][]RowTime{a, b} {
+		for _, rt := range list {
+			if t, ok := latest[rt.Row]; !ok || rt.Time.After(t) {
+				latest[rt.Row] = rt.Time
+			}
+		}
+	}
+
+	results := make([]RowTime, 0, len(latest))
+	for row, t := range latest {
+		results = append(results, RowTime{Row: row, Time: t})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].Time.Equal(results[j].Time) {
+			return results[i].Time.After(results[j].Time)
+		}
+		return results[i].Row < results[j].Row
+	})
+	return results
+}
+
+// executeTopColumns executes a TopColumns() call, which returns the columns
+// that are set in the most rows of a field. Since a column only exists in a
+// single shard, the top columns of each shard can be merged directly.
+func (e *executor) executeTopColumns(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) ([]Pair, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeTopColumns")
+	defer span.Finish()
+
+	fieldName, ok := c.Args["field"].(string)
+	if !ok || fieldName == "" {
+		return nil, errors.New("TopColumns(): field required")
+	}
+	n, ok, err := c.UintArg("n")
+	if err != nil {
+		return nil, errors.Wrap(err, "TopColumns()")
+	} else if !ok || n == 0 {
+		return nil, errors.New("TopColumns(): n required")
+	} else if n > maxTopColumnsN {
+		return nil, fmt.Errorf("TopColumns(): n must be at most %d", maxTopColumnsN)
+	}
+
+	// Execute calls in bulk on each remote node and merge.
+	mapFn := func(shard uint64) (interface{}, error) {
+		return e.executeTopColumnsShard(ctx, index, fieldName, int(n), shard)
+	}
+
+	// Merge returned results at coordinating node.
+	reduceFn := func(prev, v interface{}) interface{} {
+		other, _ := prev.([]Pair)
+		return sortTopColumns(append(other, v.([]Pair)...), int(n))
+	}
+
+	result, err := e.mapReduce(ctx, index, shards, c, opt, mapFn, reduceFn)
+	if err != nil {
+		return nil, err
+	}
+	pairs, _ := result.([]Pair)
+	return pairs, nil
+}
+
+// executeTopColumnsShard returns the top n columns of a field by the number
+// of rows they are set in for a single shard.
+func (e *executor) executeTopColumnsShard(_ context.Context, index string, fieldName string, n int, shard uint64) ([]Pair, error) {
+	f := e.Holder.Field(index, fieldName)
+	if f == nil {
+		return nil, newNotFoundError(ErrFieldNotFound, fieldName)
+	} else if f.Type() == FieldTypeInt {
+		return nil, fmt.Errorf("cannot compute TopColumns() on integer field: %q", fieldName)
+	}
+
+	frag := e.Holder.fragment(index, fieldName, viewStandard, shard)
+	if frag == nil {
+		return nil, nil
+	}
+
+	rowIDs := frag.rows(0)
+	if len(rowIDs) > maxTopColumnsRows {
+		return nil, fmt.Errorf("TopColumns(): field %q has more than %d rows in shard %d", fieldName, maxTopColumnsRows, shard)
+	}
+
+	counts := make(map[uint64]uint64)
+	for _, rowID := range rowIDs {
+		for _, col := range frag.row(rowID).Columns() {
+			counts[col]++
+		}
+	}
+
+	pairs := make([]Pair, 0, len(counts))
+	for col, count := range counts {
+		pairs = append(pairs, Pair{ID: col, Count: count})
+	}
+	return sortTopColumns(pairs, n), nil
+}
+
+// sortTopColumns sorts pairs by descending count, then ascending column ID,
+// and truncates them to n.
+func sortTopColumns(pairs []Pair, n int) []Pair {
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Count != pairs[j].Count {
+			return pairs[i].Count > pairs[j].Count
+		}
+		return pairs[i].ID < pairs[j].ID
+	})
+	if len(pairs) > n {
+		pairs = pairs[:n]
+	}
+	return pairs
+}
+
+// executeRowCountPercentile executes a RowCountPercentile() call. Remote
+// nodes return the per-row counts of their shards; the percentiles are only
+// computed by the coordinating node.
+func (e *executor) executeRowCountPercentile(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) (interface{}, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeRowCountPercentile")
+	defer span.Finish()
+
+	fieldName, ok := c.Args["field"].(string)
+	if !ok || fieldName == "" {
+		return nil, errors.New("RowCountPercentile(): field required")
+	}
+
+	var percentiles []uint64
+	switch v := c.Args["p"].(type) {
+	case int64:
+		percentiles = []uint64{uint64(v)}
+	case []interface{}:
+		for _, p := range v {
+			n, ok := p.(int64)
+			if !ok {
+				return nil, fmt.Errorf("RowCountPercentile(): invalid percentile: %v", p)
+			}
+			percentiles = append(percentiles, uint64(n))
+		}
+	default:
+		return nil, errors.New("RowCountPercentile(): p required")
+	}
+	for _, p := range percentiles {
+		if p > 100 {
+			return nil, fmt.Errorf("RowCountPercentile(): percentile out of range: %d", p)
+		}
+	}
+
+	// Execute calls in bulk on each remote node and merge.
+	mapFn := func(shard uint64) (interface{}, error) {
+		return e.executeRowCountsShard(ctx, index, fieldName, shard)
+	}
+
+	// Merge returned results at coordinating node.
+	reduceFn := func(prev, v interface{}) interface{} {
+		other, _ := prev.([]Pair)
+		return Pairs(other).Add(v.([]Pair))
+	}
+
+	if opt.Remote {
+		result, err := e.mapReduce(ctx, index, shards, c, opt, mapFn, reduceFn)
+		if err != nil {
+			return nil, err
+		}
+		pairs, _ := result.([]Pair)
+		return pairs, nil
+	}
+
+	// The counts depend on the shards queried as well as the field.
+	key := rowCountsKey(index, fieldName, shards)
+	counts, ok := e.cachedRowCounts(key)
+	if !ok {
+		result, err := e.mapReduce(ctx, index, shards, c, opt, mapFn, reduceFn)
+		if err != nil {
+			return nil, err
+		}
+		pairs, _ := result.([]Pair)
+
+		counts = make([]uint64, len(pairs))
+		for i := range pairs {
+			counts[i] = pairs[i].Count
+		}
+		sort.Sort(uint64Slice(counts))
+		e.cacheRowCounts(key, counts)
+	}
+
+	// Use the nearest-rank method against the sorted counts.
+	results := make([]PercentileCount, len(percentiles))
+	for i, p := range percentiles {
+		results[i].Percentile = p
+		if len(counts) == 0 {
+			continue
+		}
+		rank := (p*uint64(len(counts)) + 99) / 100
+		if rank > 0 {
+			rank--
+		}
+		results[i].Count = counts[rank]
+	}
+	return results, nil
+}
+
+// rowCountsKey returns the rowCountsCache key for the row counts of a field
+// over shards.
+func rowCountsKey(index, fieldName string, shards []uint64) string {
+	var buf strings.Builder
+	buf.WriteString(index)
+	buf.WriteByte('/')
+	buf.WriteString(fieldName)
+	for i, shard := range shards {
+		if i == 0 {
+			buf.WriteByte('/')
+		} else {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(strconv.FormatUint(shard, 10))
+	}
+	return buf.String()
+}
+
+// cachedRowCounts returns the cached row counts for key, if they haven't
+// expired. Expired entries are removed, so that fields and shard sets which
+// are no longer queried don't accumulate.
+func (e *executor) cachedRowCounts(key string) ([]uint64, bool) {
+	e.rowCountsMu.Lock()
+	defer e.rowCountsMu.Unlock()
+
+	now := time.Now()
+	for k, entry := range e.rowCountsCache {
+		if now.After(entry.expires) {
+			delete(e.rowCountsCache, k)
+		}
+	}
+	entry, ok := e.rowCountsCache[key]
+	return entry.counts, ok
+}
+
+// cacheRowCounts caches the sorted row counts for key.
+func (e *executor) cacheRowCounts(key string, counts []uint64) {
+	e.rowCountsMu.Lock()
+	defer e.rowCountsMu.Unlock()
+	e.rowCountsCache[key] = rowCountsEntry{counts: counts, expires: time.Now().Add(rowCountsCacheTTL)}
+}
+
+// executeRowCountsShard returns the count of every row in a field for a
+// single shard.
+func (e *executor) executeRowCountsShard(_ context.Context, index string, fieldName string, shard uint64) ([]Pair, error) {
+	f := e.Holder.Field(index, fieldName)
+	if f == nil {
+		return nil, newNotFoundError(ErrFieldNotFound, fieldName)
+	} else if f.Type() == FieldTypeInt {
+		return nil, fmt.Errorf("cannot compute RowCountPercentile() on integer field: %q", fieldName)
+	}
+
+	frag := e.Holder.fragment(index, fieldName, viewStandard, shard)
+	if frag == nil {
+		return nil, nil
+	}
+
+	rowIDs := frag.rows(0)
+	pairs := make([]Pair, len(rowIDs))
+	for i, rowID := range rowIDs {
+		pairs[i] = Pair{ID: rowID, Count: frag.row(rowID).Count()}
+	}
+	return pairs, nil
+}
+
+func (e *executor) executeRowsShard(_ context.Context, index string, fieldName string, c *pql.Call, shard uint64) (RowIDs, error) {
+	// Fetch index.
+	idx := e.Holder.Index(index)
+	if idx == nil {
+		return nil, newNotFoundError(ErrIndexNotFound, index)
+	}
+	// Fetch field.
+	f := e.Holder.Field(index, fieldName)
+	if f == nil {
+		return nil, newNotFoundError(ErrFieldNotFound, fieldName)
+	}
+
+	// rowIDs is the result set.
+	var rowIDs RowIDs
+
+	// views contains the list of views to inspect (and merge)
+	// in order to represent `Rows` for the field.
+	var views = []string{viewStandard}
+
+	// Handle `time` fields.
+	if f.Type() == FieldTypeTime {
+		var err error
+
+		// Parse "from" time, if set.
+		var fromTime time.Time
+		if v, ok := c.Args["from"]; ok {
+			if fromTime, err = parseTime(v); err != nil {
+				return nil, errors.Wrap(err, "parsing from time")
+			}
+		}
+
+		// Parse "to" time, if set.
+		var toTime time.Time
+		if v, ok := c.Args["to"]; ok {
+			if toTime, err = parseTime(v); err != nil {
+				return nil, errors.Wrap(err, "parsing to time")
+			}
+		}
+
+		// Calculate the views for a range as long as some piece of the range
+		// (from/to) are specified, or if there's no standard view to represent
+		// all dates.
+		if !fromTime.IsZero() || !toTime.IsZero() || f.options.NoStandardView {
+			// If no quantum exists then return an empty result set.
+			q := f.TimeQuantum()
+			if q == "" {
+				return rowIDs, nil
+			}
+
+			// Get min/max based on existing views.
+			var vs []string
+			for _, v := range f.views() {
+				vs = append(vs, v.name)
+			}
+			min, max := minMaxViews(vs, q)
+
+			// If min/max are empty, there were no time views.
+			if min == "" || max == "" {
+				return rowIDs, nil
+			}
+
+			// Convert min/max from string to time.Time.
+			minTime, err := timeOfView(min, false)
+			if err != nil {
+				return rowIDs, errors.Wrapf(err, "getting min time from view: %s", min)
+			}
+			if fromTime.IsZero() || fromTime.Before(minTime) {
+				fromTime = minTime
+			}
+
+			maxTime, err := timeOfView(max, true)
+			if err != nil {
+				return rowIDs, errors.Wrapf(err, "getting max time from view: %s", max)
+			}
+			if toTime.IsZero() || toTime.After(maxTime) {
+				toTime = maxTime
+			}
+
+			// Determine the views based on the specified time range.
+			if views, err = e.timeRangeViews(c, fromTime, toTime, q); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	start := uint64(0)
+	if previous, ok, err := c.UintArg("previous"); err != nil {
+		return nil, errors.Wrap(err, "getting previous")
+	} else if ok {
+		start = previous + 1
+	}
+
+	filters := []rowFilter{}
+	if columnID, ok, err := c.UintArg("column"); err != nil {
+		return nil, err
+	} else if ok {
+		colShard := columnID >> shardwidth.Exponent
+		if colShard != shard {
+			return rowIDs, nil
+		}
+		filters = append(filters, filterColumn(columnID))
+	}
+
+	limit := int(^uint(0) >> 1)
+	if lim, hasLimit, err := c.UintArg("limit"); err != nil {
+		return nil, errors.Wrap(err, "getting limit")
+	} else if hasLimit {
+		filters = append(filters, filterWithLimit(lim))
+		limit = int(lim)
+	}
+
+	for _, view := range views {
+		frag := e.Holder.fragment(index, fieldName, view, shard)
+		if frag == nil {
+			continue
+		}
+
+		viewRows := frag.rows(start, filters...)
+		rowIDs = rowIDs.merge(viewRows, limit)
+	}
+
+	return rowIDs, nil
+}
+
+func (e *executor) executeRowShard(ctx context.Context, index string, c *pql.Call, shard uint64) (*Row, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "Executor.executeRowShard")
+	defer span.Finish()
+
+	// Handle bsiGroup ranges differently.
+	if c.HasConditionArg() {
+		return e.executeRowBSIGroupShard(ctx, index, c, shard)
+	}
+
+	// Fetch column label from index.
+	idx := e.Holder.Index(index)
+	if idx == nil {
+		return nil, newNotFoundError(ErrIndexNotFound, index)
+	}
+
+	// Fetch field name from argument.
+	fieldName, err := c.FieldArg()
+	if err != nil {
+		return nil, errors.New("Row() argument required: field")
+	}
+	f := e.Holder.Field(index, fieldName)
+	if f == nil {
+		return nil, newNotFoundError(ErrFieldNotFound, fieldName)
+	}
+
+	rowID, rowOK, rowErr := c.UintArg(fieldName)
+	if rowErr != nil {
+		return nil, fmt.Errorf("Row() error with arg for row: %v", rowErr)
+	} else if !rowOK {
+		return nil, fmt.Errorf("Row() must specify %v", rowLabel)
+	}
+
+	// Parse "from" time, if set.
+	var fromTime time.Time
+	if v, ok := c.Args["from"]; ok {
+		if fromTime, err = parseTime(v); err != nil {
 			return nil, errors.Wrap(err, "parsing from time")
 		}
 	}
@@ -1483,6 +2924,8 @@ func (e *executor) executeRowShard(ctx context.Context, index string, c *pql.Cal
 	if v, ok := c.Args["to"]; ok {
 		if toTime, err = parseTime(v); err != nil {
 			return nil, errors.Wrap(err, "parsing to time")
+		} else if fromTime.After(toTime) {
+			return nil, fmt.Errorf("%s(): from time %s is after to time %s", c.Name, fromTime.Format(TimeFormat), toTime.Format(TimeFormat))
 		}
 	}
 
@@ -1509,7 +2952,10 @@ func (e *executor) executeRowShard(ctx context.Context, index string, c *pql.Cal
 	}
 
 	// Union bitmaps across all time-based views.
-	views := viewsByTimeRange(viewStandard, fromTime, toTime, q)
+	views, err := e.timeRangeViews(c, fromTime, toTime, q)
+	if err != nil {
+		return nil, err
+	}
 	rows := make([]*Row, 0, len(views))
 	for _, view := range views {
 		f := e.Holder.fragment(index, fieldName, view, shard)
@@ -1730,6 +3176,44 @@ func (e *executor) executeXorShard(ctx context.Context, index string, c *pql.Cal
 	return other, nil
 }
 
+// executeAtLeastShard executes an AtLeast() call for a local shard. It returns
+// the columns set in at least k of the input rows.
+func (e *executor) executeAtLeastShard(ctx context.Context, index string, c *pql.Call, shard uint64) (*Row, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeAtLeastShard")
+	defer span.Finish()
+
+	k, ok, err := c.UintArg("k")
+	if err != nil {
+		return nil, fmt.Errorf("executeAtLeastShard: %v", err)
+	} else if !ok || k == 0 {
+		return nil, errors.New("AtLeast(): k must be a positive integer")
+	} else if k > uint64(len(c.Children)) {
+		return NewRow(), nil
+	}
+
+	// atLeast[j] holds the columns set in at least j+1 of the rows seen so
+	// far. Each input row promotes the columns it shares with atLeast[j-1]
+	// into atLeast[j], working downwards so a row is only counted once.
+	atLeast := make([]*Row, k)
+	for i := range atLeast {
+		atLeast[i] = NewRow()
+	}
+	for _, input := range c.Children {
+		row, err := e.executeBitmapCallShard(ctx, index, input, shard)
+		if err != nil {
+			return nil, err
+		}
+
+		for j := len(atLeast) - 1; j > 0; j-- {
+			atLeast[j] = atLeast[j].Union(atLeast[j-1].Intersect(row))
+		}
+		atLeast[0] = atLeast[0].Union(row)
+	}
+	other := atLeast[k-1]
+	other.invalidateCount()
+	return other, nil
+}
+
 // executeNotShard executes a not() call for a local shard.
 func (e *executor) executeNotShard(ctx context.Context, index string, c *pql.Call, shard uint64) (*Row, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeNotShard")
@@ -1762,63 +3246,421 @@ func (e *executor) executeNotShard(ctx context.Context, index string, c *pql.Cal
 		return nil, err
 	}
 
-	return existenceRow.Difference(row), nil
+	return existenceRow.Difference(row), nil
+}
+
+// executeShiftShard executes a shift() call for a local shard.
+func (e *executor) executeShiftShard(ctx context.Context, index string, c *pql.Call, shard uint64) (*Row, error) {
+	n, _, err := c.IntArg("n")
+	if err != nil {
+		return nil, fmt.Errorf("executeShiftShard: %v", err)
+	}
+
+	if len(c.Children) == 0 {
+		return nil, errors.New("Shift() requires an input row")
+	} else if len(c.Children) > 1 {
+		return nil, errors.New("Shift() only accepts a single row input")
+	}
+
+	row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
+	if err != nil {
+		return nil, err
+	}
+
+	return row.Shift(n)
+}
+
+// executeIntersectRangeShard executes an IntersectRange() call for a local
+// shard. The input row is intersected with the union of every row set in a
+// time field within the given time range.
+func (e *executor) executeIntersectRangeShard(ctx context.Context, index string, c *pql.Call, shard uint64) (*Row, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeIntersectRangeShard")
+	defer span.Finish()
+
+	if len(c.Children) != 1 {
+		return nil, errors.New("IntersectRange() requires a single input row")
+	}
+
+	fieldName, ok := c.Args["field"].(string)
+	if !ok || fieldName == "" {
+		return nil, errors.New("IntersectRange(): field required")
+	}
+	f := e.Holder.Field(index, fieldName)
+	if f == nil {
+		return nil, newNotFoundError(ErrFieldNotFound, fieldName)
+	}
+	q := f.TimeQuantum()
+	if q == "" {
+		return nil, fmt.Errorf("IntersectRange(): field has no time quantum: %q", fieldName)
+	}
+
+	var err error
+	var fromTime, toTime time.Time
+	if v, ok := c.Args["from"]; ok {
+		if fromTime, err = parseTime(v); err != nil {
+			return nil, errors.Wrap(err, "parsing from time")
+		}
+	}
+	if v, ok := c.Args["to"]; ok {
+		if toTime, err = parseTime(v); err != nil {
+			return nil, errors.Wrap(err, "parsing to time")
+		}
+	}
+
+	// Clamp the range to the existing views, so that an omitted or distant
+	// from or to doesn't expand to views which can't hold any data.
+	var vs []string
+	for _, v := range f.views() {
+		vs = append(vs, v.name)
+	}
+	min, max := minMaxViews(vs, q)
+
+	// If min/max are empty, there were no time views.
+	if min == "" || max == "" {
+		return &Row{}, nil
+	}
+
+	minTime, err := timeOfView(min, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting min time from view: %s", min)
+	}
+	if fromTime.IsZero() || fromTime.Before(minTime) {
+		fromTime = minTime
+	}
+
+	maxTime, err := timeOfView(max, true)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting max time from view: %s", max)
+	}
+	if toTime.IsZero() || toTime.After(maxTime) {
+		toTime = maxTime
+	}
+
+	src, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
+	if err != nil {
+		return nil, err
+	}
+
+	// Union every row across all time-based views.
+	views, err := e.timeRangeViews(c, fromTime, toTime, q)
+	if err != nil {
+		return nil, err
+	}
+	var rows []*Row
+	for _, view := range views {
+		frag := e.Holder.fragment(index, fieldName, view, shard)
+		if frag == nil {
+			continue
+		}
+		for _, rowID := range frag.rows(0) {
+			rows = append(rows, frag.row(rowID))
+		}
+	}
+	if len(rows) == 0 {
+		return &Row{}, nil
+	}
+	return src.Intersect(rows[0].Union(rows[1:]...)), nil
+}
+
+// executeUploadedShard executes an Uploaded() call for a local shard. The
+// columns of the bitmap uploaded with the query are attached to the call by
+// the API before execution, so that remote nodes receive them as well.
+func (e *executor) executeUploadedShard(ctx context.Context, c *pql.Call, shard uint64) (*Row, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "Executor.executeUploadedShard")
+	defer span.Finish()
+
+	columns, err := uploadedColumnsArg(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// Columns are sorted, so only the range within the shard is used.
+	min, max := shard*ShardWidth, (shard+1)*ShardWidth
+	i := sort.Search(len(columns), func(i int) bool { return columns[i] >= min })
+	j := sort.Search(len(columns), func(i int) bool { return columns[i] >= max })
+	return NewRow(columns[i:j]...), nil
+}
+
+// uploadedColumnsArg returns the sorted columns attached to an Uploaded()
+// call. The argument is a []uint64 on the originating node and a list of
+// integers once parsed by a remote node.
+func uploadedColumnsArg(c *pql.Call) ([]uint64, error) {
+	columns, ok, err := uint64SliceArg(c, "columns")
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, errors.New("Uploaded(): no bitmap uploaded with query")
+	}
+
+	if !sort.SliceIsSorted(columns, func(i, j int) bool { return columns[i] < columns[j] }) {
+		columns = append([]uint64(nil), columns...)
+		sort.Slice(columns, func(i, j int) bool { return columns[i] < columns[j] })
+	}
+	return columns, nil
+}
+
+// uint64SliceArg returns the list of non-negative integers in the argument
+// key of c, in order. Unlike pql.Call.UintSliceArg, it also accepts the list
+// as parsed from a query string.
+func uint64SliceArg(c *pql.Call, key string) ([]uint64, bool, error) {
+	switch v := c.Args[key].(type) {
+	case nil:
+		return nil, false, nil
+	case []uint64:
+		return v, true, nil
+	case []interface{}:
+		a := make([]uint64, len(v))
+		for i := range v {
+			n, ok := v[i].(int64)
+			if !ok || n < 0 {
+				return nil, false, fmt.Errorf("%s(): invalid %s value: %v", c.Name, key, v[i])
+			}
+			a[i] = uint64(n)
+		}
+		return a, true, nil
+	default:
+		return nil, false, fmt.Errorf("%s(): invalid %s argument type: %T", c.Name, key, v)
+	}
+}
+
+// executeCount executes a count() call.
+func (e *executor) executeCount(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) (uint64, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeCount")
+	defer span.Finish()
+
+	if len(c.Children) == 0 {
+		return 0, errors.New("Count() requires an input bitmap")
+	} else if len(c.Children) > 1 {
+		return 0, errors.New("Count() only accepts a single bitmap input")
+	}
+
+	// Counting a Rows() call returns the number of distinct rows rather
+	// than the number of columns, e.g. the number of rows in which a given
+	// column is set.
+	if c.Children[0].Name == "Rows" {
+		rowIDs, err := e.executeRows(ctx, index, c.Children[0], shards, opt)
+		if err != nil {
+			return 0, errors.Wrap(err, "executing rows")
+		}
+		return uint64(len(rowIDs)), nil
+	}
+
+	// Execute calls in bulk on each remote node and merge.
+	mapFn := func(shard uint64) (interface{}, error) {
+		row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
+		if err != nil {
+			return 0, err
+		}
+		return row.Count(), nil
+	}
+
+	// Merge returned results at coordinating node.
+	reduceFn := func(prev, v interface{}) interface{} {
+		other, _ := prev.(uint64)
+		return other + v.(uint64)
+	}
+
+	result, err := e.mapReduce(ctx, index, shards, c, opt, mapFn, reduceFn)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.(uint64)
+
+	return n, nil
+}
+
+// executeOverlapMany executes an OverlapMany() call, which returns the number
+// of columns each of the given rows of a field shares with the input row. The
+// input row is only computed once per shard. Results are in the order of the
+// "rows" argument.
+func (e *executor) executeOverlapMany(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) ([]Pair, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeOverlapMany")
+	defer span.Finish()
+
+	if len(c.Children) != 1 {
+		return nil, errors.New("OverlapMany() requires a single input row")
+	}
+	fieldName, ok := c.Args["field"].(string)
+	if !ok || fieldName == "" {
+		return nil, errors.New("OverlapMany(): field required")
+	}
+	if e.Holder.Field(index, fieldName) == nil {
+		return nil, newNotFoundError(ErrFieldNotFound, fieldName)
+	}
+	rowIDs, ok, err := uint64SliceArg(c, "rows")
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, errors.New("OverlapMany(): rows required")
+	} else if len(rowIDs) > maxOverlapManyRows {
+		return nil, fmt.Errorf("OverlapMany(): number of rows must not exceed %d", maxOverlapManyRows)
+	}
+
+	// Compare each requested row once, keeping the order given.
+	seen := make(map[uint64]struct{}, len(rowIDs))
+	unique := make([]uint64, 0, len(rowIDs))
+	for _, rowID := range rowIDs {
+		if _, ok := seen[rowID]; !ok {
+			seen[rowID] = struct{}{}
+			unique = append(unique, rowID)
+		}
+	}
+	rowIDs = unique
+
+	// Execute calls in bulk on each remote node and merge.
+	mapFn := func(shard uint64) (interface{}, error) {
+		base, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
+		if err != nil {
+			return nil, err
+		}
+		frag := e.Holder.fragment(index, fieldName, viewStandard, shard)
+		pairs := make([]Pair, 0, len(rowIDs))
+		for _, rowID := range rowIDs {
+			var n uint64
+			if frag != nil {
+				n = frag.row(rowID).intersectionCount(base)
+			}
+			pairs = append(pairs, Pair{ID: rowID, Count: n})
+		}
+		return pairs, nil
+	}
+
+	// Merge returned results at coordinating node.
+	reduceFn := func(prev, v interface{}) interface{} {
+		other, _ := prev.([]Pair)
+		return Pairs(other).Add(v.([]Pair))
+	}
+
+	result, err := e.mapReduce(ctx, index, shards, c, opt, mapFn, reduceFn)
+	if err != nil {
+		return nil, err
+	}
+	merged, _ := result.([]Pair)
+	counts := make(map[uint64]uint64, len(merged))
+	for _, pair := range merged {
+		counts[pair.ID] = pair.Count
+	}
+
+	pairs := make([]Pair, len(rowIDs))
+	for i, rowID := range rowIDs {
+		pairs[i] = Pair{ID: rowID, Count: counts[rowID]}
+	}
+	return pairs, nil
 }
 
-// executeShiftShard executes a shift() call for a local shard.
-func (e *executor) executeShiftShard(ctx context.Context, index string, c *pql.Call, shard uint64) (*Row, error) {
-	n, _, err := c.IntArg("n")
-	if err != nil {
-		return nil, fmt.Errorf("executeShiftShard: %v", err)
+// executeOverlap executes an Overlap() call. Both totals and the
+// intersection are computed in a single pass over each shard.
+func (e *executor) executeOverlap(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) (OverlapCount, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeOverlap")
+	defer span.Finish()
+
+	if len(c.Children) != 2 {
+		return OverlapCount{}, errors.New("Overlap() requires exactly two bitmap inputs")
 	}
 
-	if len(c.Children) == 0 {
-		return nil, errors.New("Shift() requires an input row")
-	} else if len(c.Children) > 1 {
-		return nil, errors.New("Shift() only accepts a single row input")
+	// Execute calls in bulk on each remote node and merge.
+	mapFn := func(shard uint64) (interface{}, error) {
+		return e.executeOverlapShard(ctx, index, c, shard)
 	}
 
-	row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
+	// Merge returned results at coordinating node.
+	reduceFn := func(prev, v interface{}) interface{} {
+		other, _ := prev.(OverlapCount)
+		return other.add(v.(OverlapCount))
+	}
+
+	result, err := e.mapReduce(ctx, index, shards, c, opt, mapFn, reduceFn)
 	if err != nil {
-		return nil, err
+		return OverlapCount{}, err
 	}
+	oc, _ := result.(OverlapCount)
+	return oc, nil
+}
 
-	return row.Shift(n)
+// executeOverlapShard counts the overlap of two rows for a single shard.
+func (e *executor) executeOverlapShard(ctx context.Context, index string, c *pql.Call, shard uint64) (OverlapCount, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeOverlapShard")
+	defer span.Finish()
+
+	a, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
+	if err != nil {
+		return OverlapCount{}, err
+	}
+	b, err := e.executeBitmapCallShard(ctx, index, c.Children[1], shard)
+	if err != nil {
+		return OverlapCount{}, err
+	}
+
+	aTotal, bTotal, both := a.Count(), b.Count(), a.intersectionCount(b)
+	return OverlapCount{
+		AOnly:  aTotal - both,
+		BOnly:  bTotal - both,
+		Both:   both,
+		ATotal: aTotal,
+		BTotal: bTotal,
+	}, nil
 }
 
-// executeCount executes a count() call.
-func (e *executor) executeCount(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) (uint64, error) {
-	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeCount")
+// executeVenn executes a Venn() call, which counts each of the seven regions
+// of a Venn diagram of three rows. Every region is computed in a single pass
+// over each shard.
+func (e *executor) executeVenn(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) (VennCount, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeVenn")
 	defer span.Finish()
 
-	if len(c.Children) == 0 {
-		return 0, errors.New("Count() requires an input bitmap")
-	} else if len(c.Children) > 1 {
-		return 0, errors.New("Count() only accepts a single bitmap input")
+	if len(c.Children) != 3 {
+		return VennCount{}, errors.New("Venn() requires exactly three bitmap inputs")
 	}
 
 	// Execute calls in bulk on each remote node and merge.
 	mapFn := func(shard uint64) (interface{}, error) {
-		row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
-		if err != nil {
-			return 0, err
-		}
-		return row.Count(), nil
+		return e.executeVennShard(ctx, index, c, shard)
 	}
 
 	// Merge returned results at coordinating node.
 	reduceFn := func(prev, v interface{}) interface{} {
-		other, _ := prev.(uint64)
-		return other + v.(uint64)
+		other, _ := prev.(VennCount)
+		return other.add(v.(VennCount))
 	}
 
 	result, err := e.mapReduce(ctx, index, shards, c, opt, mapFn, reduceFn)
 	if err != nil {
-		return 0, err
+		return VennCount{}, err
 	}
-	n, _ := result.(uint64)
+	vc, _ := result.(VennCount)
+	return vc, nil
+}
 
-	return n, nil
+// executeVennShard counts the Venn regions of three rows for a single shard.
+func (e *executor) executeVennShard(ctx context.Context, index string, c *pql.Call, shard uint64) (VennCount, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeVennShard")
+	defer span.Finish()
+
+	rows := make([]*Row, 3)
+	for i, child := range c.Children {
+		row, err := e.executeBitmapCallShard(ctx, index, child, shard)
+		if err != nil {
+			return VennCount{}, err
+		}
+		rows[i] = row
+	}
+	a, b, cc := rows[0], rows[1], rows[2]
+
+	ab := a.Intersect(b)
+	abc := ab.Intersect(cc).Count()
+	abN := ab.Count()
+	acN := a.Intersect(cc).Count()
+	bcN := b.Intersect(cc).Count()
+
+	return VennCount{
+		AOnly: a.Count() - abN - acN + abc,
+		BOnly: b.Count() - abN - bcN + abc,
+		COnly: cc.Count() - acN - bcN + abc,
+		AB:    abN - abc,
+		AC:    acN - abc,
+		BC:    bcN - abc,
+		ABC:   abc,
+	}, nil
 }
 
 // executeClearBit executes a Clear() call.
@@ -2013,6 +3855,130 @@ func (e *executor) executeSetRow(ctx context.Context, index string, c *pql.Call,
 	return r, err
 }
 
+// executeMaterialize executes a Materialize() call, which stores the result
+// of its input row like Store() and records the input as a row attribute so
+// that the row can later be recomputed with Refresh().
+func (e *executor) executeMaterialize(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) (bool, error) {
+	if len(c.Children) != 1 {
+		return false, errors.New("Materialize() requires a source row")
+	}
+	fieldName, err := c.FieldArg()
+	if err != nil {
+		return false, errors.New("Materialize() argument required: field")
+	}
+	rowID, ok, err := c.UintArg(fieldName)
+	if err != nil {
+		return false, fmt.Errorf("reading Materialize() row: %v", err)
+	} else if !ok {
+		return false, fmt.Errorf("need the <FIELD>=<ROW> argument on Materialize()")
+	}
+
+	store := &pql.Call{Name: "Store", Args: c.Args, Children: c.Children}
+	changed, err := e.executeSetRow(ctx, index, store, shards, opt)
+	if err != nil {
+		return false, err
+	}
+
+	attrs := &pql.Call{Name: "SetRowAttrs", Args: map[string]interface{}{
+		"_field":              fieldName,
+		"_" + rowLabel:        rowID,
+		materializedQueryAttr: c.Children[0].String(),
+	}}
+	if err := e.executeSetRowAttrs(ctx, index, attrs, opt); err != nil {
+		return false, errors.Wrap(err, "recording materialized query")
+	}
+	return changed, nil
+}
+
+// executeRefresh executes a Refresh() call, which stores the result of the
+// query recorded by Materialize() for a row again.
+func (e *executor) executeRefresh(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) (bool, error) {
+	fieldName, err := c.FieldArg()
+	if err != nil {
+		return false, errors.New("Refresh() argument required: field")
+	}
+	rowID, ok, err := c.UintArg(fieldName)
+	if err != nil {
+		return false, fmt.Errorf("reading Refresh() row: %v", err)
+	} else if !ok {
+		return false, fmt.Errorf("need the <FIELD>=<ROW> argument on Refresh()")
+	}
+	field := e.Holder.Field(index, fieldName)
+	if field == nil {
+		return false, newNotFoundError(ErrFieldNotFound, fieldName)
+	}
+
+	attrs, err := field.RowAttrStore().Attrs(rowID)
+	if err != nil {
+		return false, errors.Wrap(err, "getting row attrs")
+	}
+	query, _ := attrs[materializedQueryAttr].(string)
+	if query == "" {
+		return false, fmt.Errorf("Refresh(): row %d of field %q was not materialized", rowID, fieldName)
+	}
+	q, err := pql.ParseString(query)
+	if err != nil {
+		return false, errors.Wrap(err, "parsing materialized query")
+	} else if len(q.Calls) != 1 {
+		return false, fmt.Errorf("Refresh(): invalid materialized query: %q", query)
+	}
+
+	store := &pql.Call{Name: "Store", Args: c.Args, Children: q.Calls}
+	return e.executeSetRow(ctx, index, store, shards, opt)
+}
+
+// executePreviewSet executes a PreviewSet() call, which counts the columns
+// of its input row which are not yet set in the destination row. That is the
+// number of bits which setting every input column in the destination row
+// would change. Nothing is written.
+func (e *executor) executePreviewSet(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) (uint64, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executePreviewSet")
+	defer span.Finish()
+
+	if len(c.Children) != 1 {
+		return 0, errors.New("PreviewSet() requires a source row")
+	}
+	fieldName, err := c.FieldArg()
+	if err != nil {
+		return 0, errors.New("PreviewSet() argument required: field")
+	}
+	rowID, ok, err := c.UintArg(fieldName)
+	if err != nil {
+		return 0, fmt.Errorf("reading PreviewSet() row: %v", err)
+	} else if !ok {
+		return 0, fmt.Errorf("need the <FIELD>=<ROW> argument on PreviewSet()")
+	}
+	if e.Holder.Field(index, fieldName) == nil {
+		return 0, newNotFoundError(ErrFieldNotFound, fieldName)
+	}
+
+	// Execute calls in bulk on each remote node and merge.
+	mapFn := func(shard uint64) (interface{}, error) {
+		src, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
+		if err != nil {
+			return nil, errors.Wrap(err, "getting source row")
+		}
+		frag := e.Holder.fragment(index, fieldName, viewStandard, shard)
+		if frag == nil {
+			return src.Count(), nil
+		}
+		return src.Difference(frag.row(rowID)).Count(), nil
+	}
+
+	// Merge returned results at coordinating node.
+	reduceFn := func(prev, v interface{}) interface{} {
+		other, _ := prev.(uint64)
+		return other + v.(uint64)
+	}
+
+	result, err := e.mapReduce(ctx, index, shards, c, opt, mapFn, reduceFn)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.(uint64)
+	return n, nil
+}
+
 // executeSetRowShard executes a SetRow() call for a single shard.
 func (e *executor) executeSetRowShard(ctx context.Context, index string, c *pql.Call, shard uint64) (bool, error) {
 	fieldName, err := c.FieldArg()
@@ -2097,6 +4063,15 @@ func (e *executor) executeSet(ctx context.Context, index string, c *pql.Call, op
 		return false, newNotFoundError(ErrFieldNotFound, fieldName)
 	}
 
+	// Reject Set() calls whose row and column look like they were swapped.
+	if f.Type() != FieldTypeInt {
+		if rowID, ok, err := c.UintArg(fieldName); err == nil && ok {
+			if err := e.SetValidation.check(idx, f, rowID, colID); err != nil {
+				return false, err
+			}
+		}
+	}
+
 	// Set column on existence field.
 	if ef := idx.existenceField(); ef != nil {
 		if _, err := ef.SetBit(0, colID, nil); err != nil {
@@ -2138,6 +4113,41 @@ func (e *executor) executeSet(ctx context.Context, index string, c *pql.Call, op
 	return e.executeSetBitField(ctx, index, c, f, colID, rowID, timestamp, opt)
 }
 
+// SetValidation holds optional checks on the row and column IDs of Set()
+// calls and imports into fields which aren't integer fields. They catch
+// clients which swap the row and column of a bit, which otherwise silently
+// writes to the wrong rows. Row IDs are usually small (e.g. segment IDs) and
+// column IDs large (e.g. user IDs), so bounding each catches most swaps.
+// Rows and columns which use keys are not checked, since their IDs are
+// assigned by Pilosa.
+type SetValidation struct {
+	// Enabled turns the checks on. Setting row 0 of column 0 is rejected.
+	Enabled bool
+
+	// MaxRowID and MaxColumnID reject row and column IDs above them. Zero
+	// means no limit.
+	MaxRowID    uint64
+	MaxColumnID uint64
+}
+
+// check returns an error if a bit fails the validation checks.
+func (v SetValidation) check(idx *Index, f *Field, rowID, columnID uint64) error {
+	if !v.Enabled {
+		return nil
+	}
+	rowChecked, columnChecked := !f.keys(), !idx.Keys()
+	if rowChecked && columnChecked && rowID == 0 && columnID == 0 {
+		return errors.New("set validation: row and column are both zero")
+	}
+	if rowChecked && v.MaxRowID > 0 && rowID > v.MaxRowID {
+		return fmt.Errorf("set validation: row %d of field %q exceeds the maximum row ID of %d; are the row and column swapped?", rowID, f.Name(), v.MaxRowID)
+	}
+	if columnChecked && v.MaxColumnID > 0 && columnID > v.MaxColumnID {
+		return fmt.Errorf("set validation: column %d exceeds the maximum column ID of %d; are the row and column swapped?", columnID, v.MaxColumnID)
+	}
+	return nil
+}
+
 // executeSetBitField executes a Set() call for a specific field.
 func (e *executor) executeSetBitField(ctx context.Context, index string, c *pql.Call, f *Field, colID, rowID uint64, timestamp *time.Time, opt *execOptions) (bool, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeSetBitField")
@@ -2640,6 +4650,16 @@ func (e *executor) translateCall(index string, idx *Index, c *pql.Call) error {
 		fieldName = callArgString(c, "_field")
 		rowKey = "previous"
 		colKey = "column"
+	case "Contains":
+		colKey = "column"
+	case "ContainsMany":
+		if err := e.translateColumnKeys(idx, c, "columns"); err != nil {
+			return errors.Wrap(err, "translating ContainsMany")
+		}
+	case "OverlapMany":
+		if err := e.translateRowKeys(idx, c, callArgString(c, "field"), "rows"); err != nil {
+			return errors.Wrap(err, "translating OverlapMany")
+		}
 	case "GroupBy":
 		return errors.Wrap(e.translateGroupByCall(index, idx, c), "translating GroupBy")
 	default:
@@ -2718,6 +4738,77 @@ func (e *executor) translateCall(index string, idx *Index, c *pql.Call) error {
 	return nil
 }
 
+// translateRowKeys translates the list of row keys in the key argument of c
+// to IDs of the named field.
+func (e *executor) translateRowKeys(idx *Index, c *pql.Call, fieldName, key string) error {
+	field := idx.Field(fieldName)
+	if field == nil {
+		// As in translateCall, a missing field raises an error downstream.
+		return nil
+	}
+	values, ok := c.Args[key].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	if !field.keys() {
+		for _, v := range values {
+			if isString(v) {
+				return errors.Errorf("string '%s' value not allowed unless field 'keys' option enabled", key)
+			}
+		}
+		return nil
+	}
+
+	ids := make([]uint64, len(values))
+	for i, v := range values {
+		value, ok := v.(string)
+		if !ok {
+			return errors.Errorf("%s values must be strings when field 'keys' option enabled", key)
+		}
+		id, err := field.translateStore.TranslateKey(value)
+		if err != nil {
+			return errors.Wrapf(err, "translating row key '%s'", value)
+		}
+		ids[i] = id
+	}
+	c.Args[key] = ids
+	return nil
+}
+
+// translateColumnKeys translates the list of column keys in the key argument
+// of c to column IDs.
+func (e *executor) translateColumnKeys(idx *Index, c *pql.Call, key string) error {
+	values, ok := c.Args[key].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	if !idx.Keys() {
+		for _, v := range values {
+			if isString(v) {
+				return errors.Errorf("string '%s' value not allowed unless index 'keys' option enabled", key)
+			}
+		}
+		return nil
+	}
+
+	ids := make([]uint64, len(values))
+	for i, v := range values {
+		value, ok := v.(string)
+		if !ok {
+			return errors.Errorf("%s values must be strings when index 'keys' option enabled", key)
+		}
+		id, err := idx.translateStore.TranslateKey(value)
+		if err != nil {
+			return errors.Wrapf(err, "translating column key '%s'", value)
+		}
+		ids[i] = id
+	}
+	c.Args[key] = ids
+	return nil
+}
+
 func (e *executor) translateGroupByCall(index string, idx *Index, c *pql.Call) error {
 	if c.Name != "GroupBy" {
 		panic("translateGroupByCall called with '" + c.Name + "'")
@@ -2833,7 +4924,26 @@ func (e *executor) translateResult(index string, idx *Index, call *pql.Call, res
 		}
 
 	case []Pair:
-		if fieldName := callArgString(call, "_field"); fieldName != "" {
+		// TopColumns() pairs hold column IDs rather than row IDs.
+		if call.Name == "TopColumns" {
+			if idx.Keys() {
+				other := make([]Pair, len(result))
+				for i := range result {
+					key, err := idx.translateStore.TranslateID(result[i].ID)
+					if err != nil {
+						return nil, err
+					}
+					other[i] = Pair{Key: key, Count: result[i].Count}
+				}
+				return other, nil
+			}
+			return result, nil
+		}
+		fieldName := callArgString(call, "_field")
+		if call.Name == "OverlapMany" {
+			fieldName = callArgString(call, "field")
+		}
+		if fieldName != "" {
 			field := idx.Field(fieldName)
 			if field == nil {
 				return nil, fmt.Errorf("field %q not found", fieldName)
@@ -2851,6 +4961,38 @@ func (e *executor) translateResult(index string, idx *Index, call *pql.Call, res
 			}
 		}
 
+	case []ColumnTime:
+		if idx.Keys() {
+			other := make([]ColumnTime, len(result))
+			for i := range result {
+				key, err := idx.translateStore.TranslateID(result[i].Column)
+				if err != nil {
+					return nil, err
+				}
+				other[i] = ColumnTime{Key: key, Time: result[i].Time}
+			}
+			return other, nil
+		}
+
+	case []RowTime:
+		if fieldName := callArgString(call, "field"); fieldName != "" {
+			field := idx.Field(fieldName)
+			if field == nil {
+				return nil, fmt.Errorf("field %q not found", fieldName)
+			}
+			if field.keys() {
+				other := make([]RowTime, len(result))
+				for i := range result {
+					key, err := field.translateStore.TranslateID(result[i].Row)
+					if err != nil {
+						return nil, err
+					}
+					other[i] = RowTime{Key: key, Time: result[i].Time}
+				}
+				return other, nil
+			}
+		}
+
 	case []GroupCount:
 		other := make([]GroupCount, 0)
 		for _, gl := range result {
@@ -2962,6 +5104,27 @@ type execOptions struct {
 	ExcludeRowAttrs bool
 	ExcludeColumns  bool
 	ColumnAttrs     bool
+
+	// NoTimeout runs the query without the executor's QueryTimeout.
+	NoTimeout bool
+
+	// IncludeTotal returns the number of rows overlapping the filter of the
+	// query's TopN() call along with its results.
+	IncludeTotal bool
+}
+
+// topNCallN returns the number of TopN() calls in calls, including those
+// wrapped in Options().
+func topNCallN(calls []*pql.Call) int {
+	var n int
+	for _, call := range calls {
+		if call.Name == "Options" {
+			n += topNCallN(call.Children)
+		} else if call.Name == "TopN" {
+			n++
+		}
+	}
+	return n
 }
 
 // hasOnlySetRowAttrs returns true if calls only contains SetRowAttrs() calls.
@@ -3002,6 +5165,119 @@ type ValCount struct {
 	Count int64 `json:"count"`
 }
 
+// PercentileCount represents a single percentile returned by a
+// RowCountPercentile() call.
+type PercentileCount struct {
+	Percentile uint64 `json:"percentile"`
+	Count      uint64 `json:"count"`
+}
+
+// BucketCount represents the count of a single time bucket returned by a
+// CumulativeCount() call.
+type BucketCount struct {
+	Time  time.Time `json:"time"`
+	Count uint64    `json:"count"`
+}
+
+// BucketDelta represents the result of a BucketDelta() call comparing the
+// columns of a row in two time buckets. The column lists are only returned
+// when requested.
+type BucketDelta struct {
+	Added          uint64   `json:"added"`
+	Removed        uint64   `json:"removed"`
+	AddedColumns   []uint64 `json:"added_columns,omitempty"`
+	RemovedColumns []uint64 `json:"removed_columns,omitempty"`
+}
+
+// add returns the sum of d and other, keeping the lowest limit columns of
+// each list.
+func (d BucketDelta) add(other BucketDelta, limit int) BucketDelta {
+	merge := func(a, b []uint64) []uint64 {
+		merged := append(append([]uint64(nil), a...), b...)
+		sort.Slice(merged, func(i, j int) bool { return merged[i] < merged[j] })
+		if len(merged) > limit {
+			merged = merged[:limit]
+		}
+		if len(merged) == 0 {
+			return nil
+		}
+		return merged
+	}
+	return BucketDelta{
+		Added:          d.Added + other.Added,
+		Removed:        d.Removed + other.Removed,
+		AddedColumns:   merge(d.AddedColumns, other.AddedColumns),
+		RemovedColumns: merge(d.RemovedColumns, other.RemovedColumns),
+	}
+}
+
+// TopNTotal represents the result of a TopN() call which also collects the
+// rows of its field which overlap its filter, so that the coordinating node
+// can count them across shards for the query's total.
+type TopNTotal struct {
+	Pairs []Pair
+	Rows  []uint64
+}
+
+// OverlapCount represents the result of an Overlap() call comparing two rows.
+type OverlapCount struct {
+	AOnly  uint64 `json:"a_only"`
+	BOnly  uint64 `json:"b_only"`
+	Both   uint64 `json:"both"`
+	ATotal uint64 `json:"a_total"`
+	BTotal uint64 `json:"b_total"`
+}
+
+func (oc OverlapCount) add(other OverlapCount) OverlapCount {
+	return OverlapCount{
+		AOnly:  oc.AOnly + other.AOnly,
+		BOnly:  oc.BOnly + other.BOnly,
+		Both:   oc.Both + other.Both,
+		ATotal: oc.ATotal + other.ATotal,
+		BTotal: oc.BTotal + other.BTotal,
+	}
+}
+
+// ColumnTime represents a column and the start of the time bucket returned for
+// it by a FirstSeen() call.
+type ColumnTime struct {
+	Column uint64    `json:"column"`
+	Key    string    `json:"key,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// RowTime represents a row and the start of its most recent time bucket
+// returned by a Recent() call.
+type RowTime struct {
+	Row  uint64    `json:"row"`
+	Key  string    `json:"key,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// VennCount represents the result of a Venn() call comparing three rows. Each
+// field counts the columns in exactly the named rows.
+type VennCount struct {
+	AOnly uint64 `json:"a_only"`
+	BOnly uint64 `json:"b_only"`
+	COnly uint64 `json:"c_only"`
+	AB    uint64 `json:"ab"`
+	AC    uint64 `json:"ac"`
+	BC    uint64 `json:"bc"`
+	ABC   uint64 `json:"abc"`
+}
+
+func (vc VennCount) add(other VennCount) VennCount {
+	return VennCount{
+		AOnly: vc.AOnly + other.AOnly,
+		BOnly: vc.BOnly + other.BOnly,
+		COnly: vc.COnly + other.COnly,
+		AB:    vc.AB + other.AB,
+		AC:    vc.AC + other.AC,
+		BC:    vc.BC + other.BC,
+		ABC:   vc.ABC + other.ABC,
+	}
+}
+
 func (vc *ValCount) add(other ValCount) ValCount {
 	return ValCount{
 		Val:   vc.Val + other.Val,
@@ -20,6 +20,7 @@ import (
 	"io/ioutil"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/pilosa/pilosa/v2/pql"
 )
@@ -222,3 +223,19 @@ func TestFieldRowMarshalJSON(t *testing.T) {
 		t.Fatalf("unexpected json: %s", b)
 	}
 }
+
+// Ensure expired row counts are removed from the cache.
+func TestExecutor_RowCountsCache(t *testing.T) {
+	e := newExecutor()
+	defer e.Close()
+	e.cacheRowCounts(rowCountsKey("i", "f", []uint64{0, 1}), []uint64{1, 2})
+	e.rowCountsCache[rowCountsKey("i", "g", nil)] = rowCountsEntry{counts: []uint64{3}, expires: time.Now().Add(-time.Second)}
+
+	if counts, ok := e.cachedRowCounts("i/f/0,1"); !ok || len(counts) != 2 {
+		t.Fatalf("unexpected cached counts: %v, %v", counts, ok)
+	} else if _, ok := e.cachedRowCounts("i/f/0"); ok {
+		t.Fatal("expected no counts for other shards")
+	} else if _, ok := e.rowCountsCache["i/g"]; ok {
+		t.Fatal("expected expired entry to be removed")
+	}
+}
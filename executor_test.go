@@ -36,6 +36,7 @@ import (
 	"github.com/pilosa/pilosa/v2/http"
 	"github.com/pilosa/pilosa/v2/server"
 	"github.com/pilosa/pilosa/v2/test"
+	"github.com/pilosa/pilosa/v2/toml"
 	"github.com/pkg/errors"
 )
 
@@ -198,6 +199,50 @@ func TestExecutor_Execute_Difference(t *testing.T) {
 			t.Fatalf("unexpected keys: %+v", keys)
 		}
 	})
+
+	t.Run("Disjoint", func(t *testing.T) {
+		c := test.MustRunCluster(t, 1)
+		defer c.Close()
+		hldr := test.Holder{Holder: c[0].Server.Holder()}
+		hldr.SetBit("i", "general", 10, 1)
+		hldr.SetBit("i", "general", 10, pilosa.ShardWidth+2)
+		hldr.SetBit("i", "general", 11, 3)
+
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Difference(Row(general=10), Row(general=11))`}); err != nil {
+			t.Fatal(err)
+		} else if columns := res.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(columns, []uint64{1, pilosa.ShardWidth + 2}) {
+			t.Fatalf("unexpected columns: %+v", columns)
+		}
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		c := test.MustRunCluster(t, 1)
+		defer c.Close()
+		hldr := test.Holder{Holder: c[0].Server.Holder()}
+		hldr.SetBit("i", "general", 10, 1)
+		hldr.SetBit("i", "general", 10, 2)
+		hldr.SetBit("i", "general", 10, pilosa.ShardWidth+3)
+		hldr.SetBit("i", "general", 11, 2)
+		hldr.SetBit("i", "f", 20, 1)
+		hldr.SetBit("i", "f", 20, pilosa.ShardWidth+3)
+		hldr.SetBit("i", "f", 21, 1)
+		hldr.SetBit("i", "f", 21, 2)
+		if err := c[0].API.RecalculateCaches(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Count(Difference(Row(general=10), Row(general=11)))`}); err != nil {
+			t.Fatal(err)
+		} else if n := res.Results[0]; n != uint64(2) {
+			t.Fatalf("unexpected count: %v", n)
+		}
+
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `TopN(f, Difference(Row(general=10), Row(general=11)))`}); err != nil {
+			t.Fatal(err)
+		} else if pairs := res.Results[0].([]pilosa.Pair); !reflect.DeepEqual(pairs, []pilosa.Pair{{ID: 20, Count: 2}, {ID: 21, Count: 1}}) {
+			t.Fatalf("unexpected pairs: %+v", pairs)
+		}
+	})
 }
 
 // Ensure an empty difference query behaves properly.
@@ -313,6 +358,26 @@ func TestExecutor_Execute_Union(t *testing.T) {
 		}
 	})
 
+	t.Run("CountAcrossFields", func(t *testing.T) {
+		c := test.MustRunCluster(t, 1)
+		defer c.Close()
+		hldr := test.Holder{Holder: c[0].Server.Holder()}
+		hldr.SetBit("i", "f", 10, 0)
+		hldr.SetBit("i", "f", 10, 1)
+		hldr.SetBit("i", "f", 10, ShardWidth+1)
+		hldr.SetBit("i", "f", 11, 2)
+
+		hldr.SetBit("i", "g", 20, 1)
+		hldr.SetBit("i", "g", 20, ShardWidth+1)
+		hldr.SetBit("i", "g", 20, ShardWidth+2)
+
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Count(Union(Row(f=10), Row(f=11), Row(g=20)))`}); err != nil {
+			t.Fatal(err)
+		} else if n := res.Results[0].(uint64); n != 5 {
+			t.Fatalf("unexpected count: %d", n)
+		}
+	})
+
 	t.Run("RowIDColumnKey", func(t *testing.T) {
 		writeQuery := `
 			Set("one", f=10)
@@ -446,6 +511,90 @@ func TestExecutor_Execute_Xor(t *testing.T) {
 			t.Fatalf("unexpected keys: %+v", keys)
 		}
 	})
+	t.Run("Identical", func(t *testing.T) {
+		c := test.MustRunCluster(t, 1)
+		defer c.Close()
+		hldr := test.Holder{Holder: c[0].Server.Holder()}
+		hldr.SetBit("i", "general", 10, 1)
+		hldr.SetBit("i", "general", 10, ShardWidth+2)
+		hldr.SetBit("i", "general", 11, 1)
+		hldr.SetBit("i", "general", 11, ShardWidth+2)
+
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Xor(Row(general=10), Row(general=11)) Count(Xor(Row(general=10), Row(general=11)))`}); err != nil {
+			t.Fatal(err)
+		} else if columns := res.Results[0].(*pilosa.Row).Columns(); len(columns) != 0 {
+			t.Fatalf("unexpected columns: %+v", columns)
+		} else if n := res.Results[1]; n != uint64(0) {
+			t.Fatalf("unexpected count: %v", n)
+		}
+	})
+
+	t.Run("Disjoint", func(t *testing.T) {
+		c := test.MustRunCluster(t, 1)
+		defer c.Close()
+		hldr := test.Holder{Holder: c[0].Server.Holder()}
+		hldr.SetBit("i", "general", 10, 1)
+		hldr.SetBit("i", "general", 10, ShardWidth+2)
+		hldr.SetBit("i", "general", 11, 3)
+
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Xor(Row(general=10), Row(general=11)) Union(Row(general=10), Row(general=11)) Count(Xor(Row(general=10), Row(general=11)))`}); err != nil {
+			t.Fatal(err)
+		} else if columns, union := res.Results[0].(*pilosa.Row).Columns(), res.Results[1].(*pilosa.Row).Columns(); !reflect.DeepEqual(columns, union) || !reflect.DeepEqual(columns, []uint64{1, 3, ShardWidth + 2}) {
+			t.Fatalf("unexpected columns: %+v, union: %+v", columns, union)
+		} else if n := res.Results[2]; n != uint64(3) {
+			t.Fatalf("unexpected count: %v", n)
+		}
+	})
+}
+
+// Ensure an AtLeast query can be executed.
+func TestExecutor_Execute_AtLeast(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	hldr := test.Holder{Holder: c[0].Server.Holder()}
+
+	// Column 0 is in all three rows, columns 1, 2, and ShardWidth+1 are in
+	// two, and columns 3, 4, 5, and ShardWidth+2 are in one.
+	hldr.SetBit("i", "general", 10, 0)
+	hldr.SetBit("i", "general", 10, 1)
+	hldr.SetBit("i", "general", 10, 2)
+	hldr.SetBit("i", "general", 10, 3)
+	hldr.SetBit("i", "general", 10, ShardWidth+1)
+	hldr.SetBit("i", "general", 11, 0)
+	hldr.SetBit("i", "general", 11, 1)
+	hldr.SetBit("i", "general", 11, 4)
+	hldr.SetBit("i", "general", 11, ShardWidth+1)
+	hldr.SetBit("i", "general", 12, 0)
+	hldr.SetBit("i", "general", 12, 2)
+	hldr.SetBit("i", "general", 12, 5)
+	hldr.SetBit("i", "general", 12, ShardWidth+2)
+
+	for _, tt := range []struct {
+		k   int
+		exp []uint64
+	}{
+		{k: 1, exp: []uint64{0, 1, 2, 3, 4, 5, ShardWidth + 1, ShardWidth + 2}},
+		{k: 2, exp: []uint64{0, 1, 2, ShardWidth + 1}},
+		{k: 3, exp: []uint64{0}},
+		{k: 4, exp: []uint64{}},
+	} {
+		q := fmt.Sprintf(`AtLeast(Row(general=10), Row(general=11), Row(general=12), k=%d)`, tt.k)
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: q}); err != nil {
+			t.Fatal(err)
+		} else if columns := res.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(columns, tt.exp) {
+			t.Fatalf("unexpected columns for k=%d: %+v", tt.k, columns)
+		}
+	}
+
+	if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Count(AtLeast(Row(general=10), Row(general=11), Row(general=12), k=2))`}); err != nil {
+		t.Fatal(err)
+	} else if res.Results[0] != uint64(4) {
+		t.Fatalf("unexpected count: %d", res.Results[0])
+	}
+
+	if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `AtLeast(Row(general=10), Row(general=11))`}); err == nil || !strings.Contains(err.Error(), "k must be a positive integer") {
+		t.Fatalf("expected k error, got: %v", err)
+	}
 }
 
 // Ensure a count query can be executed.
@@ -466,6 +615,37 @@ func TestExecutor_Execute_Count(t *testing.T) {
 		}
 	})
 
+	t.Run("Union", func(t *testing.T) {
+		c := test.MustRunCluster(t, 1)
+		defer c.Close()
+		hldr := test.Holder{Holder: c[0].Server.Holder()}
+
+		hldr.SetBit("i", "f", 10, 3)
+		hldr.SetBit("i", "f", 10, ShardWidth+1)
+		hldr.SetBit("i", "f", 11, 3)
+		hldr.SetBit("i", "f", 11, 2*ShardWidth)
+
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Count(Union(Row(f=10), Row(f=11)))`}); err != nil {
+			t.Fatal(err)
+		} else if res.Results[0] != uint64(3) {
+			t.Fatalf("unexpected n: %d", res.Results[0])
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		c := test.MustRunCluster(t, 1)
+		defer c.Close()
+		hldr := test.Holder{Holder: c[0].Server.Holder()}
+
+		hldr.SetBit("i", "f", 10, 3)
+
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Count(Row(f=11)) Count(Intersect(Row(f=10), Row(f=11)))`}); err != nil {
+			t.Fatal(err)
+		} else if res.Results[0] != uint64(0) || res.Results[1] != uint64(0) {
+			t.Fatalf("unexpected n: %v", res.Results)
+		}
+	})
+
 	t.Run("RowIDColumnKey", func(t *testing.T) {
 		writeQuery := `
 			Set("three", f=10)
@@ -506,6 +686,409 @@ func TestExecutor_Execute_Count(t *testing.T) {
 		}
 	})
 
+	t.Run("Rows", func(t *testing.T) {
+		c := test.MustRunCluster(t, 1)
+		defer c.Close()
+		hldr := test.Holder{Holder: c[0].Server.Holder()}
+
+		hldr.SetBit("i", "f", 1, ShardWidth+3)
+		hldr.SetBit("i", "f", 5, ShardWidth+3)
+		hldr.SetBit("i", "f", 9, ShardWidth+3)
+		hldr.SetBit("i", "f", 9, 4)
+		hldr.SetBit("i", "f", 10, 4)
+
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`Count(Rows(f, column=%d))`, ShardWidth+3)}); err != nil {
+			t.Fatal(err)
+		} else if res.Results[0] != uint64(3) {
+			t.Fatalf("unexpected n: %d", res.Results[0])
+		}
+
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Count(Rows(f))`}); err != nil {
+			t.Fatal(err)
+		} else if res.Results[0] != uint64(4) {
+			t.Fatalf("unexpected n: %d", res.Results[0])
+		}
+	})
+}
+
+// Ensure an overlap query can be executed.
+func TestExecutor_Execute_Overlap(t *testing.T) {
+	t.Run("RowIDColumnID", func(t *testing.T) {
+		c := test.MustRunCluster(t, 1)
+		defer c.Close()
+		hldr := test.Holder{Holder: c[0].Server.Holder()}
+
+		hldr.MustSetBits("i", "f", 10, 1, 2, 3, ShardWidth+1, ShardWidth+2)
+		hldr.MustSetBits("i", "f", 11, 2, 3, 4, ShardWidth+2)
+
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Overlap(Row(f=10), Row(f=11))`}); err != nil {
+			t.Fatal(err)
+		} else if oc := res.Results[0].(pilosa.OverlapCount); oc != (pilosa.OverlapCount{AOnly: 2, BOnly: 1, Both: 3, ATotal: 5, BTotal: 4}) {
+			t.Fatalf("unexpected overlap: %+v", oc)
+		}
+	})
+
+	t.Run("RowKeyColumnKey", func(t *testing.T) {
+		writeQuery := `
+			Set("one", f="ten")
+			Set("two", f="ten")
+			Set("two", f="eleven")
+			Set("three", f="eleven")
+			Set("four", f="eleven")`
+		readQueries := []string{`Overlap(Row(f="ten"), Row(f="eleven"))`}
+		responses := runCallTest(t, writeQuery, readQueries,
+			&pilosa.IndexOptions{Keys: true},
+			pilosa.OptFieldKeys())
+		if oc := responses[0].Results[0].(pilosa.OverlapCount); oc != (pilosa.OverlapCount{AOnly: 1, BOnly: 2, Both: 1, ATotal: 2, BTotal: 3}) {
+			t.Fatalf("unexpected overlap: %+v", oc)
+		}
+	})
+
+	// Per-shard counts from every node are merged.
+	t.Run("Cluster", func(t *testing.T) {
+		c := test.MustRunCluster(t, 3)
+		defer c.Close()
+		c.CreateField(t, "i", pilosa.IndexOptions{}, "f")
+
+		var sets strings.Builder
+		for shard := uint64(0); shard < 10; shard++ {
+			fmt.Fprintf(&sets, "Set(%d, f=10) Set(%d, f=10) Set(%d, f=11)", shard*ShardWidth, shard*ShardWidth+1, shard*ShardWidth+1)
+		}
+		c.Query(t, "i", sets.String())
+
+		if oc := c.Query(t, "i", `Overlap(Row(f=10), Row(f=11))`).Results[0].(pilosa.OverlapCount); oc != (pilosa.OverlapCount{AOnly: 10, BOnly: 0, Both: 10, ATotal: 20, BTotal: 10}) {
+			t.Fatalf("unexpected overlap: %+v", oc)
+		}
+	})
+
+	t.Run("ErrInputs", func(t *testing.T) {
+		c := test.MustRunCluster(t, 1)
+		defer c.Close()
+		hldr := test.Holder{Holder: c[0].Server.Holder()}
+		hldr.SetBit("i", "f", 10, 1)
+
+		if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Overlap(Row(f=10))`}); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+// Ensure an OverlapMany query can be executed.
+func TestExecutor_Execute_OverlapMany(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	hldr := test.Holder{Holder: c[0].Server.Holder()}
+
+	hldr.MustSetBits("i", "audience", 1, 1, 2, 3, 4, ShardWidth+1, ShardWidth+2)
+	hldr.MustSetBits("i", "segment", 10, 1, 2, ShardWidth+1)
+	hldr.MustSetBits("i", "segment", 11, 4, 5, 6)
+	hldr.MustSetBits("i", "segment", 12, 7, ShardWidth+3)
+	hldr.MustSetBits("i", "segment", 13, 1, 2, 3, 4, ShardWidth+1, ShardWidth+2, ShardWidth+3)
+
+	if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `OverlapMany(Row(audience=1), field=segment, rows=[13, 10, 11, 12, 14, 10])`}); err != nil {
+		t.Fatal(err)
+	} else if pairs := res.Results[0].([]pilosa.Pair); !reflect.DeepEqual(pairs, []pilosa.Pair{
+		{ID: 13, Count: 6},
+		{ID: 10, Count: 3},
+		{ID: 11, Count: 1},
+		{ID: 12, Count: 0},
+		{ID: 14, Count: 0},
+	}) {
+		t.Fatalf("unexpected pairs: %+v", pairs)
+	}
+
+	if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `OverlapMany(Row(audience=1), field=segment)`}); err == nil {
+		t.Fatal("expected error without rows")
+	} else if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `OverlapMany(Row(audience=1), field=segment, rows=["a"])`}); err == nil {
+		t.Fatal("expected error for row keys on a field without keys")
+	}
+
+	// Row keys are translated to IDs, and the results back to keys.
+	responses := runCallTest(t, `
+		Set("one", f="audience")
+		Set("two", f="audience")
+		Set("three", f="audience")
+		Set("one", f="a")
+		Set("two", f="a")
+		Set("three", f="b")
+		Set("four", f="b")`,
+		[]string{`OverlapMany(Row(f="audience"), field=f, rows=["b", "a", "c"])`},
+		&pilosa.IndexOptions{Keys: true},
+		pilosa.OptFieldKeys())
+	if pairs := responses[0].Results[0].([]pilosa.Pair); !reflect.DeepEqual(pairs, []pilosa.Pair{
+		{Key: "b", Count: 1},
+		{Key: "a", Count: 2},
+		{Key: "c", Count: 0},
+	}) {
+		t.Fatalf("unexpected keyed pairs: %+v", pairs)
+	}
+}
+
+// Ensure a Venn query can be executed.
+func TestExecutor_Execute_Venn(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	hldr := test.Holder{Holder: c[0].Server.Holder()}
+
+	hldr.MustSetBits("i", "f", 10, 1, 2, 3, 4, 5, ShardWidth+1)
+	hldr.MustSetBits("i", "f", 11, 3, 4, 6, 7, ShardWidth+1)
+	hldr.MustSetBits("i", "f", 12, 4, 5, 7, 8, ShardWidth+2)
+
+	res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `
+		Venn(Row(f=10), Row(f=11), Row(f=12))
+		Count(Union(Row(f=10), Row(f=11), Row(f=12)))`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vc := res.Results[0].(pilosa.VennCount)
+	if vc != (pilosa.VennCount{AOnly: 2, BOnly: 1, COnly: 2, AB: 2, AC: 1, BC: 1, ABC: 1}) {
+		t.Fatalf("unexpected venn: %+v", vc)
+	}
+	if sum := vc.AOnly + vc.BOnly + vc.COnly + vc.AB + vc.AC + vc.BC + vc.ABC; sum != res.Results[1] {
+		t.Fatalf("regions sum to %d, union count is %d", sum, res.Results[1])
+	}
+
+	if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Venn(Row(f=10), Row(f=11))`}); err == nil || !strings.Contains(err.Error(), "exactly three bitmap inputs") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure a TopColumns query can be executed.
+func TestExecutor_Execute_TopColumns(t *testing.T) {
+	t.Run("RowIDColumnID", func(t *testing.T) {
+		c := test.MustRunCluster(t, 1)
+		defer c.Close()
+		c[0].MustCreateIndex(t, "i", pilosa.IndexOptions{})
+		c[0].MustCreateField(t, "i", "f")
+
+		c[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`
+			Set(1, f=10) Set(1, f=11) Set(1, f=12) Set(1, f=13) Set(1, f=14)
+			Set(2, f=10) Set(2, f=11) Set(2, f=12)
+			Set(%d, f=10) Set(%d, f=11) Set(%d, f=12) Set(%d, f=13)
+			Set(3, f=14)`, ShardWidth+1, ShardWidth+1, ShardWidth+1, ShardWidth+1)})
+
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `TopColumns(field=f, n=3)`}); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(res.Results[0], []pilosa.Pair{
+			{ID: 1, Count: 5},
+			{ID: ShardWidth + 1, Count: 4},
+			{ID: 2, Count: 3},
+		}) {
+			t.Fatalf("unexpected result: %+v", res.Results[0])
+		}
+
+		if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `TopColumns(field=f, n=100000)`}); err == nil {
+			t.Fatal("expected error for n above the limit")
+		}
+	})
+
+	t.Run("ColumnKey", func(t *testing.T) {
+		writeQuery := `
+			Set("one", f=10)
+			Set("two", f=10)
+			Set("two", f=11)`
+		readQueries := []string{`TopColumns(field=f, n=1)`}
+		responses := runCallTest(t, writeQuery, readQueries, &pilosa.IndexOptions{Keys: true})
+		if !reflect.DeepEqual(responses[0].Results[0], []pilosa.Pair{{Key: "two", Count: 2}}) {
+			t.Fatalf("unexpected result: %+v", responses[0].Results[0])
+		}
+	})
+}
+
+// Ensure calls which find nothing return empty values rather than null.
+func TestExecutor_Execute_EmptyResults(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	c[0].MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	c[0].MustCreateField(t, "i", "f")
+	c[0].MustCreateField(t, "i", "t", pilosa.OptFieldTypeTime(pilosa.TimeQuantum("YMD")))
+
+	for query, exp := range map[string]string{
+		`TopN(f)`:                  `[]`,
+		`TopColumns(field=f, n=1)`: `[]`,
+		`Rows(f)`:                  `{"rows":[]}`,
+		`GroupBy(Rows(f))`:         `[]`,
+		`Row(f=1)`:                 `{"attrs":{},"columns":[]}`,
+		`Count(Row(f=1))`:          `0`,
+		`FirstSeen(Row(t=1), from=2019-01-01T00:00, to=2019-01-02T00:00)`: `[]`,
+	} {
+		if res, err := c[0].Query("i", "", query); err != nil {
+			t.Fatal(err)
+		} else if res != `{"results":[`+exp+`]}`+"\n" {
+			t.Fatalf("unexpected result for %s: %s", query, res)
+		}
+	}
+}
+
+// Ensure a ShareAny query can be executed.
+func TestExecutor_Execute_ShareAny(t *testing.T) {
+	t.Run("RowIDColumnID", func(t *testing.T) {
+		writeQuery := fmt.Sprintf(`
+			Set(1, f=10)
+			Set(1, f=11)
+			Set(%d, f=11)
+			Set(%d, f=12)
+			Set(3, f=12)`, ShardWidth+2, ShardWidth+2)
+		readQueries := []string{
+			fmt.Sprintf(`ShareAny(Rows(f, column=1), Rows(f, column=%d))`, ShardWidth+2),
+			`ShareAny(Rows(f, column=1), Rows(f, column=3))`,
+			`ShareAny(Rows(f, column=1), Rows(f, column=4))`,
+		}
+		responses := runCallTest(t, writeQuery, readQueries, nil)
+		for i, exp := range []bool{true, false, false} {
+			if responses[i].Results[0] != exp {
+				t.Fatalf("unexpected result for %s: %v", readQueries[i], responses[i].Results[0])
+			}
+		}
+	})
+
+	t.Run("RowKeyColumnKey", func(t *testing.T) {
+		writeQuery := `
+			Set("one", f="ten")
+			Set("two", f="ten")
+			Set("three", f="eleven")`
+		readQueries := []string{
+			`ShareAny(Rows(f, column="one"), Rows(f, column="two"))`,
+			`ShareAny(Rows(f, column="one"), Rows(f, column="three"))`,
+		}
+		responses := runCallTest(t, writeQuery, readQueries,
+			&pilosa.IndexOptions{Keys: true},
+			pilosa.OptFieldKeys())
+		for i, exp := range []bool{true, false} {
+			if responses[i].Results[0] != exp {
+				t.Fatalf("unexpected result for %s: %v", readQueries[i], responses[i].Results[0])
+			}
+		}
+	})
+}
+
+func TestExecutor_Execute_Contains(t *testing.T) {
+	writeQuery := fmt.Sprintf(`
+		Set(1, f=10)
+		Set(%d, f=10)
+		Set(2, f=11)`, ShardWidth+2)
+	readQueries := []string{
+		`Contains(Row(f=10), column=1)`,
+		fmt.Sprintf(`Contains(Row(f=10), column=%d)`, ShardWidth+2),
+		`Contains(Row(f=10), column=2)`,
+		fmt.Sprintf(`Contains(Row(f=10), column=%d)`, 3*ShardWidth),
+		`Contains(Union(Row(f=10), Row(f=11)), column=2)`,
+	}
+	responses := runCallTest(t, writeQuery, readQueries, nil)
+	for i, exp := range []bool{true, true, false, false, true} {
+		if responses[i].Results[0] != exp {
+			t.Fatalf("unexpected result for %s: %v", readQueries[i], responses[i].Results[0])
+		}
+	}
+
+	t.Run("FieldNotFound", func(t *testing.T) {
+		c := test.MustRunCluster(t, 1)
+		defer c.Close()
+		c[0].MustCreateIndex(t, "i", pilosa.IndexOptions{})
+		if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Contains(Row(nope=1), column=1)`}); errors.Cause(err) != pilosa.ErrFieldNotFound {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestExecutor_Execute_ContainsMany(t *testing.T) {
+	writeQuery := fmt.Sprintf(`
+		Set(1, f=10)
+		Set(3, f=10)
+		Set(%d, f=10)
+		Set(2, f=11)`, ShardWidth+2)
+	readQueries := []string{
+		fmt.Sprintf(`ContainsMany(Row(f=10), columns=[%d, 2, 3, %d, 1, 3])`, ShardWidth+2, 3*ShardWidth),
+		`ContainsMany(Row(f=12), columns=[1, 2])`,
+	}
+	responses := runCallTest(t, writeQuery, readQueries, nil)
+	for i, exp := range [][]bool{
+		{true, false, true, false, true, true},
+		{false, false},
+	} {
+		if !reflect.DeepEqual(responses[i].Results[0], exp) {
+			t.Fatalf("unexpected result for %s: %v", readQueries[i], responses[i].Results[0])
+		}
+	}
+
+	// Column keys are translated to IDs.
+	t.Run("Keys", func(t *testing.T) {
+		responses := runCallTest(t, `
+			Set("a", f=10)
+			Set("b", f=10)
+			Set("c", f=11)`,
+			[]string{`ContainsMany(Row(f=10), columns=["b", "c", "d", "a"])`},
+			&pilosa.IndexOptions{Keys: true})
+		if !reflect.DeepEqual(responses[0].Results[0], []bool{true, false, false, true}) {
+			t.Fatalf("unexpected keyed result: %v", responses[0].Results[0])
+		}
+	})
+
+	t.Run("ErrKeysWithoutKeyedIndex", func(t *testing.T) {
+		c := test.MustRunCluster(t, 1)
+		defer c.Close()
+		c[0].MustCreateIndex(t, "i", pilosa.IndexOptions{})
+		c[0].MustCreateField(t, "i", "f")
+		if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `ContainsMany(Row(f=10), columns=["a"])`}); err == nil || !strings.Contains(err.Error(), "keys") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
+// Ensure a RowCountPercentile query can be executed.
+func TestExecutor_Execute_RowCountPercentile(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	hldr := test.Holder{Holder: c[0].Server.Holder()}
+
+	// Row i has i bits set, split across two shards.
+	for rowID := uint64(1); rowID <= 10; rowID++ {
+		for i := uint64(0); i < rowID; i++ {
+			hldr.SetBit("i", "f", rowID, (i%2)*ShardWidth+i)
+		}
+	}
+
+	t.Run("Multiple", func(t *testing.T) {
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `RowCountPercentile(field=f, p=[0, 50, 90, 100])`}); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(res.Results[0], []pilosa.PercentileCount{
+			{Percentile: 0, Count: 1},
+			{Percentile: 50, Count: 5},
+			{Percentile: 90, Count: 9},
+			{Percentile: 100, Count: 10},
+		}) {
+			t.Fatalf("unexpected result: %+v", res.Results[0])
+		}
+	})
+
+	t.Run("Single", func(t *testing.T) {
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `RowCountPercentile(field=f, p=75)`}); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(res.Results[0], []pilosa.PercentileCount{{Percentile: 75, Count: 8}}) {
+			t.Fatalf("unexpected result: %+v", res.Results[0])
+		}
+	})
+
+	// Shard 0 holds half of each row's bits, rounded up. The counts of the
+	// whole field are cached by now, but don't apply to a single shard.
+	t.Run("Shards", func(t *testing.T) {
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `RowCountPercentile(field=f, p=100)`, Shards: []uint64{0}}); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(res.Results[0], []pilosa.PercentileCount{{Percentile: 100, Count: 5}}) {
+			t.Fatalf("unexpected result: %+v", res.Results[0])
+		}
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `RowCountPercentile(field=f, p=100)`}); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(res.Results[0], []pilosa.PercentileCount{{Percentile: 100, Count: 10}}) {
+			t.Fatalf("unexpected result: %+v", res.Results[0])
+		}
+	})
+
+	t.Run("ErrPercentile", func(t *testing.T) {
+		if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `RowCountPercentile(field=f, p=101)`}); err == nil {
+			t.Fatal("expected error")
+		}
+	})
 }
 
 // Ensure a set query can be executed.
@@ -1262,6 +1845,106 @@ func TestExecutor_Execute_TopN_Src(t *testing.T) {
 	}
 }
 
+// Ensure a TopN() query can return the number of rows overlapping its source
+// row, counted from every row rather than only those in the rank cache.
+func TestExecutor_Execute_TopN_Total(t *testing.T) {
+	c := test.MustRunCluster(t, 3)
+	defer c.Close()
+	c.CreateField(t, "i", pilosa.IndexOptions{}, "f", pilosa.OptFieldTypeSet(pilosa.CacheTypeRanked, 2))
+	c.CreateField(t, "i", pilosa.IndexOptions{}, "g")
+
+	// In each of 10 shards, row 50 and five rows of its own overlap the
+	// source row, and row 100 does not. That's more rows per fragment than
+	// the rank cache holds.
+	var sets strings.Builder
+	for shard := uint64(0); shard < 10; shard++ {
+		col := shard*ShardWidth + 1
+		fmt.Fprintf(&sets, "Set(%d, g=0) Set(%d, f=50) Set(%d, f=100)", col, col, col+1)
+		for k := uint64(0); k < 5; k++ {
+			fmt.Fprintf(&sets, " Set(%d, f=%d)", col, 1000+shard*5+k)
+		}
+	}
+	c.Query(t, "i", sets.String())
+	if err := c[0].RecalculateCaches(); err != nil {
+		t.Fatalf("recalculating caches: %v", err)
+	}
+
+	t.Run("Src", func(t *testing.T) {
+		res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `TopN(f, Row(g=0), n=1)`, IncludeTotal: true})
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(res.Results, []interface{}{[]pilosa.Pair{{ID: 50, Count: 10}}}) {
+			t.Fatalf("unexpected result: %s", spew.Sdump(res.Results))
+		} else if res.Total == nil || *res.Total != 51 {
+			t.Fatalf("unexpected total: %v", res.Total)
+		}
+	})
+
+	t.Run("NoSrc", func(t *testing.T) {
+		res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `TopN(f, n=1)`, IncludeTotal: true})
+		if err != nil {
+			t.Fatal(err)
+		} else if res.Total == nil || *res.Total != 52 {
+			t.Fatalf("unexpected total: %v", res.Total)
+		}
+	})
+
+	t.Run("NotRequested", func(t *testing.T) {
+		res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `TopN(f, Row(g=0), n=1, total=true)`})
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(res.Results, []interface{}{[]pilosa.Pair{{ID: 50, Count: 10}}}) {
+			t.Fatalf("unexpected result: %s", spew.Sdump(res.Results))
+		} else if res.Total != nil {
+			t.Fatalf("unexpected total: %d", *res.Total)
+		}
+	})
+
+	t.Run("ErrTopNCalls", func(t *testing.T) {
+		for _, q := range []string{`Count(Row(g=0))`, `TopN(f, n=1) TopN(f, n=2)`} {
+			if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: q, IncludeTotal: true}); err == nil || !strings.Contains(err.Error(), "exactly one TopN() call") {
+				t.Fatalf("%s: unexpected error: %v", q, err)
+			}
+		}
+	})
+}
+
+// Ensure TopN counts only the requested rows against its source row, as used
+// for facet counts.
+func TestExecutor_Execute_TopN_IDs(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	hldr := test.Holder{Holder: c[0].Server.Holder()}
+
+	// The base query is the union of two rows across two shards.
+	hldr.SetBit("i", "other", 100, 1)
+	hldr.SetBit("i", "other", 100, ShardWidth)
+	hldr.SetBit("i", "other", 200, ShardWidth+1)
+	hldr.SetBit("i", "other", 200, ShardWidth+2)
+
+	hldr.SetBit("i", "f", 0, 0)
+	hldr.SetBit("i", "f", 0, 1)
+	hldr.SetBit("i", "f", 0, ShardWidth)
+	hldr.SetBit("i", "f", 10, 5)
+	hldr.SetBit("i", "f", 10, ShardWidth+1)
+	for _, col := range []uint64{1, ShardWidth, ShardWidth + 1, ShardWidth + 2} {
+		hldr.SetBit("i", "f", 20, col)
+		hldr.SetBit("i", "f", 40, col)
+	}
+	hldr.SetBit("i", "f", 30, 7)
+
+	// Row 40 isn't requested and row 30 doesn't intersect the base query.
+	if result, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `TopN(f, Union(Row(other=100), Row(other=200)), ids=[0, 10, 20, 30])`}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(result.Results, []interface{}{[]pilosa.Pair{
+		{ID: 20, Count: 4},
+		{ID: 0, Count: 2},
+		{ID: 10, Count: 1},
+	}}) {
+		t.Fatalf("unexpected result: %s", spew.Sdump(result))
+	}
+}
+
 //Ensure TopN handles Attribute filters
 func TestExecutor_Execute_TopN_Attr(t *testing.T) {
 	c := test.MustRunCluster(t, 1)
@@ -1732,6 +2415,36 @@ func TestExecutor_Execute_Row_Range(t *testing.T) {
 		})
 	})
 
+	t.Run("DayBoundary", func(t *testing.T) {
+		c := test.MustRunCluster(t, 1)
+		defer c.Close()
+		hldr := test.Holder{Holder: c[0].Server.Holder()}
+
+		index := hldr.MustCreateIndexIfNotExists("i", pilosa.IndexOptions{})
+		if _, err := index.CreateField("f", pilosa.OptFieldTypeTime(pilosa.TimeQuantum("YMDH"))); err != nil {
+			t.Fatal(err)
+		} else if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `
+			Set(1, f=1, 2014-04-01T21:00)
+			Set(2, f=1, 2014-04-01T22:00)
+			Set(3, f=1, 2014-04-01T23:00)
+			Set(4, f=1, 2014-04-02T00:00)
+			Set(5, f=1, 2014-04-02T01:00)
+			Set(6, f=1, 2014-04-02T02:00)`}); err != nil {
+			t.Fatal(err)
+		}
+
+		// From is inclusive and to is exclusive.
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Row(f=1, from=2014-04-01T22:00, to=2014-04-02T02:00)`}); err != nil {
+			t.Fatal(err)
+		} else if columns := res.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(columns, []uint64{2, 3, 4, 5}) {
+			t.Fatalf("unexpected columns: %+v", columns)
+		}
+
+		if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Row(f=1, from=2014-04-02T02:00, to=2014-04-01T22:00)`}); err == nil || !strings.Contains(err.Error(), "from time 2014-04-02T02:00 is after to time 2014-04-01T22:00") {
+			t.Fatalf("expected from after to error, got: %v", err)
+		}
+	})
+
 	t.Run("RowIDColumnKey", func(t *testing.T) {
 		writeQuery := `
 		Set("two", f=1, 1999-12-31T00:00)
@@ -1870,6 +2583,355 @@ func TestExecutor_Execute_Row_Range(t *testing.T) {
 	})
 }
 
+// Ensure an IntersectRange query can be executed.
+func TestExecutor_Execute_IntersectRange(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	c[0].MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	c[0].MustCreateField(t, "i", "segment")
+	c[0].MustCreateField(t, "i", "activity", pilosa.OptFieldTypeTime(pilosa.TimeQuantum("YMD")))
+
+	c[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`
+		Set(1, segment=5)
+		Set(2, segment=5)
+		Set(3, segment=5)
+		Set(%d, segment=5)
+		Set(4, segment=6)
+
+		Set(1, activity=100, 2019-01-02T00:00)
+		Set(2, activity=101, 2019-01-05T00:00)
+		Set(3, activity=100, 2018-12-01T00:00)
+		Set(4, activity=100, 2019-01-03T00:00)
+		Set(%d, activity=102, 2019-01-06T00:00)`, ShardWidth+1, ShardWidth+1)})
+
+	if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `IntersectRange(Row(segment=5), field=activity, from=2019-01-01T00:00, to=2019-01-08T00:00)`}); err != nil {
+		t.Fatal(err)
+	} else if columns := res.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(columns, []uint64{1, 2, ShardWidth + 1}) {
+		t.Fatalf("unexpected columns: %+v", columns)
+	}
+
+	if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Count(IntersectRange(Row(segment=5), field=activity, from=2019-01-03T00:00, to=2019-01-08T00:00))`}); err != nil {
+		t.Fatal(err)
+	} else if res.Results[0] != uint64(2) {
+		t.Fatalf("unexpected count: %d", res.Results[0])
+	}
+
+	if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `IntersectRange(Row(segment=5), field=segment)`}); err == nil {
+		t.Fatal("expected error for field without time quantum")
+	}
+}
+
+// Ensure IntersectRange() without from or to, or with a distant one, only
+// reads the field's existing views.
+func TestExecutor_Execute_IntersectRange_OpenRange(t *testing.T) {
+	c := test.MustNewCluster(t, 1)
+	c[0].Config.MaxRangeBuckets = 100
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c[0].MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	c[0].MustCreateField(t, "i", "segment")
+	c[0].MustCreateField(t, "i", "activity", pilosa.OptFieldTypeTime(pilosa.TimeQuantum("H")))
+	c[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `
+		Set(1, segment=5)
+		Set(2, segment=5)
+		Set(3, segment=5)
+		Set(1, activity=100, 2019-01-01T00:00)
+		Set(2, activity=100, 2019-01-02T12:00)
+		Set(4, activity=100, 2019-01-02T12:00)`})
+
+	for _, tt := range []struct {
+		args string
+		exp  []uint64
+	}{
+		{args: ``, exp: []uint64{1, 2}},
+		{args: `, from=2019-01-02T00:00`, exp: []uint64{2}},
+		{args: `, to=2019-01-01T06:00`, exp: []uint64{1}},
+		{args: `, from=1970-01-01T00:00, to=2100-01-01T00:00`, exp: []uint64{1, 2}},
+	} {
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `IntersectRange(Row(segment=5), field=activity` + tt.args + `)`}); err != nil {
+			t.Fatalf("%q: %s", tt.args, err)
+		} else if columns := res.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(columns, tt.exp) {
+			t.Fatalf("%q: unexpected columns: %+v", tt.args, columns)
+		}
+	}
+}
+
+// Ensure a CumulativeCount query can be executed.
+func TestExecutor_Execute_CumulativeCount(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	c[0].MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	c[0].MustCreateField(t, "i", "activity", pilosa.OptFieldTypeTime(pilosa.TimeQuantum("YMD")))
+
+	c[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`
+		Set(1, activity=100, 2018-12-31T00:00)
+		Set(1, activity=100, 2019-01-01T00:00)
+		Set(2, activity=100, 2019-01-01T00:00)
+		Set(2, activity=100, 2019-01-03T00:00)
+		Set(3, activity=100, 2019-01-03T00:00)
+		Set(%d, activity=100, 2019-01-04T00:00)
+		Set(4, activity=101, 2019-01-02T00:00)`, ShardWidth+1)})
+
+	res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `CumulativeCount(Row(activity=100), from=2019-01-01T00:00, to=2019-01-05T00:00, granularity="D")`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buckets := res.Results[0].([]pilosa.BucketCount)
+	exp := []uint64{2, 2, 3, 4}
+	if len(buckets) != len(exp) {
+		t.Fatalf("unexpected bucket count: %d", len(buckets))
+	}
+	for i, b := range buckets {
+		if b.Count != exp[i] {
+			t.Fatalf("unexpected count for bucket %d: %d != %d", i, b.Count, exp[i])
+		} else if i > 0 && b.Count < buckets[i-1].Count {
+			t.Fatalf("counts are not non-decreasing: %+v", buckets)
+		} else if b.Time != time.Date(2019, 1, i+1, 0, 0, 0, 0, time.UTC) {
+			t.Fatalf("unexpected time for bucket %d: %s", i, b.Time)
+		}
+	}
+
+	if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `CumulativeCount(Row(activity=100), from=2019-01-05T00:00, to=2019-01-01T00:00)`}); err == nil {
+		t.Fatal("expected error for reversed range")
+	}
+	if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `CumulativeCount(Row(activity=100), from=2019-01-01T00:00, to=2019-01-05T00:00, granularity="W")`}); err == nil {
+		t.Fatal("expected error for invalid granularity")
+	}
+}
+
+// Ensure a SlidingCount query counts distinct columns within each window.
+func TestExecutor_Execute_SlidingCount(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	c[0].MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	c[0].MustCreateField(t, "i", "activity", pilosa.OptFieldTypeTime(pilosa.TimeQuantum("YMD")))
+
+	c[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`
+		Set(1, activity=100, 2018-12-30T00:00)
+		Set(2, activity=100, 2019-01-01T00:00)
+		Set(3, activity=100, 2019-01-03T00:00)
+		Set(3, activity=100, 2019-01-05T00:00)
+		Set(%d, activity=100, 2019-01-04T00:00)
+		Set(4, activity=101, 2019-01-02T00:00)`, ShardWidth+1)})
+
+	res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `SlidingCount(Row(activity=100), from=2019-01-01T00:00, to=2019-01-06T00:00, window=3, granularity="D")`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buckets := res.Results[0].([]pilosa.BucketCount)
+	// The first window reaches back before "from", and columns leave the
+	// window once their bucket is three days old.
+	exp := []uint64{2, 1, 2, 2, 2}
+	if len(buckets) != len(exp) {
+		t.Fatalf("unexpected bucket count: %d", len(buckets))
+	}
+	for i, b := range buckets {
+		if b.Count != exp[i] {
+			t.Fatalf("unexpected count for bucket %d: %d != %d", i, b.Count, exp[i])
+		} else if b.Time != time.Date(2019, 1, i+1, 0, 0, 0, 0, time.UTC) {
+			t.Fatalf("unexpected time for bucket %d: %s", i, b.Time)
+		}
+	}
+
+	if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `SlidingCount(Row(activity=100), from=2019-01-01T00:00, to=2019-01-06T00:00, window=0)`}); err == nil {
+		t.Fatal("expected error for empty window")
+	}
+}
+
+// Ensure a BucketDelta query returns the columns added and removed between
+// two time buckets.
+func TestExecutor_Execute_BucketDelta(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	c[0].MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	c[0].MustCreateField(t, "i", "activity", pilosa.OptFieldTypeTime(pilosa.TimeQuantum("YMDH")))
+
+	c[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`
+		Set(1, activity=100, 2019-01-01T10:00)
+		Set(2, activity=100, 2019-01-01T10:30)
+		Set(2, activity=100, 2019-01-01T11:00)
+		Set(3, activity=100, 2019-01-01T11:10)
+		Set(%d, activity=100, 2019-01-01T11:59)
+		Set(%d, activity=100, 2019-01-01T10:00)
+		Set(4, activity=101, 2019-01-01T11:00)`, ShardWidth+1, ShardWidth+2)})
+
+	for _, tt := range []struct {
+		query string
+		exp   pilosa.BucketDelta
+	}{
+		{
+			query: `BucketDelta(Row(activity=100), from=2019-01-01T10:00, to=2019-01-01T11:00, granularity="H")`,
+			exp:   pilosa.BucketDelta{Added: 2, Removed: 2},
+		},
+		{
+			query: `BucketDelta(Row(activity=100), from=2019-01-01T10:15, to=2019-01-01T11:45, granularity="H", columns=true)`,
+			exp:   pilosa.BucketDelta{Added: 2, Removed: 2, AddedColumns: []uint64{3, ShardWidth + 1}, RemovedColumns: []uint64{1, ShardWidth + 2}},
+		},
+		{
+			query: `BucketDelta(Row(activity=100), from=2019-01-01T10:15, to=2019-01-01T11:45, granularity="H", columns=true, limit=1, previous=2)`,
+			exp:   pilosa.BucketDelta{Added: 2, Removed: 2, AddedColumns: []uint64{3}, RemovedColumns: []uint64{ShardWidth + 2}},
+		},
+	} {
+		res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: tt.query})
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(res.Results[0], tt.exp) {
+			t.Fatalf("unexpected result for %s: %+v", tt.query, res.Results[0])
+		}
+	}
+
+	if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `BucketDelta(Row(activity=100), from=2019-01-01T10:00, to=2019-01-01T11:00, granularity="W")`}); err == nil {
+		t.Fatal("expected error for invalid granularity")
+	}
+}
+
+func TestExecutor_Execute_Retention(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	c[0].MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	c[0].MustCreateField(t, "i", "active", pilosa.OptFieldTypeTime(pilosa.TimeQuantum("YMD")))
+
+	// Columns 1-4 form the cohort of January 1st; column 5 is only active later.
+	c[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`
+		Set(1, active=1, 2019-01-01T09:00)
+		Set(2, active=1, 2019-01-01T10:00)
+		Set(3, active=1, 2019-01-01T11:00)
+		Set(%d, active=1, 2019-01-01T12:00)
+		Set(1, active=1, 2019-01-02T00:00)
+		Set(2, active=1, 2019-01-02T00:00)
+		Set(%d, active=1, 2019-01-02T00:00)
+		Set(5, active=1, 2019-01-02T00:00)
+		Set(1, active=1, 2019-01-08T00:00)
+		Set(5, active=1, 2019-01-08T00:00)
+		Set(3, active=2, 2019-01-08T00:00)`, ShardWidth+1, ShardWidth+1)})
+
+	day := func(d int) time.Time { return time.Date(2019, 1, d, 0, 0, 0, 0, time.UTC) }
+	for _, tt := range []struct {
+		query string
+		exp   []pilosa.BucketCount
+	}{
+		{
+			query: `Retention(Row(active=1), cohort=2019-01-01T00:00, returns=[2019-01-02T00:00, 2019-01-08T00:00, 2019-01-09T00:00])`,
+			exp:   []pilosa.BucketCount{{Time: day(1), Count: 4}, {Time: day(2), Count: 3}, {Time: day(8), Count: 1}, {Time: day(9), Count: 0}},
+		},
+		{
+			query: `Retention(Row(active=1), cohort=2019-01-02T12:00, returns=[2019-01-08T00:00])`,
+			exp:   []pilosa.BucketCount{{Time: day(2), Count: 4}, {Time: day(8), Count: 2}},
+		},
+		{
+			query: `Retention(Row(active=1), cohort=2019-01-01T00:00, returns=[2019-01-01T00:00], granularity="M")`,
+			exp:   []pilosa.BucketCount{{Time: day(1), Count: 5}, {Time: day(1), Count: 5}},
+		},
+	} {
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: tt.query}); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(res.Results[0], tt.exp) {
+			t.Fatalf("unexpected result for %s: %+v", tt.query, res.Results[0])
+		}
+	}
+
+	if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Retention(Row(active=1), cohort=2019-01-01T00:00, returns=[2019-01-02T00:00], granularity="H")`}); err == nil {
+		t.Fatal("expected error for granularity not in the time quantum")
+	}
+}
+
+// Ensure a FirstSeen query can be executed.
+func TestExecutor_Execute_FirstSeen(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	c[0].MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	c[0].MustCreateField(t, "i", "activity", pilosa.OptFieldTypeTime(pilosa.TimeQuantum("YMD")))
+
+	c[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`
+		Set(1, activity=100, 2019-01-05T00:00)
+		Set(1, activity=100, 2019-01-03T10:00)
+		Set(2, activity=100, 2019-01-02T00:00)
+		Set(3, activity=100, 2018-12-30T00:00)
+		Set(3, activity=100, 2019-01-06T00:00)
+		Set(%d, activity=100, 2019-01-04T00:00)
+		Set(4, activity=101, 2019-01-02T00:00)`, ShardWidth+1)})
+
+	day := func(d int) time.Time { return time.Date(2019, 1, d, 0, 0, 0, 0, time.UTC) }
+	for _, tt := range []struct {
+		query string
+		exp   []pilosa.ColumnTime
+	}{
+		{
+			query: `FirstSeen(Row(activity=100), from=2019-01-01T00:00, to=2019-01-08T00:00)`,
+			exp:   []pilosa.ColumnTime{{Column: 1, Time: day(3)}, {Column: 2, Time: day(2)}, {Column: 3, Time: day(6)}, {Column: ShardWidth + 1, Time: day(4)}},
+		},
+		{
+			query: `FirstSeen(Row(activity=100), from=2019-01-01T00:00, to=2019-01-08T00:00, limit=2)`,
+			exp:   []pilosa.ColumnTime{{Column: 1, Time: day(3)}, {Column: 2, Time: day(2)}},
+		},
+		{
+			query: `FirstSeen(Row(activity=100), from=2019-01-01T00:00, to=2019-01-08T00:00, previous=2, limit=2)`,
+			exp:   []pilosa.ColumnTime{{Column: 3, Time: day(6)}, {Column: ShardWidth + 1, Time: day(4)}},
+		},
+	} {
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: tt.query}); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(res.Results[0], tt.exp) {
+			t.Fatalf("unexpected result for %s: %+v", tt.query, res.Results[0])
+		}
+	}
+
+	if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `FirstSeen(Row(activity=100), from=2019-01-01T00:00, to=2019-01-08T00:00, limit=100000)`}); err == nil {
+		t.Fatal("expected error for limit above the maximum")
+	}
+}
+
+func TestExecutor_Execute_Recent(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	c[0].MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	c[0].MustCreateField(t, "i", "segment", pilosa.OptFieldTypeTime(pilosa.TimeQuantum("YMD")))
+
+	c[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`
+		Set(1, segment=10, 2019-01-02T00:00)
+		Set(1, segment=20, 2019-01-05T00:00)
+		Set(%d, segment=20, 2019-01-03T00:00)
+		Set(2, segment=30, 2019-01-03T12:00)
+		Set(%d, segment=30, 2018-12-20T00:00)
+		Set(3, segment=40, 2018-12-31T00:00)
+		Set(4, segment=50, 2019-01-05T00:00)`, ShardWidth+1, ShardWidth+2)})
+
+	day := func(d int) time.Time { return time.Date(2019, 1, d, 0, 0, 0, 0, time.UTC) }
+	for _, tt := range []struct {
+		query string
+		exp   []pilosa.RowTime
+	}{
+		{
+			query: `Recent(field=segment, since=2019-01-01T00:00)`,
+			exp:   []pilosa.RowTime{{Row: 20, Time: day(5)}, {Row: 50, Time: day(5)}, {Row: 30, Time: day(3)}, {Row: 10, Time: day(2)}},
+		},
+		{
+			query: `Recent(field=segment, since=2019-01-03T18:00)`,
+			exp:   []pilosa.RowTime{{Row: 20, Time: day(5)}, {Row: 50, Time: day(5)}, {Row: 30, Time: day(3)}},
+		},
+		{
+			query: `Recent(field=segment, since=2019-01-01T00:00, offset=1, limit=2)`,
+			exp:   []pilosa.RowTime{{Row: 50, Time: day(5)}, {Row: 30, Time: day(3)}},
+		},
+		{
+			query: `Recent(field=segment, since=2019-01-06T00:00)`,
+			exp:   []pilosa.RowTime{},
+		},
+	} {
+		if res, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: tt.query}); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(res.Results[0], tt.exp) {
+			t.Fatalf("unexpected result for %s: %+v", tt.query, res.Results[0])
+		}
+	}
+
+	if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Recent(field=segment, since=2019-01-01T00:00, limit=100000)`}); err == nil {
+		t.Fatal("expected error for limit above the maximum")
+	}
+}
+
 // Ensure a range query can be executed.
 func TestExecutor_Execute_Range_Deprecated(t *testing.T) {
 	t.Run("RowIDColumnID", func(t *testing.T) {
@@ -2525,6 +3587,77 @@ func TestExecutor_Execute_ErrMaxWritesPerRequest(t *testing.T) {
 	}
 }
 
+// Ensure executor returns an error if a time range covers too many buckets.
+func TestExecutor_Execute_ErrMaxRangeBuckets(t *testing.T) {
+	c := test.MustNewCluster(t, 1)
+	c[0].Config.MaxRangeBuckets = 24
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c[0].MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	c[0].MustCreateField(t, "i", "h", pilosa.OptFieldTypeTime(pilosa.TimeQuantum("H")))
+	c[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `
+		Set(1, h=1, 2019-01-01T00:00)
+		Set(2, h=1, 2019-01-01T23:00)
+		Set(3, h=2, 2019-01-03T00:00)`})
+
+	for _, tt := range []struct {
+		name  string
+		query string
+	}{
+		{name: "Row", query: `Row(h=1, from=2019-01-01T00:00, to=%s)`},
+		{name: "Rows", query: `Rows(h, from=2019-01-01T00:00, to=%s)`},
+		{name: "IntersectRange", query: `IntersectRange(Row(h=1, from=2019-01-01T00:00, to=2019-01-01T01:00), field=h, from=2019-01-01T00:00, to=%s)`},
+		{name: "CumulativeCount", query: `CumulativeCount(Row(h=1), from=2019-01-01T00:00, to=%s, granularity=H)`},
+		{name: "SlidingCount", query: `SlidingCount(Row(h=1), from=2019-01-01T00:00, to=%s, window=1, granularity=H)`},
+		{name: "FirstSeen", query: `FirstSeen(Row(h=1), from=2019-01-01T00:00, to=%s)`},
+		{name: "BucketDelta", query: `BucketDelta(Row(h=1), from=2019-01-01T00:00, to=%s, granularity=H)`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(tt.query, "2019-01-02T00:00")}); err != nil {
+				t.Fatalf("unexpected error at the maximum: %s", err)
+			}
+			if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(tt.query, "2019-01-02T01:00")}); err == nil || !strings.Contains(err.Error(), "25") || !strings.Contains(err.Error(), "maximum of 24") {
+				t.Fatalf("unexpected error above the maximum: %v", err)
+			}
+		})
+	}
+
+	// Recent() scans every existing bucket since a time.
+	t.Run("Recent", func(t *testing.T) {
+		c[0].MustCreateField(t, "i", "r", pilosa.OptFieldTypeTime(pilosa.TimeQuantum("H")))
+		var sets strings.Builder
+		for hour := 0; hour < 25; hour++ {
+			fmt.Fprintf(&sets, "Set(1, r=1, %s)\n", time.Date(2019, 1, 5, hour, 0, 0, 0, time.UTC).Format(pilosa.TimeFormat))
+		}
+		c[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: sets.String()})
+
+		if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Recent(field=r, since=2019-01-05T01:00)`}); err != nil {
+			t.Fatalf("unexpected error at the maximum: %s", err)
+		}
+		if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Recent(field=r, since=2019-01-05T00:00)`}); err == nil || !strings.Contains(err.Error(), "25") || !strings.Contains(err.Error(), "maximum of 24") {
+			t.Fatalf("unexpected error above the maximum: %v", err)
+		}
+	})
+}
+
+// Ensure executor aborts a query which runs longer than the query timeout.
+func TestExecutor_Execute_QueryTimeout(t *testing.T) {
+	c := test.MustNewCluster(t, 1)
+	c[0].Config.QueryTimeout = toml.Duration(time.Nanosecond)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c[0].MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	c[0].MustCreateField(t, "i", "f")
+
+	if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Count(Row(f=1))`}); errors.Cause(err) != pilosa.ErrQueryTimeout {
+		t.Fatalf("expected query timeout, got: %v", err)
+	}
+}
+
 // Ensure SetColumnAttrs doesn't save `field` as an attribute
 func TestExecutor_SetColumnAttrs_ExcludeField(t *testing.T) {
 	c := test.MustRunCluster(t, 1)
@@ -3247,6 +4380,44 @@ func TestExecutor_Execute_SetRow(t *testing.T) {
 	})
 }
 
+// Ensure Materialize() stores a query result which Refresh() can recompute.
+func TestExecutor_Execute_Materialize(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	c[0].MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	c[0].MustCreateField(t, "i", "f")
+	c[0].MustCreateField(t, "i", "seg")
+
+	c[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`
+		Set(1, f=1)
+		Set(%d, f=2)
+		Set(3, f=3)`, ShardWidth+2)})
+
+	columns := func() []uint64 {
+		resp := c[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Row(seg=10)`})
+		return resp.Results[0].(*pilosa.Row).Columns()
+	}
+
+	c[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Materialize(Union(Row(f=1), Row(f=2)), seg=10)`})
+	if cols := columns(); !reflect.DeepEqual(cols, []uint64{1, ShardWidth + 2}) {
+		t.Fatalf("unexpected materialized columns: %v", cols)
+	}
+
+	// The destination is not changed by writes until it is refreshed.
+	c[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Set(4, f=1) Clear(1, f=1)`})
+	if cols := columns(); !reflect.DeepEqual(cols, []uint64{1, ShardWidth + 2}) {
+		t.Fatalf("unexpected columns before refresh: %v", cols)
+	}
+	c[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Refresh(seg=10)`})
+	if cols := columns(); !reflect.DeepEqual(cols, []uint64{4, ShardWidth + 2}) {
+		t.Fatalf("unexpected columns after refresh: %v", cols)
+	}
+
+	if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Refresh(seg=11)`}); err == nil {
+		t.Fatal("expected error refreshing a row which was not materialized")
+	}
+}
+
 func benchmarkExistence(nn bool, b *testing.B) {
 	c := test.MustNewCluster(b, 1)
 	var err error
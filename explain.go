@@ -0,0 +1,99 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"sort"
+
+	"github.com/pilosa/pilosa/v2/pql"
+)
+
+// QueryPlan describes how a query would be executed.
+type QueryPlan struct {
+	// Calls holds a plan for each top-level call in the query.
+	Calls []*CallPlan `json:"calls"`
+
+	// Shards are the shards the query would run against.
+	Shards []uint64 `json:"shards"`
+
+	// Nodes maps the ID of each node which would execute part of the query
+	// to the shards it would execute.
+	Nodes map[string][]uint64 `json:"nodes"`
+}
+
+// CallPlan describes a single call of a query and the calls nested in it.
+type CallPlan struct {
+	Name string `json:"name"`
+
+	// Args holds the call's arguments. Arguments which are calls, such as
+	// the filter of GroupBy(), are CallPlans, and conditions are strings.
+	Args map[string]interface{} `json:"args,omitempty"`
+
+	// Fields lists the fields of the index which the call reads or writes
+	// directly, not including those of its children.
+	Fields []string `json:"fields,omitempty"`
+
+	Children []*CallPlan `json:"children,omitempty"`
+}
+
+// newCallPlan returns the plan for c against idx.
+func newCallPlan(idx *Index, c *pql.Call) *CallPlan {
+	p := &CallPlan{Name: c.Name}
+
+	fields := make(map[string]struct{})
+	addField := func(name string) {
+		if idx.Field(name) != nil {
+			fields[name] = struct{}{}
+		}
+	}
+
+	for key, v := range c.Args {
+		// Field names are either argument keys, as in Row(f=1), or the
+		// value of the _field or field argument, as in TopN(f) or
+		// Sum(field=f).
+		switch key {
+		case "_field", "field":
+			if name, ok := v.(string); ok {
+				addField(name)
+			}
+		default:
+			if !pql.IsReservedArg(key) {
+				addField(key)
+			}
+		}
+
+		if p.Args == nil {
+			p.Args = make(map[string]interface{}, len(c.Args))
+		}
+		switch v := v.(type) {
+		case *pql.Call:
+			p.Args[key] = newCallPlan(idx, v)
+		case *pql.Condition:
+			p.Args[key] = v.String()
+		default:
+			p.Args[key] = v
+		}
+	}
+
+	for name := range fields {
+		p.Fields = append(p.Fields, name)
+	}
+	sort.Strings(p.Fields)
+
+	for _, child := range c.Children {
+		p.Children = append(p.Children, newCallPlan(idx, child))
+	}
+	return p
+}
@@ -90,6 +90,7 @@ type Field struct {
 	logger logger.Logger
 
 	snapshotQueue chan *fragment
+	backend       Storage
 
 	// Instantiates new translation store on open.
 	OpenTranslateStore OpenTranslateStoreFunc
@@ -882,6 +883,7 @@ func (f *Field) newView(path, name string) *view {
 	view.stats = f.Stats
 	view.broadcaster = f.broadcaster
 	view.snapshotQueue = f.snapshotQueue
+	view.backend = f.backend
 	return view
 }
 
@@ -156,6 +156,9 @@ type fragment struct {
 	stats stats.StatsClient
 
 	snapshotQueue chan *fragment
+
+	// Persists the row cache.
+	backend Storage
 }
 
 // newFragment returns a new instance of Fragment.
@@ -174,6 +177,8 @@ func newFragment(path, index, field, view string, shard uint64, flags byte) *fra
 		MaxOpN: defaultFragmentMaxOpN,
 
 		stats: stats.NopStatsClient,
+
+		backend: fileStorage{},
 	}
 	f.snapshotCond = sync.Cond{L: &f.mu}
 	return f
@@ -472,9 +477,9 @@ func (f *fragment) openCache() error {
 		return ErrInvalidCacheType
 	}
 
-	// Read cache data from disk.
+	// Read cache data from storage.
 	path := f.cachePath()
-	buf, err := ioutil.ReadFile(path)
+	buf, err := readStorage(f.backend, path)
 	if os.IsNotExist(err) {
 		return nil
 	} else if err != nil {
@@ -892,6 +897,13 @@ func (f *fragment) bit(rowID, columnID uint64) (bool, error) {
 	return f.storage.Contains(pos), nil
 }
 
+// hasBit returns whether the bit at rowID, columnID is set.
+func (f *fragment) hasBit(rowID, columnID uint64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.bit(rowID, columnID)
+}
+
 // value uses a column of bits to read a multi-bit value.
 func (f *fragment) value(columnID uint64, bitDepth uint) (value int64, exists bool, err error) {
 	f.mu.Lock()
@@ -1701,6 +1713,28 @@ func (f *fragment) top(opt topOptions) ([]Pair, error) {
 	return r, nil
 }
 
+// overlappingRows returns the IDs of the rows which have a column in common
+// with src, or of every row with a column set if src is nil. Unlike top(), it
+// reads rows from storage, so it isn't limited to the rows in the rank cache.
+func (f *fragment) overlappingRows(src *Row) []uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rowIDs := f.unprotectedRows(0)
+	if src == nil {
+		return rowIDs
+	}
+
+	n := 0
+	for _, rowID := range rowIDs {
+		if f.rowFromStorage(rowID).intersectionCount(src) > 0 {
+			rowIDs[n] = rowID
+			n++
+		}
+	}
+	return rowIDs[:n]
+}
+
 func (f *fragment) topBitmapPairs(rowIDs []uint64) []bitmapPair {
 	// Don't retrieve from storage if CacheTypeNone.
 	if f.CacheType == CacheTypeNone {
@@ -2424,8 +2458,8 @@ func (f *fragment) flushCache() error {
 		return errors.Wrap(err, "marshalling")
 	}
 
-	// Write to disk.
-	if err := ioutil.WriteFile(f.cachePath(), buf, 0666); err != nil {
+	// Write to storage.
+	if err := writeStorage(f.backend, f.cachePath(), buf); err != nil {
 		return errors.Wrap(err, "writing")
 	}
 
@@ -2499,7 +2533,7 @@ func (f *fragment) writeCacheToArchive(tw *tar.Writer) error {
 	defer f.mu.Unlock()
 
 	// Read cache into buffer.
-	buf, err := ioutil.ReadFile(f.cachePath())
+	buf, err := readStorage(f.backend, f.cachePath())
 	if os.IsNotExist(err) {
 		return nil
 	} else if err != nil {
@@ -2589,11 +2623,11 @@ func (f *fragment) readStorageFromArchive(r io.Reader) error {
 }
 
 func (f *fragment) readCacheFromArchive(r io.Reader) error {
-	// Slurp data from reader and write to disk.
+	// Slurp data from reader and write to storage.
 	buf, err := ioutil.ReadAll(r)
 	if err != nil {
 		return errors.Wrap(err, "reading")
-	} else if err := ioutil.WriteFile(f.cachePath(), buf, 0666); err != nil {
+	} else if err := writeStorage(f.backend, f.cachePath(), buf); err != nil {
 		return errors.Wrap(err, "writing")
 	}
 
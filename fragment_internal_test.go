@@ -78,6 +78,43 @@ func TestFragment_SetBit(t *testing.T) {
 	}
 }
 
+// Ensure concurrent writes to the same row are serialized so that no update
+// is lost and exactly one writer reports each bit as changed.
+func TestFragment_SetBitConcurrent(t *testing.T) {
+	f := mustOpenFragment("i", "f", viewStandard, 0, "")
+	defer f.Clean(t)
+
+	const workerN, bitN = 16, 1000
+	var changedN uint64
+	var eg errgroup.Group
+	for w := 0; w < workerN; w++ {
+		w := w
+		eg.Go(func() error {
+			// Each worker walks the columns in a different order to
+			// increase contention on the same containers.
+			for i := 0; i < bitN; i++ {
+				columnID := uint64((i + w*bitN/workerN) % bitN * 67)
+				changed, err := f.setBit(120, columnID)
+				if err != nil {
+					return err
+				} else if changed {
+					atomic.AddUint64(&changedN, 1)
+				}
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	if changedN != bitN {
+		t.Fatalf("unexpected changed count: %d", changedN)
+	} else if n := f.row(120).Count(); n != bitN {
+		t.Fatalf("unexpected row count: %d", n)
+	}
+}
+
 // Ensure a fragment can clear a set bit.
 func TestFragment_ClearBit(t *testing.T) {
 	f := mustOpenFragment("i", "f", viewStandard, 0, "")
@@ -1301,6 +1338,37 @@ func TestFragment_LRUCache_Persistence(t *testing.T) {
 	}
 }
 
+// Ensure a fragment's cache is persisted through its Storage.
+func TestFragment_Cache_Storage(t *testing.T) {
+	f := mustOpenFragment("i", "f", viewStandard, 0, CacheTypeRanked)
+	defer f.Clean(t)
+
+	st := newMemStorage()
+	f.backend = st
+
+	for i := uint64(0); i < 100; i++ {
+		if _, err := f.setBit(i, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := f.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys, err := st.List(f.path); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(keys, []string{f.cachePath()}) {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+	if _, err := os.Stat(f.cachePath()); !os.IsNotExist(err) {
+		t.Fatalf("expected no cache file on disk, got: %v", err)
+	}
+	if n := f.cache.Len(); n != 100 {
+		t.Fatalf("unexpected cache len: %d", n)
+	}
+}
+
 // Ensure a fragment's cache can be persisted between restarts.
 func TestFragment_RankCache_Persistence(t *testing.T) {
 	index := mustOpenIndex(IndexOptions{})
@@ -42,6 +42,18 @@ type QueryRequest struct {
 	// If true, indicates that query is part of a larger distributed query.
 	// If false, this request is on the originating node.
 	Remote bool
+
+	// Serialized roaring bitmap of column IDs referenced by Uploaded() calls
+	// in the query. It is only used on the originating node.
+	Bitmap []byte
+
+	// If true, the query is not subject to the executor's query timeout.
+	// It is only used on the originating node.
+	NoTimeout bool
+
+	// If true, the response includes the number of rows overlapping the
+	// filter of the query's single TopN() call.
+	IncludeTotal bool
 }
 
 // QueryResponse represent a response from a processed query.
@@ -53,6 +65,10 @@ type QueryResponse struct {
 	// Set of column attribute objects matching IDs returned in Result.
 	ColumnAttrSets []*ColumnAttrSet
 
+	// Number of rows overlapping the filter of the query's TopN() call,
+	// if it was requested.
+	Total *uint64
+
 	// Error during parsing or execution.
 	Err error
 }
@@ -68,9 +84,11 @@ func (resp *QueryResponse) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
 		Results        []interface{}    `json:"results"`
 		ColumnAttrSets []*ColumnAttrSet `json:"columnAttrs,omitempty"`
+		Total          *uint64          `json:"total,omitempty"`
 	}{
 		Results:        resp.Results,
 		ColumnAttrSets: resp.ColumnAttrSets,
+		Total:          resp.Total,
 	})
 }
 
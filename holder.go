@@ -77,6 +77,10 @@ type Holder struct {
 
 	snapshotQueue chan *fragment
 
+	// Storage persists fragment data which is read and written in full,
+	// such as row caches. Defaults to files within Path.
+	Storage Storage
+
 	// Manages replication from the primary node.
 	primaryTranslateNode     *Node
 	translateStoreReplicator *holderTranslateStoreReplicator
@@ -112,6 +116,18 @@ func (lc *lockedChan) Recv() {
 	lc.mu.RUnlock()
 }
 
+// isClosed returns true if the channel has been closed, without blocking.
+func (lc *lockedChan) isClosed() bool {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	select {
+	case <-lc.ch:
+		return true
+	default:
+		return false
+	}
+}
+
 // NewHolder returns a new instance of Holder.
 func NewHolder() *Holder {
 	return &Holder{
@@ -124,6 +140,7 @@ func NewHolder() *Holder {
 		Stats:       stats.NopStatsClient,
 
 		NewAttrStore: newNopAttrStore,
+		Storage:      fileStorage{},
 
 		cacheFlushInterval: defaultCacheFlushInterval,
 
@@ -442,6 +459,7 @@ func (h *Holder) newIndex(path, name string) (*Index, error) {
 	index.newAttrStore = h.NewAttrStore
 	index.columnAttrs = h.NewAttrStore(filepath.Join(index.path, ".data"))
 	index.snapshotQueue = h.snapshotQueue
+	index.backend = h.Storage
 	index.holder = h
 	index.OpenTranslateStore = h.OpenTranslateStore
 	return index, nil
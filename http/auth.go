@@ -0,0 +1,107 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/pilosa/pilosa/v2/pql"
+)
+
+// authenticate rejects requests which don't carry the handler's API key as a
+// bearer token with a 401. Only requests which may modify data are checked
+// unless authReads is set. Queries may call Set() and Clear(), so any
+// request which isn't a GET, and any GET carrying a query which writes, is
+// treated as modifying data.
+func (h *Handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.apiKey == "" || !h.requiresAuth(r) || h.validAPIKey(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="pilosa"`)
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "missing or invalid API key")
+	})
+}
+
+// requiresAuth returns true if r must carry the API key.
+func (h *Handler) requiresAuth(r *http.Request) bool {
+	switch r.Method {
+	case "GET", "HEAD":
+		// Nodes check each other's /version to confirm a node is down, and
+		// orchestrators probe /healthz, so they stay open.
+		if r.URL.Path == "/version" || r.URL.Path == "/healthz" {
+			return false
+		}
+		return h.authReads || writesQuery(r.URL.Query().Get("query"))
+	default:
+		return true
+	}
+}
+
+// writesQuery returns true if query contains a call which modifies data.
+// The GET query handler rejects such queries, but the key is still required
+// so that authentication doesn't depend on that check. Queries which can't
+// be parsed are treated as writes.
+func writesQuery(query string) bool {
+	if query == "" {
+		return false
+	}
+	q, err := pql.ParseString(query)
+	return err != nil || q.HasWriteCall()
+}
+
+// validAPIKey returns true if r carries the API key in its Authorization
+// header.
+func (h *Handler) validAPIKey(r *http.Request) bool {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), []byte(h.apiKey)) == 1
+}
+
+// WithAPIKey returns a copy of c which sends key as a bearer token with every
+// request. It is used by nodes to authenticate to each other.
+func WithAPIKey(c *http.Client, key string) *http.Client {
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	clone := *c
+	clone.Transport = &apiKeyTransport{key: key, next: transport}
+	return &clone
+}
+
+// apiKeyTransport is an http.RoundTripper which adds an API key to requests.
+type apiKeyTransport struct {
+	key  string
+	next http.RoundTripper
+}
+
+// RoundTrip sets the Authorization header on a copy of req and sends it.
+func (t *apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it is given.
+	clone := req.WithContext(req.Context())
+	clone.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		clone.Header[k] = v
+	}
+	clone.Header.Set("Authorization", "Bearer "+t.key)
+	return t.next.RoundTrip(clone)
+}
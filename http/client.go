@@ -478,6 +478,9 @@ func (c *InternalClient) importNode(ctx context.Context, node *pilosa.Node, inde
 	if opts.IgnoreKeyCheck {
 		vals.Set("ignoreKeyCheck", "true")
 	}
+	if opts.Remote {
+		vals.Set("remote", "true")
+	}
 	url := fmt.Sprintf("%s?%s", u.String(), vals.Encode())
 
 	req, err := http.NewRequest("POST", url, bytes.NewReader(buf))
@@ -1055,10 +1058,10 @@ func (c *InternalClient) executeRequest(req *http.Request) (*http.Response, erro
 			return resp, errors.Wrapf(err, "bad status '%s' and err reading body", resp.Status)
 		}
 		var msg string
-		// try to decode a JSON response
-		var sr successResponse
-		if err = json.Unmarshal(buf, &sr); err == nil {
-			msg = sr.Error.Error()
+		// try to decode a JSON error response
+		var er errorResponse
+		if err = json.Unmarshal(buf, &er); err == nil && er.Error != nil {
+			msg = er.Error.Error()
 		} else {
 			msg = string(buf)
 		}
@@ -14,8 +14,36 @@
 
 package http
 
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error codes identify the kind of error in an error response, so that
+// clients can handle errors without matching on the message.
+const (
+	ErrCodeBadRequest           = "bad-request"
+	ErrCodeUnauthorized         = "unauthorized"
+	ErrCodeNotFound             = "not-found"
+	ErrCodeIndexNotFound        = "index-not-found"
+	ErrCodeFieldNotFound        = "field-not-found"
+	ErrCodeConflict             = "conflict"
+	ErrCodeNotAcceptable        = "not-acceptable"
+	ErrCodeUnsupportedMediaType = "unsupported-media-type"
+	ErrCodePreconditionFailed   = "precondition-failed"
+	ErrCodeTooLarge             = "too-large"
+	ErrCodeRateLimited          = "rate-limited"
+	ErrCodeUnavailable          = "unavailable"
+	ErrCodeTimeout              = "timeout"
+	ErrCodeNotImplemented       = "not-implemented"
+	ErrCodeInternal             = "internal"
+)
+
 // Error defines a standard application error.
 type Error struct {
+	// Machine-readable error code, one of the ErrCode constants.
+	Code string `json:"code"`
+
 	// Human-readable message.
 	Message string `json:"message"`
 }
@@ -24,3 +52,17 @@ type Error struct {
 func (e *Error) Error() string {
 	return e.Message
 }
+
+// errorResponse is the body of every JSON error response.
+type errorResponse struct {
+	Error *Error `json:"error"`
+}
+
+// writeError writes an error response with the given HTTP status to w as
+// {"error":{"code":code,"message":message}}.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errorResponse{Error: &Error{Code: code, Message: message}})
+}
@@ -15,8 +15,10 @@
 package http
 
 import (
+	"bufio"
 	"context"
 	"crypto/tls"
+	"encoding/csv"
 	"encoding/json"
 	"expvar"
 	"fmt"
@@ -37,6 +39,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/pilosa/pilosa/v2"
 	"github.com/pilosa/pilosa/v2/logger"
+	"github.com/pilosa/pilosa/v2/pql"
 	"github.com/pilosa/pilosa/v2/tracing"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -57,6 +60,54 @@ type Handler struct {
 
 	closeTimeout time.Duration
 
+	// maxImportBatch limits the number of columns which may be sent in a
+	// single import request. Zero means no limit.
+	maxImportBatch int
+
+	// drainRetryAfter is the Retry-After sent with requests rejected while
+	// the node is draining.
+	drainRetryAfter time.Duration
+
+	// excludeColumns is used for queries which don't set the
+	// excludeColumns query argument.
+	excludeColumns bool
+
+	// pageLimit is the number of columns returned for each row when a
+	// query sets the offset argument but not limit. Zero means no limit.
+	pageLimit uint64
+
+	// queryResultTTL is how long the result of an async query is kept
+	// once the query has finished.
+	queryResultTTL time.Duration
+	queryJobs      *queryJobs
+
+	// maxAsyncQueries limits the number of async queries running at once.
+	// Zero means no limit.
+	maxAsyncQueries int
+
+	// asyncQueryTimeout is the maximum time an async query may run for.
+	// Async queries aren't subject to the query timeout. Zero means no
+	// limit.
+	asyncQueryTimeout time.Duration
+
+	// rateLimitPerSec limits the rate of import and write query requests
+	// from each client IP. Zero means no limit.
+	rateLimitPerSec int
+	rateLimiter     *rateLimiter
+
+	// apiKey is the bearer token required by requests which modify data,
+	// and by all requests if authReads is set. Empty means no
+	// authentication.
+	apiKey    string
+	authReads bool
+
+	// enablePprof exposes the net/http/pprof endpoints under /debug/pprof/.
+	enablePprof bool
+
+	// maxRequestBytes limits the size of request bodies, other than those
+	// of bulk data routes. Zero means no limit.
+	maxRequestBytes int64
+
 	server *http.Server
 }
 
@@ -73,10 +124,6 @@ var externalPrefixFlag = map[string]bool{
 	"version": true,
 }
 
-type errorResponse struct {
-	Error string `json:"error"`
-}
-
 // handlerOption is a functional option type for pilosa.Handler
 type handlerOption func(s *Handler) error
 
@@ -84,7 +131,7 @@ func OptHandlerAllowedOrigins(origins []string) handlerOption {
 	return func(h *Handler) error {
 		h.Handler = handlers.CORS(
 			handlers.AllowedOrigins(origins),
-			handlers.AllowedHeaders([]string{"Content-Type"}),
+			handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}),
 		)(h.Handler)
 		return nil
 	}
@@ -120,11 +167,127 @@ func OptHandlerCloseTimeout(d time.Duration) handlerOption {
 	}
 }
 
+// OptHandlerMaxImportBatch limits the number of columns accepted in a single
+// import request. Requests exceeding the limit are rejected with a 413. A
+// value of zero disables the limit.
+func OptHandlerMaxImportBatch(n int) handlerOption {
+	return func(h *Handler) error {
+		h.maxImportBatch = n
+		return nil
+	}
+}
+
+// OptHandlerDrainRetryAfter sets how long clients are told to wait before
+// retrying requests rejected while the node is draining. Default is 30
+// seconds.
+func OptHandlerDrainRetryAfter(d time.Duration) handlerOption {
+	return func(h *Handler) error {
+		h.drainRetryAfter = d
+		return nil
+	}
+}
+
+// OptHandlerQueryResultTTL sets how long the result of an async query is kept
+// once the query has finished. Default is 10 minutes.
+func OptHandlerQueryResultTTL(d time.Duration) handlerOption {
+	return func(h *Handler) error {
+		h.queryResultTTL = d
+		return nil
+	}
+}
+
+// OptHandlerMaxAsyncQueries limits the number of async queries running at
+// once. Requests for more are rejected with a 429. A value of zero disables
+// the limit.
+func OptHandlerMaxAsyncQueries(n int) handlerOption {
+	return func(h *Handler) error {
+		h.maxAsyncQueries = n
+		return nil
+	}
+}
+
+// OptHandlerAsyncQueryTimeout sets the maximum time an async query may run
+// for. Async queries aren't subject to the server's query timeout, since they
+// are meant for queries which take longer. A value of zero disables the
+// limit.
+func OptHandlerAsyncQueryTimeout(d time.Duration) handlerOption {
+	return func(h *Handler) error {
+		h.asyncQueryTimeout = d
+		return nil
+	}
+}
+
+// OptHandlerRateLimit limits the number of import and write query requests
+// per second accepted from each client IP. Requests over the limit are rejected with a
+// 429. A value of zero disables the limit.
+func OptHandlerRateLimit(perSec int) handlerOption {
+	return func(h *Handler) error {
+		h.rateLimitPerSec = perSec
+		return nil
+	}
+}
+
+// OptHandlerAPIKey requires requests which modify data to carry key as a
+// bearer token in their Authorization header. Requests without it are
+// rejected with a 401. If authReads is set, all other requests except GET
+// /version and GET /healthz require it too. An empty key disables
+// authentication.
+func OptHandlerAPIKey(key string, authReads bool) handlerOption {
+	return func(h *Handler) error {
+		h.apiKey = key
+		h.authReads = authReads
+		return nil
+	}
+}
+
+// OptHandlerMaxRequestBytes limits the size of request bodies to n bytes.
+// Imports and queries with an uploaded bitmap are not limited. A value of
+// zero disables the limit.
+func OptHandlerMaxRequestBytes(n int64) handlerOption {
+	return func(h *Handler) error {
+		h.maxRequestBytes = n
+		return nil
+	}
+}
+
+// OptHandlerPprof sets whether the net/http/pprof profiling endpoints are
+// served under /debug/pprof/. They are off unless enabled because profiles
+// can expose memory contents and are expensive to collect.
+func OptHandlerPprof(enable bool) handlerOption {
+	return func(h *Handler) error {
+		h.enablePprof = enable
+		return nil
+	}
+}
+
+// OptHandlerExcludeColumns sets whether columns are excluded from row results
+// when a query request doesn't set the excludeColumns argument.
+func OptHandlerExcludeColumns(v bool) handlerOption {
+	return func(h *Handler) error {
+		h.excludeColumns = v
+		return nil
+	}
+}
+
+// OptHandlerPageLimit sets the number of columns returned for each row when a
+// query sets the offset argument but not limit. Zero means no limit.
+func OptHandlerPageLimit(limit uint64) handlerOption {
+	return func(h *Handler) error {
+		h.pageLimit = limit
+		return nil
+	}
+}
+
 // NewHandler returns a new instance of Handler with a default logger.
 func NewHandler(opts ...handlerOption) (*Handler, error) {
 	handler := &Handler{
 		logger:       logger.NopLogger,
 		closeTimeout: time.Second * 30,
+
+		drainRetryAfter: time.Second * 30,
+		queryResultTTL:  time.Minute * 10,
+		pageLimit:       1000,
+		maxRequestBytes: 32 << 20,
 	}
 	handler.Handler = newRouter(handler)
 	handler.populateValidators()
@@ -144,6 +307,10 @@ func NewHandler(opts ...handlerOption) (*Handler, error) {
 		return nil, errors.New("must pass OptHandlerListener")
 	}
 
+	handler.queryJobs = newQueryJobs(handler.queryResultTTL, handler.maxAsyncQueries)
+	if handler.rateLimitPerSec > 0 {
+		handler.rateLimiter = newRateLimiter(handler.rateLimitPerSec)
+	}
 	handler.server = &http.Server{Handler: handler}
 
 	return handler, nil
@@ -173,6 +340,8 @@ func (h *Handler) Close() error {
 func (h *Handler) populateValidators() {
 	h.validators = map[string]*queryValidationSpec{}
 	h.validators["Home"] = queryValidationSpecRequired()
+	h.validators["PostClusterDrain"] = queryValidationSpecRequired()
+	h.validators["DeleteClusterDrain"] = queryValidationSpecRequired()
 	h.validators["PostClusterResizeAbort"] = queryValidationSpecRequired()
 	h.validators["PostClusterResizeRemoveNode"] = queryValidationSpecRequired()
 	h.validators["PostClusterResizeSetCoordinator"] = queryValidationSpecRequired()
@@ -185,9 +354,17 @@ func (h *Handler) populateValidators() {
 	h.validators["PostTranslateKeys"] = queryValidationSpecRequired()
 	h.validators["PostField"] = queryValidationSpecRequired()
 	h.validators["DeleteField"] = queryValidationSpecRequired()
-	h.validators["PostImport"] = queryValidationSpecRequired().Optional("clear", "ignoreKeyCheck")
+	h.validators["PostImport"] = queryValidationSpecRequired().Optional("clear", "ignoreKeyCheck", "remote")
 	h.validators["PostImportRoaring"] = queryValidationSpecRequired().Optional("remote", "clear")
-	h.validators["PostQuery"] = queryValidationSpecRequired().Optional("shards", "columnAttrs", "excludeRowAttrs", "excludeColumns")
+	h.validators["PostFieldRemap"] = queryValidationSpecRequired("offset")
+	h.validators["GetQuery"] = queryValidationSpecRequired("query").Optional("shards", "columnAttrs", "excludeRowAttrs", "excludeColumns", "include_total", "typed", "format", "offset", "limit")
+	h.validators["PostQuery"] = queryValidationSpecRequired().Optional("shards", "columnAttrs", "excludeRowAttrs", "excludeColumns", "include_total", "typed", "format", "offset", "limit")
+	h.validators["PostQueries"] = queryValidationSpecRequired()
+	h.validators["PostQueryAsync"] = queryValidationSpecRequired().Optional("shards", "columnAttrs", "excludeRowAttrs", "excludeColumns", "include_total")
+	h.validators["PostQueryExplain"] = queryValidationSpecRequired().Optional("shards")
+	h.validators["GetQueryResult"] = queryValidationSpecRequired("job").Optional("typed", "format")
+	h.validators["PostQueryWithBitmap"] = queryValidationSpecRequired().Optional("shards", "columnAttrs", "excludeRowAttrs", "excludeColumns", "include_total", "typed", "format", "offset", "limit")
+	h.validators["GetHealthz"] = queryValidationSpecRequired()
 	h.validators["GetInfo"] = queryValidationSpecRequired()
 	h.validators["RecalculateCaches"] = queryValidationSpecRequired()
 	h.validators["GetSchema"] = queryValidationSpecRequired()
@@ -211,14 +388,7 @@ func (h *Handler) queryArgValidator(next http.Handler) http.Handler {
 
 		if validator, ok := h.validators[key]; ok {
 			if err := validator.validate(r.URL.Query()); err != nil {
-				// TODO: Return the response depending on the Accept header
-				response := errorResponse{Error: err.Error()}
-				body, err := json.Marshal(response)
-				if err != nil {
-					http.Error(w, err.Error(), http.StatusBadRequest)
-					return
-				}
-				http.Error(w, string(body), http.StatusBadRequest)
+				writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 				return
 			}
 		}
@@ -226,6 +396,33 @@ func (h *Handler) queryArgValidator(next http.Handler) http.Handler {
 	})
 }
 
+// unlimitedBodyRoutes are the routes which carry bulk data. Their bodies
+// are streamed or bounded by other limits, such as maxImportBatch, so they
+// aren't subject to maxRequestBytes.
+var unlimitedBodyRoutes = map[string]bool{
+	"PostImport":          true,
+	"PostImportRoaring":   true,
+	"PostQueryWithBitmap": true,
+}
+
+// limitRequestBody rejects requests whose bodies are larger than
+// maxRequestBytes with a 413. Bodies sent without a Content-Length are cut
+// off at the limit, which the handler reports as a read error.
+func (h *Handler) limitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.maxRequestBytes <= 0 || unlimitedBodyRoutes[mux.CurrentRoute(r).GetName()] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.ContentLength > h.maxRequestBytes {
+			writeError(w, http.StatusRequestEntityTooLarge, ErrCodeTooLarge, fmt.Sprintf("request body of %d bytes exceeds maximum of %d", r.ContentLength, h.maxRequestBytes))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxRequestBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (h *Handler) extractTracing(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		span, ctx := tracing.GlobalTracer.ExtractHTTPHeaders(r)
@@ -274,10 +471,12 @@ func (h *Handler) collectStats(next http.Handler) http.Handler {
 func newRouter(handler *Handler) *mux.Router {
 	router := mux.NewRouter()
 	router.HandleFunc("/", handler.handleHome).Methods("GET").Name("Home")
+	router.HandleFunc("/cluster/drain", handler.handlePostClusterDrain).Methods("POST").Name("PostClusterDrain")
+	router.HandleFunc("/cluster/drain", handler.handleDeleteClusterDrain).Methods("DELETE").Name("DeleteClusterDrain")
 	router.HandleFunc("/cluster/resize/abort", handler.handlePostClusterResizeAbort).Methods("POST").Name("PostClusterResizeAbort")
 	router.HandleFunc("/cluster/resize/remove-node", handler.handlePostClusterResizeRemoveNode).Methods("POST").Name("PostClusterResizeRemoveNode")
 	router.HandleFunc("/cluster/resize/set-coordinator", handler.handlePostClusterResizeSetCoordinator).Methods("POST").Name("PostClusterResizeSetCoordinator")
-	router.PathPrefix("/debug/pprof/").Handler(http.DefaultServeMux).Methods("GET")
+	router.PathPrefix("/debug/pprof/").HandlerFunc(handler.handleGetPprof).Methods("GET")
 	router.Handle("/debug/vars", expvar.Handler()).Methods("GET")
 	router.Handle("/metrics", promhttp.Handler())
 	router.HandleFunc("/export", handler.handleGetExport).Methods("GET").Name("GetExport")
@@ -292,9 +491,17 @@ func newRouter(handler *Handler) *mux.Router {
 	router.HandleFunc("/index/{index}/field", handler.handlePostField).Methods("POST").Name("PostField")
 	router.HandleFunc("/index/{index}/field/", handler.handlePostField).Methods("POST").Name("PostField")
 	router.HandleFunc("/index/{index}/field/{field}", handler.handleDeleteField).Methods("DELETE").Name("DeleteField")
-	router.HandleFunc("/index/{index}/field/{field}/import", handler.handlePostImport).Methods("POST").Name("PostImport")
-	router.HandleFunc("/index/{index}/field/{field}/import-roaring/{shard}", handler.handlePostImportRoaring).Methods("POST").Name("PostImportRoaring")
-	router.HandleFunc("/index/{index}/query", handler.handlePostQuery).Methods("POST").Name("PostQuery")
+	router.HandleFunc("/index/{index}/field/{field}/import", handler.rateLimit(handler.handlePostImport)).Methods("POST").Name("PostImport")
+	router.HandleFunc("/index/{index}/field/{field}/import-roaring/{shard}", handler.rateLimit(handler.handlePostImportRoaring)).Methods("POST").Name("PostImportRoaring")
+	router.HandleFunc("/index/{index}/field/{field}/remap", handler.handlePostFieldRemap).Methods("POST").Name("PostFieldRemap")
+	router.Handle("/index/{index}/query", handlers.CompressHandler(http.HandlerFunc(handler.handleGetQuery))).Methods("GET").Name("GetQuery")
+	router.Handle("/index/{index}/query", handlers.CompressHandler(http.HandlerFunc(handler.handlePostQuery))).Methods("POST").Name("PostQuery")
+	router.HandleFunc("/index/{index}/query/async", handler.handlePostQueryAsync).Methods("POST").Name("PostQueryAsync")
+	router.HandleFunc("/index/{index}/query/explain", handler.handlePostQueryExplain).Methods("POST").Name("PostQueryExplain")
+	router.Handle("/query/result", handlers.CompressHandler(http.HandlerFunc(handler.handleGetQueryResult))).Methods("GET").Name("GetQueryResult")
+	router.Handle("/queries", handlers.CompressHandler(http.HandlerFunc(handler.handlePostQueries))).Methods("POST").Name("PostQueries")
+	router.Handle("/index/{index}/query/with-bitmap", handlers.CompressHandler(http.HandlerFunc(handler.handlePostQueryWithBitmap))).Methods("POST").Name("PostQueryWithBitmap")
+	router.HandleFunc("/healthz", handler.handleGetHealthz).Methods("GET").Name("GetHealthz")
 	router.HandleFunc("/info", handler.handleGetInfo).Methods("GET").Name("GetInfo")
 	router.HandleFunc("/recalculate-caches", handler.handleRecalculateCaches).Methods("POST").Name("RecalculateCaches")
 	router.HandleFunc("/schema", handler.handleGetSchema).Methods("GET").Name("GetSchema")
@@ -317,25 +524,138 @@ func newRouter(handler *Handler) *mux.Router {
 	router.HandleFunc("/internal/nodes", handler.handleGetNodes).Methods("GET").Name("GetNodes")
 	router.HandleFunc("/internal/shards/max", handler.handleGetShardsMax).Methods("GET").Name("GetShardsMax") // TODO: deprecate, but it's being used by the client
 
+	router.Use(handler.recoverPanic)
+	router.Use(handler.authenticate)
+	router.Use(handler.limitRequestBody)
 	router.Use(handler.queryArgValidator)
 	router.Use(handler.extractTracing)
 	router.Use(handler.collectStats)
 	return router
 }
 
-// ServeHTTP handles an HTTP request.
-func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	defer func() {
-		if err := recover(); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			stack := debug.Stack()
-			msg := "PANIC: %s\n%s"
-			h.logger.Printf(msg, err, stack)
-			fmt.Fprintf(w, msg, err, stack)
+// rateLimit wraps next so that requests from a client IP which is over the
+// rate limit are rejected with a 429 and a Retry-After header. Imports
+// forwarded from other nodes aren't limited, since a node fans a single
+// client import out into a request per shard.
+func (h *Handler) rateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.rateLimiter == nil || (r.URL.Query().Get("remote") == "true" && h.fromPeer(r)) || h.allowRate(w, r) {
+			next(w, r)
+		}
+	}
+}
+
+// rateLimitWrites applies the rate limit to requests carrying queries which
+// write, so that Set() and Clear() calls can't be used to get around the
+// limit on imports. Queries which only read aren't limited. It returns false
+// if the request was rejected.
+func (h *Handler) rateLimitWrites(w http.ResponseWriter, r *http.Request, remote bool, queries ...string) bool {
+	if h.rateLimiter == nil || (remote && h.fromPeer(r)) {
+		return true
+	}
+	for _, query := range queries {
+		if writesQuery(query) {
+			return h.allowRate(w, r)
 		}
-	}()
+	}
+	return true
+}
 
-	h.Handler.ServeHTTP(w, r)
+// allowRate returns true if the client IP of r is within the rate limit.
+// Otherwise it rejects r with a 429 and a Retry-After header.
+func (h *Handler) allowRate(w http.ResponseWriter, r *http.Request) bool {
+	// Key on the IP alone, since each connection has its own port.
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if ok, wait := h.rateLimiter.allow(ip); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		writeError(w, http.StatusTooManyRequests, ErrCodeRateLimited, "rate limit exceeded")
+		return false
+	}
+	return true
+}
+
+// fromPeer returns true if r comes from the address of one of the cluster's
+// nodes. Requests which nodes forward to each other are marked remote, but
+// clients can mark theirs remote too, so the mark is only trusted on requests
+// from a peer. Nodes must advertise an address which resolves to the one they
+// connect from.
+func (h *Handler) fromPeer(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, node := range h.api.Hosts(r.Context()) {
+		addrs, err := net.LookupIP(node.URI.Host)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if addr.Equal(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// recoverPanic logs the stack of a panic in a handler and answers the
+// request with a 500, unless the handler had already started its response,
+// so that the client isn't left waiting on a connection nothing will write
+// to.
+func (h *Handler) recoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &panicResponseWriter{ResponseWriter: w}
+		defer func() {
+			if err := recover(); err != nil {
+				// net/http uses ErrAbortHandler to abort a response quietly.
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				h.logger.Printf("PANIC: %s %s: %s\n%s", r.Method, r.URL.Path, err, debug.Stack())
+				if !rw.wroteHeader {
+					writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("internal error: %s", err))
+				}
+			}
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// panicResponseWriter records whether a handler has started its response.
+type panicResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *panicResponseWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *panicResponseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher, which streaming handlers rely on.
+func (w *panicResponseWriter) Flush() {
+	w.wroteHeader = true
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// ServeHTTP handles an HTTP request. Panics in routed handlers are handled
+// by recoverPanic; this catches any raised outside of them.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.recoverPanic(h.Handler).ServeHTTP(w, r)
 }
 
 // successResponse is a general success/error struct for http responses.
@@ -346,11 +666,11 @@ type successResponse struct {
 }
 
 // check determines success or failure based on the error.
-// It also returns the corresponding http status code.
-func (r *successResponse) check(err error) (statusCode int) {
+// It also returns the corresponding http status code and error code.
+func (r *successResponse) check(err error) (statusCode int, code string) {
 	if err == nil {
 		r.Success = true
-		return 0
+		return 0, ""
 	}
 
 	cause := errors.Cause(err)
@@ -358,55 +678,51 @@ func (r *successResponse) check(err error) (statusCode int) {
 	// Determine HTTP status code based on the error type.
 	switch cause.(type) {
 	case pilosa.BadRequestError:
-		statusCode = http.StatusBadRequest
+		statusCode, code = http.StatusBadRequest, ErrCodeBadRequest
 	case pilosa.ConflictError:
-		statusCode = http.StatusConflict
+		statusCode, code = http.StatusConflict, ErrCodeConflict
 	case pilosa.NotFoundError:
-		statusCode = http.StatusNotFound
+		statusCode, code = http.StatusNotFound, ErrCodeNotFound
 	default:
-		statusCode = http.StatusInternalServerError
+		statusCode, code = http.StatusInternalServerError, ErrCodeInternal
 	}
 
 	r.Success = false
-	r.Error = &Error{Message: err.Error()}
+	r.Error = &Error{Code: code, Message: err.Error()}
 
-	return statusCode
+	return statusCode, code
 }
 
 // write sends a response to the http.ResponseWriter based on the success
 // status and the error.
 func (r *successResponse) write(w http.ResponseWriter, err error) {
 	// Apply the error and get the status code.
-	statusCode := r.check(err)
+	statusCode, code := r.check(err)
+	if statusCode != 0 {
+		writeError(w, statusCode, code, r.Error.Message)
+		return
+	}
 
 	// Marshal the json response.
 	msg, err := json.Marshal(r)
 	if err != nil {
-		http.Error(w, string(msg), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 
 	// Write the response.
-	if statusCode == 0 {
-		_, err := w.Write(msg)
-		if err != nil {
-			r.h.logger.Printf("error writing response: %v", err)
-			http.Error(w, string(msg), http.StatusInternalServerError)
-			return
-		}
-		_, err = w.Write([]byte("\n"))
-		if err != nil {
-			r.h.logger.Printf("error writing newline after response: %v", err)
-			http.Error(w, string(msg), http.StatusInternalServerError)
-			return
-		}
-	} else {
-		http.Error(w, string(msg), statusCode)
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(msg); err != nil {
+		r.h.logger.Printf("error writing response: %v", err)
+		return
+	}
+	if _, err := w.Write([]byte("\n")); err != nil {
+		r.h.logger.Printf("error writing newline after response: %v", err)
 	}
 }
 
 func (h *Handler) handleHome(w http.ResponseWriter, _ *http.Request) {
-	http.Error(w, "Welcome. Pilosa is running. Visit https://www.pilosa.com/docs/ for more information.", http.StatusNotFound)
+	writeError(w, http.StatusNotFound, ErrCodeNotFound, "Welcome. Pilosa is running. Visit https://www.pilosa.com/docs/ for more information.")
 }
 
 // validHeaderAcceptJSON returns false if one or more Accept
@@ -427,11 +743,12 @@ func validHeaderAcceptJSON(header http.Header) bool {
 // handleGetSchema handles GET /schema requests.
 func (h *Handler) handleGetSchema(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
 
 	schema := h.api.Schema(r.Context())
+	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(map[string]interface{}{"indexes": schema}); err != nil { // TODO: use pilosa.Schema instead of map[string]interface{} here?
 		h.logger.Printf("write schema response error: %s", err)
 	}
@@ -447,12 +764,12 @@ func (h *Handler) handlePostSchema(w http.ResponseWriter, r *http.Request) {
 
 	schema := &pilosa.Schema{}
 	if err := json.NewDecoder(r.Body).Decode(schema); err != nil {
-		http.Error(w, fmt.Sprintf("decoding request as JSON Pilosa schema: %v", err), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("decoding request as JSON Pilosa schema: %v", err))
 		return
 	}
 
 	if err := h.api.ApplySchema(r.Context(), schema, remote); err != nil {
-		http.Error(w, fmt.Sprintf("apply schema to Pilosa: %v", err), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("apply schema to Pilosa: %v", err))
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -461,7 +778,7 @@ func (h *Handler) handlePostSchema(w http.ResponseWriter, r *http.Request) {
 // handleGetStatus handles GET /status requests.
 func (h *Handler) handleGetStatus(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
 	status := getStatusResponse{
@@ -469,6 +786,7 @@ func (h *Handler) handleGetStatus(w http.ResponseWriter, r *http.Request) {
 		Nodes:   h.api.Hosts(r.Context()),
 		LocalID: h.api.Node().ID,
 	}
+	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(status); err != nil {
 		h.logger.Printf("write status response error: %s", err)
 	}
@@ -476,15 +794,54 @@ func (h *Handler) handleGetStatus(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) handleGetInfo(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
 	info := h.api.Info()
+	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(info); err != nil {
 		h.logger.Printf("write info response error: %s", err)
 	}
 }
 
+// handleGetPprof handles GET /debug/pprof/ requests by passing them to the
+// handlers net/http/pprof registers, if they are enabled.
+func (h *Handler) handleGetPprof(w http.ResponseWriter, r *http.Request) {
+	if !h.enablePprof {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "pprof is not enabled")
+		return
+	}
+	http.DefaultServeMux.ServeHTTP(w, r)
+}
+
+// handleGetHealthz handles GET /healthz requests. It is a cheap probe for
+// orchestrators: it answers 200 only when the node can serve queries, and
+// 503 with the reason otherwise. It reads in-memory state only, so it never
+// blocks on other nodes.
+func (h *Handler) handleGetHealthz(w http.ResponseWriter, r *http.Request) {
+	var reason string
+	switch {
+	case !h.api.Ready():
+		reason = pilosa.ErrNodeStarting.Error()
+	case h.api.State() == pilosa.ClusterStateStarting:
+		reason = "cluster is starting"
+	case h.api.Draining():
+		reason = pilosa.ErrDraining.Error()
+	}
+	if reason != "" {
+		writeError(w, http.StatusServiceUnavailable, ErrCodeUnavailable, reason)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(getHealthzResponse{Status: "ok"}); err != nil {
+		h.logger.Printf("write healthz response error: %s", err)
+	}
+}
+
+type getHealthzResponse struct {
+	Status string `json:"status"`
+}
+
 type getSchemaResponse struct {
 	Indexes []*pilosa.IndexInfo `json:"indexes"`
 }
@@ -500,8 +857,7 @@ func (h *Handler) handlePostQuery(w http.ResponseWriter, r *http.Request) {
 	// Parse incoming request.
 	req, err := h.readQueryRequest(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		e := h.writeQueryResponse(w, r, &pilosa.QueryResponse{Err: err})
+		e := h.writeQueryResponse(w, r, http.StatusBadRequest, &pilosa.QueryResponse{Err: err})
 		if e != nil {
 			h.logger.Printf("write query response error: %v (while trying to write another error: %v)", e, err)
 		}
@@ -510,20 +866,159 @@ func (h *Handler) handlePostQuery(w http.ResponseWriter, r *http.Request) {
 	// TODO: Remove
 	req.Index = mux.Vars(r)["index"]
 
+	h.serveQuery(w, r, req)
+}
+
+// handleGetQuery handles GET /query requests. The query is read from the
+// "query" URL argument rather than the body, and may not contain writes so
+// that responses can be cached like any other GET.
+func (h *Handler) handleGetQuery(w http.ResponseWriter, r *http.Request) {
+	req, err := h.readGetQueryRequest(r)
+	if err != nil {
+		e := h.writeQueryResponse(w, r, http.StatusBadRequest, &pilosa.QueryResponse{Err: err})
+		if e != nil {
+			h.logger.Printf("write query response error: %v (while trying to write another error: %v)", e, err)
+		}
+		return
+	}
+	req.Index = mux.Vars(r)["index"]
+
+	h.serveQuery(w, r, req)
+}
+
+// handlePostQueryWithBitmap handles /query/with-bitmap requests. The body is
+// multipart with a "query" part holding the PQL and a "bitmap" part holding a
+// roaring bitmap of column IDs, which the query references with Uploaded().
+func (h *Handler) handlePostQueryWithBitmap(w http.ResponseWriter, r *http.Request) {
+	req, err := h.readMultipartQueryRequest(r)
+	if err != nil {
+		e := h.writeQueryResponse(w, r, http.StatusBadRequest, &pilosa.QueryResponse{Err: err})
+		if e != nil {
+			h.logger.Printf("write query response error: %v (while trying to write another error: %v)", e, err)
+		}
+		return
+	}
+	req.Index = mux.Vars(r)["index"]
+
+	h.serveQuery(w, r, req)
+}
+
+// handlePostQueryAsync handles POST /query/async requests. The query is run in
+// the background and the response holds the id of its job, whose result is
+// fetched from /query/result.
+func (h *Handler) handlePostQueryAsync(w http.ResponseWriter, r *http.Request) {
+	req, err := h.readQueryRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	req.Index = mux.Vars(r)["index"]
+	if !h.rateLimitWrites(w, r, false, req.Query) {
+		return
+	}
+
+	// The query outlives the request, so it can't use the request's context.
+	// It has its own timeout rather than the one of synchronous queries.
+	req.NoTimeout = true
+	id, ok := h.queryJobs.start(func() (pilosa.QueryResponse, error) {
+		ctx := context.Background()
+		if h.asyncQueryTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, h.asyncQueryTimeout)
+			defer cancel()
+		}
+		return h.api.Query(ctx, req)
+	})
+	if !ok {
+		writeError(w, http.StatusTooManyRequests, ErrCodeRateLimited, "too many async queries running")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(postQueryAsyncResponse{JobID: id}); err != nil {
+		h.logger.Printf("write async query response error: %s", err)
+	}
+}
+
+type postQueryAsyncResponse struct {
+	JobID string `json:"job_id"`
+}
+
+// handlePostQueryExplain handles POST /index/{index}/query/explain requests,
+// which return how a query would be executed without executing it.
+func (h *Handler) handlePostQueryExplain(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
+		return
+	}
+	req, err := h.readURLQueryRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	req.Index = mux.Vars(r)["index"]
+
+	plan, err := h.api.ExplainQuery(r.Context(), req)
+	if err != nil {
+		status := queryErrorStatus(err)
+		writeError(w, status, queryErrorCode(err, status), err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(plan); err != nil {
+		h.logger.Printf("write query plan response error: %s", err)
+	}
+}
+
+// handleGetQueryResult handles GET /query/result requests. While the job is
+// running the response is a 202 with a "pending" status; once it has finished
+// the response is the same as that of the query endpoint.
+func (h *Handler) handleGetQueryResult(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.queryJobs.get(r.URL.Query().Get("job"))
+	if !ok {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "query job not found")
+		return
+	} else if !job.done {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(getQueryResultPendingResponse{Status: "pending"}); err != nil {
+			h.logger.Printf("write query result response error: %s", err)
+		}
+		return
+	}
+
+	status, resp := http.StatusOK, &job.resp
+	if job.err != nil {
+		status, resp = queryErrorStatus(job.err), &pilosa.QueryResponse{Err: job.err}
+	}
+	if err := h.writeQueryResponse(w, r, status, resp); err != nil {
+		h.logger.Printf("write query result response error: %s", err)
+	}
+}
+
+type getQueryResultPendingResponse struct {
+	Status string `json:"status"`
+}
+
+// serveQuery executes req and writes the response to w.
+func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, req *pilosa.QueryRequest) {
+	if !h.rateLimitWrites(w, r, req.Remote, req.Query) {
+		return
+	}
+
 	resp, err := h.api.Query(r.Context(), req)
 	if err != nil {
 		switch errors.Cause(err) {
-		case pilosa.ErrTooManyWrites:
-			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		case pilosa.ErrDraining:
+			w.Header().Set("Retry-After", h.retryAfterSeconds())
 		case pilosa.ErrTranslateStoreReadOnly:
 			u := h.api.PrimaryReplicaNodeURL()
 			u.Path, u.RawQuery = r.URL.Path, r.URL.RawQuery
 			http.Redirect(w, r, u.String(), http.StatusFound)
 			return
-		default:
-			w.WriteHeader(http.StatusBadRequest)
 		}
-		e := h.writeQueryResponse(w, r, &pilosa.QueryResponse{Err: err})
+		e := h.writeQueryResponse(w, r, queryErrorStatus(err), &pilosa.QueryResponse{Err: err})
 		if e != nil {
 			h.logger.Printf("write query response error: %v (while trying to write another error: %v)", e, err)
 		}
@@ -533,27 +1028,92 @@ func (h *Handler) handlePostQuery(w http.ResponseWriter, r *http.Request) {
 	// Set appropriate status code, if there is an error. It doesn't appear that
 	// resp.Err could ever be set in API.Query, so this code block is probably
 	// doing nothing right now.
+	status := http.StatusOK
 	if resp.Err != nil {
 		switch errors.Cause(resp.Err) {
 		case pilosa.ErrTooManyWrites:
-			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			status = http.StatusRequestEntityTooLarge
 		default:
-			w.WriteHeader(http.StatusBadRequest)
+			status = http.StatusBadRequest
 		}
 	}
 
 	// Write response back to client.
-	if err := h.writeQueryResponse(w, r, &resp); err != nil {
+	if err := h.writeQueryResponse(w, r, status, &resp); err != nil {
 		h.logger.Printf("write query response error: %s", err)
 	}
 }
 
+// queryErrorStatus returns the HTTP status for a query which failed with err.
+func queryErrorStatus(err error) int {
+	switch errors.Cause(err) {
+	case pilosa.ErrTooManyWrites:
+		return http.StatusRequestEntityTooLarge
+	case pilosa.ErrDraining, pilosa.ErrNodeStarting, pilosa.ErrWarmingUp:
+		return http.StatusServiceUnavailable
+	case pilosa.ErrQueryTimeout:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadRequest
+}
+
+// postQueriesItem is a single query in a POST /queries batch.
+type postQueriesItem struct {
+	Index string `json:"index"`
+	Query string `json:"query"`
+}
+
+// handlePostQueries handles POST /queries requests, which execute a JSON
+// array of queries, possibly against different indexes, in one round trip.
+// The response is an array holding each query's results, or its error, in
+// the order the queries were given; one failing query does not stop the
+// others from running.
+func (h *Handler) handlePostQueries(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
+		return
+	}
+
+	var items []postQueriesItem
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("decoding queries: %v", err))
+		return
+	}
+	queries := make([]string, len(items))
+	for i, item := range items {
+		queries[i] = item.Query
+	}
+	if !h.rateLimitWrites(w, r, false, queries...) {
+		return
+	}
+
+	resps := make([]interface{}, len(items))
+	for i, item := range items {
+		resp, err := h.api.Query(r.Context(), &pilosa.QueryRequest{
+			Index:          item.Index,
+			Query:          item.Query,
+			ExcludeColumns: h.excludeColumns,
+		})
+		if err != nil {
+			resps[i] = errorResponse{Error: &Error{Code: queryErrorCode(err, queryErrorStatus(err)), Message: err.Error()}}
+			continue
+		}
+		resps[i] = &resp
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resps); err != nil {
+		h.logger.Printf("write queries response error: %s", err)
+	}
+}
+
 // handleGetShardsMax handles GET /internal/shards/max requests.
 func (h *Handler) handleGetShardsMax(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(getShardsMaxResponse{
 		Standard: h.api.MaxShards(r.Context()),
 	}); err != nil {
@@ -573,19 +1133,20 @@ func (h *Handler) handleGetIndexes(w http.ResponseWriter, r *http.Request) {
 // handleGetIndex handles GET /index/<indexname> requests.
 func (h *Handler) handleGetIndex(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
 	indexName := mux.Vars(r)["index"]
 	for _, idx := range h.api.Schema(r.Context()) {
 		if idx.Name == indexName {
+			w.Header().Set("Content-Type", "application/json")
 			if err := json.NewEncoder(w).Encode(idx); err != nil {
 				h.logger.Printf("write response error: %s", err)
 			}
 			return
 		}
 	}
-	http.Error(w, fmt.Sprintf("Index %s Not Found", indexName), http.StatusNotFound)
+	writeError(w, http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("Index %s Not Found", indexName))
 }
 
 type postIndexRequest struct {
@@ -669,7 +1230,7 @@ func foundItem(items []string, item string) bool {
 // handleDeleteIndex handles DELETE /index request.
 func (h *Handler) handleDeleteIndex(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
 
@@ -683,12 +1244,12 @@ func (h *Handler) handleDeleteIndex(w http.ResponseWriter, r *http.Request) {
 // handlePostIndex handles POST /index request.
 func (h *Handler) handlePostIndex(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
 	indexName, ok := mux.Vars(r)["index"]
 	if !ok {
-		http.Error(w, "index name is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "index name is required")
 		return
 	}
 
@@ -714,7 +1275,7 @@ func (h *Handler) handlePostIndex(w http.ResponseWriter, r *http.Request) {
 // handlePostIndexAttrDiff handles POST /internal/index/attr/diff requests.
 func (h *Handler) handlePostIndexAttrDiff(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
 	indexName := mux.Vars(r)["index"]
@@ -722,21 +1283,22 @@ func (h *Handler) handlePostIndexAttrDiff(w http.ResponseWriter, r *http.Request
 	// Decode request.
 	var req postIndexAttrDiffRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 		return
 	}
 
 	attrs, err := h.api.IndexAttrDiff(r.Context(), indexName, req.Blocks)
 	if err != nil {
 		if errors.Cause(err) == pilosa.ErrIndexNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, err.Error())
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		}
 		return
 	}
 
 	// Encode response.
+	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(postIndexAttrDiffResponse{
 		Attrs: attrs,
 	}); err != nil {
@@ -755,19 +1317,19 @@ type postIndexAttrDiffResponse struct {
 // handlePostField handles POST /field request.
 func (h *Handler) handlePostField(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
 
 	indexName, ok := mux.Vars(r)["index"]
 	if !ok {
-		http.Error(w, "index name is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "index name is required")
 		return
 	}
 
 	fieldName, ok := mux.Vars(r)["field"]
 	if !ok {
-		http.Error(w, "field name is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "field name is required")
 		return
 	}
 
@@ -819,7 +1381,7 @@ func (h *Handler) handlePostField(w http.ResponseWriter, r *http.Request) {
 
 	_, err = h.api.CreateField(r.Context(), indexName, fieldName, fos...)
 	if _, ok := err.(pilosa.BadRequestError); ok {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 		return
 	}
 	resp.write(w, err)
@@ -925,7 +1487,7 @@ func (o *fieldOptions) validate() error {
 // handleDeleteField handles DELETE /field request.
 func (h *Handler) handleDeleteField(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
 
@@ -937,10 +1499,30 @@ func (h *Handler) handleDeleteField(w http.ResponseWriter, r *http.Request) {
 	resp.write(w, err)
 }
 
+// handlePostFieldRemap handles POST /index/{index}/field/{field}/remap requests.
+func (h *Handler) handlePostFieldRemap(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
+		return
+	}
+
+	indexName := mux.Vars(r)["index"]
+	fieldName := mux.Vars(r)["field"]
+	offset, err := strconv.ParseUint(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "offset should be an unsigned integer")
+		return
+	}
+
+	resp := successResponse{h: h}
+	err = h.api.RemapField(r.Context(), indexName, fieldName, offset)
+	resp.write(w, err)
+}
+
 // handleDeleteRemoteAvailableShard handles DELETE /field/{field}/available-shards/{shardID} request.
 func (h *Handler) handleDeleteRemoteAvailableShard(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
 
@@ -956,7 +1538,7 @@ func (h *Handler) handleDeleteRemoteAvailableShard(w http.ResponseWriter, r *htt
 // handlePostFieldAttrDiff handles POST /internal/field/attr/diff requests.
 func (h *Handler) handlePostFieldAttrDiff(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
 	indexName := mux.Vars(r)["index"]
@@ -965,7 +1547,7 @@ func (h *Handler) handlePostFieldAttrDiff(w http.ResponseWriter, r *http.Request
 	// Decode request.
 	var req postFieldAttrDiffRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 		return
 	}
 
@@ -973,14 +1555,15 @@ func (h *Handler) handlePostFieldAttrDiff(w http.ResponseWriter, r *http.Request
 	if err != nil {
 		switch errors.Cause(err) {
 		case pilosa.ErrFragmentNotFound:
-			http.Error(w, err.Error(), http.StatusNotFound)
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, err.Error())
 		default:
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		}
 		return
 	}
 
 	// Encode response.
+	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(postFieldAttrDiffResponse{
 		Attrs: attrs,
 	}); err != nil {
@@ -998,6 +1581,9 @@ type postFieldAttrDiffResponse struct {
 
 // readQueryRequest parses an query parameters from r.
 func (h *Handler) readQueryRequest(r *http.Request) (*pilosa.QueryRequest, error) {
+	if err := validateQueryFormat(r.URL.Query()); err != nil {
+		return nil, err
+	}
 	switch r.Header.Get("Content-Type") {
 	case "application/x-protobuf":
 		return h.readProtobufQueryRequest(r)
@@ -1044,20 +1630,158 @@ func (h *Handler) readURLQueryRequest(r *http.Request) (*pilosa.QueryRequest, er
 		Shards:          shards,
 		ColumnAttrs:     q.Get("columnAttrs") == "true",
 		ExcludeRowAttrs: q.Get("excludeRowAttrs") == "true",
-		ExcludeColumns:  q.Get("excludeColumns") == "true",
+		ExcludeColumns:  boolQueryArg(q, "excludeColumns", h.excludeColumns),
+		IncludeTotal:    q.Get("include_total") == "true",
+	}, nil
+}
+
+// readGetQueryRequest parses a read-only query and its parameters from the
+// URL of r.
+func (h *Handler) readGetQueryRequest(r *http.Request) (*pilosa.QueryRequest, error) {
+	q := r.URL.Query()
+	if err := validateQueryFormat(q); err != nil {
+		return nil, err
+	}
+
+	// Reject writes here; parse errors are reported when the query executes.
+	query := q.Get("query")
+	if parsed, err := pql.ParseString(query); err == nil && parsed.HasWriteCall() {
+		return nil, errors.New("GET queries cannot contain writes, use POST")
+	}
+
+	shards, err := parseUint64Slice(q.Get("shards"))
+	if err != nil {
+		return nil, errors.New("invalid shard argument")
+	}
+
+	return &pilosa.QueryRequest{
+		Query:           query,
+		Shards:          shards,
+		ColumnAttrs:     q.Get("columnAttrs") == "true",
+		ExcludeRowAttrs: q.Get("excludeRowAttrs") == "true",
+		ExcludeColumns:  boolQueryArg(q, "excludeColumns", h.excludeColumns),
+		IncludeTotal:    q.Get("include_total") == "true",
+	}, nil
+}
+
+// readMultipartQueryRequest parses a query and an uploaded bitmap from the
+// multipart body of r, and query parameters from the URL of r.
+func (h *Handler) readMultipartQueryRequest(r *http.Request) (*pilosa.QueryRequest, error) {
+	if err := validateQueryFormat(r.URL.Query()); err != nil {
+		return nil, err
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading multipart body")
+	}
+
+	var query, bitmap []byte
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "reading part")
+		}
+		buf, err := ioutil.ReadAll(part)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s part", part.FormName())
+		}
+		switch part.FormName() {
+		case "query":
+			query = buf
+		case "bitmap":
+			bitmap = buf
+		default:
+			return nil, fmt.Errorf("unknown part: %q", part.FormName())
+		}
+	}
+	if query == nil {
+		return nil, errors.New("query part required")
+	} else if bitmap == nil {
+		return nil, errors.New("bitmap part required")
+	}
+
+	q := r.URL.Query()
+	shards, err := parseUint64Slice(q.Get("shards"))
+	if err != nil {
+		return nil, errors.New("invalid shard argument")
+	}
+
+	return &pilosa.QueryRequest{
+		Query:           string(query),
+		Shards:          shards,
+		ColumnAttrs:     q.Get("columnAttrs") == "true",
+		ExcludeRowAttrs: q.Get("excludeRowAttrs") == "true",
+		ExcludeColumns:  boolQueryArg(q, "excludeColumns", h.excludeColumns),
+		IncludeTotal:    q.Get("include_total") == "true",
+		Bitmap:          bitmap,
 	}, nil
 }
 
-// writeQueryResponse writes the response from the executor to w.
-func (h *Handler) writeQueryResponse(w http.ResponseWriter, r *http.Request, resp *pilosa.QueryResponse) error {
+// boolQueryArg returns whether the query argument key is "true", or def if
+// the argument is not set.
+func boolQueryArg(q url.Values, key string, def bool) bool {
+	if _, ok := q[key]; !ok {
+		return def
+	}
+	return q.Get(key) == "true"
+}
+
+// writeQueryResponse writes the response from the executor to w with the
+// given status code. The Content-Type header is set before the status is
+// written so that error responses are labelled like successful ones.
+func (h *Handler) writeQueryResponse(w http.ResponseWriter, r *http.Request, status int, resp *pilosa.QueryResponse) error {
 	if !validHeaderAcceptJSON(r.Header) {
 		w.Header().Set("Content-Type", "application/protobuf")
+		w.WriteHeader(status)
 		return h.writeProtobufQueryResponse(w, resp)
 	}
+	if resp.Err != nil {
+		writeError(w, status, queryErrorCode(resp.Err, status), resp.Err.Error())
+		return nil
+	}
+	switch r.URL.Query().Get("format") {
+	case "bitpacked":
+		var err error
+		if resp, err = bitpackQueryResponse(resp); err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return nil
+		}
+	case "ranges":
+		resp = rangeQueryResponse(resp)
+	}
+	if offset, limit, ok := h.queryPage(r.URL.Query()); ok {
+		resp = pageQueryResponse(resp, offset, limit)
+	}
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if r.URL.Query().Get("typed") == "true" {
+		return h.writeTypedJSONQueryResponse(w, resp)
+	}
 	return h.writeJSONQueryResponse(w, resp)
 }
 
+// queryErrorCode returns the error code for a failed query which is being
+// answered with the given HTTP status.
+func queryErrorCode(err error, status int) string {
+	switch errors.Cause(err) {
+	case pilosa.ErrIndexNotFound:
+		return ErrCodeIndexNotFound
+	case pilosa.ErrFieldNotFound:
+		return ErrCodeFieldNotFound
+	}
+	switch status {
+	case http.StatusRequestEntityTooLarge:
+		return ErrCodeTooLarge
+	case http.StatusServiceUnavailable:
+		return ErrCodeUnavailable
+	case http.StatusGatewayTimeout:
+		return ErrCodeTimeout
+	}
+	return ErrCodeBadRequest
+}
+
 // writeProtobufQueryResponse writes the response from the executor to w as protobuf.
 func (h *Handler) writeProtobufQueryResponse(w io.Writer, resp *pilosa.QueryResponse) error {
 	if buf, err := h.api.Serializer.Marshal(resp); err != nil {
@@ -1069,18 +1793,373 @@ func (h *Handler) writeProtobufQueryResponse(w io.Writer, resp *pilosa.QueryResp
 }
 
 // writeJSONQueryResponse writes the response from the executor to w as JSON.
+//
+// Rows are streamed column by column rather than encoded from a slice of
+// their columns, so a row with billions of columns doesn't need to be held in
+// memory twice. The output is the same as encoding resp with json.Encoder.
 func (h *Handler) writeJSONQueryResponse(w io.Writer, resp *pilosa.QueryResponse) error {
-	return json.NewEncoder(w).Encode(resp)
+	bw := bufio.NewWriter(w)
+	if resp.Results == nil {
+		bw.WriteString(`{"results":null`)
+	} else {
+		bw.WriteString(`{"results":[`)
+		for i, result := range resp.Results {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			if row, ok := result.(*pilosa.Row); ok && row != nil && len(row.Keys) == 0 {
+				if err := writeJSONRow(bw, w, row); err != nil {
+					return err
+				}
+				continue
+			}
+			buf, err := json.Marshal(result)
+			if err != nil {
+				return err
+			}
+			bw.Write(buf)
+		}
+		bw.WriteByte(']')
+	}
+	if len(resp.ColumnAttrSets) > 0 {
+		buf, err := json.Marshal(resp.ColumnAttrSets)
+		if err != nil {
+			return err
+		}
+		bw.WriteString(`,"columnAttrs":`)
+		bw.Write(buf)
+	}
+	if resp.Total != nil {
+		fmt.Fprintf(bw, `,"total":%d`, *resp.Total)
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// jsonRowFlushInterval is the number of columns written between flushes to
+// the client while streaming a row.
+const jsonRowFlushInterval = 1 << 16
+
+// writeJSONRow writes row to bw in the same form as Row.MarshalJSON. It
+// flushes bw to w, and w to the client if it supports flushing, every
+// jsonRowFlushInterval columns.
+func writeJSONRow(bw *bufio.Writer, w io.Writer, row *pilosa.Row) error {
+	attrs := row.Attrs
+	if attrs == nil {
+		attrs = make(map[string]interface{})
+	}
+	buf, err := json.Marshal(attrs)
+	if err != nil {
+		return err
+	}
+	bw.WriteString(`{"attrs":`)
+	bw.Write(buf)
+	bw.WriteString(`,"columns":[`)
+
+	flusher, _ := w.(http.Flusher)
+	var n int
+	var num []byte
+	row.ForEach(func(col uint64) {
+		if n > 0 {
+			bw.WriteByte(',')
+		}
+		num = strconv.AppendUint(num[:0], col, 10)
+		bw.Write(num)
+		if n++; n%jsonRowFlushInterval == 0 && bw.Flush() == nil && flusher != nil {
+			flusher.Flush()
+		}
+	})
+	bw.WriteString("]}")
+
+	// bufio.Writer keeps the first write error, so a failed write to the
+	// client surfaces here.
+	return bw.Flush()
+}
+
+// typedQueryResult wraps a single query result with the name of its type so
+// that clients don't need to infer the type from the shape of the value.
+type typedQueryResult struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// writeTypedJSONQueryResponse writes the response from the executor to w as
+// JSON, wrapping each result in a typedQueryResult.
+func (h *Handler) writeTypedJSONQueryResponse(w io.Writer, resp *pilosa.QueryResponse) error {
+	results := make([]typedQueryResult, len(resp.Results))
+	for i, result := range resp.Results {
+		results[i] = typedQueryResult{Type: queryResultTypeName(result), Value: result}
+	}
+	return json.NewEncoder(w).Encode(struct {
+		Results        []typedQueryResult      `json:"results"`
+		ColumnAttrSets []*pilosa.ColumnAttrSet `json:"columnAttrs,omitempty"`
+		Total          *uint64                 `json:"total,omitempty"`
+	}{
+		Results:        results,
+		ColumnAttrSets: resp.ColumnAttrSets,
+		Total:          resp.Total,
+	})
+}
+
+// validateQueryFormat returns an error if the format query argument is not
+// supported, or if the offset and limit arguments are invalid.
+func validateQueryFormat(q url.Values) error {
+	switch f := q.Get("format"); f {
+	case "", "bitpacked", "ranges":
+	default:
+		return fmt.Errorf("invalid format: %q", f)
+	}
+	for _, key := range []string{"offset", "limit"} {
+		if _, ok := q[key]; !ok {
+			continue
+		} else if q.Get("format") != "" {
+			return fmt.Errorf("%s cannot be used with format %q", key, q.Get("format"))
+		} else if _, err := strconv.ParseUint(q.Get(key), 10, 64); err != nil {
+			return fmt.Errorf("invalid %s: %q", key, q.Get(key))
+		}
+	}
+	return nil
+}
+
+// queryPage returns the window of columns requested by the offset and limit
+// query arguments, which have already been validated. ok is false if neither
+// is set.
+func (h *Handler) queryPage(q url.Values) (offset, limit uint64, ok bool) {
+	_, hasOffset := q["offset"]
+	_, hasLimit := q["limit"]
+	if !hasOffset && !hasLimit {
+		return 0, 0, false
+	}
+	offset, _ = strconv.ParseUint(q.Get("offset"), 10, 64)
+	limit = h.pageLimit
+	if hasLimit {
+		limit, _ = strconv.ParseUint(q.Get("limit"), 10, 64)
+	}
+	return offset, limit, true
+}
+
+// pagedRow is a window of a row's columns, or of its keys if the index uses
+// keys, along with the total number of columns in the row.
+type pagedRow struct {
+	Attrs   map[string]interface{} `json:"attrs"`
+	Columns []uint64               `json:"columns"`
+	Keys    []string               `json:"keys,omitempty"`
+	Count   uint64                 `json:"count"`
+}
+
+// newPagedRow returns up to limit columns of row after skipping the first
+// offset. A limit of zero returns every column after offset.
+func newPagedRow(row *pilosa.Row, offset, limit uint64) *pagedRow {
+	pr := &pagedRow{Attrs: row.Attrs}
+	if pr.Attrs == nil {
+		pr.Attrs = make(map[string]interface{})
+	}
+
+	// Rows from indexes which use keys hold only their keys.
+	if len(row.Keys) > 0 {
+		pr.Columns = []uint64{}
+		pr.Count = uint64(len(row.Keys))
+		if offset < pr.Count {
+			end := pr.Count
+			if limit > 0 && offset+limit < end {
+				end = offset + limit
+			}
+			pr.Keys = row.Keys[offset:end]
+		}
+		return pr
+	}
+
+	pr.Columns = row.ColumnsPage(offset, limit)
+	pr.Count = row.Count()
+	return pr
+}
+
+// pageQueryResponse returns a copy of resp with each row result replaced by
+// a window of its columns.
+func pageQueryResponse(resp *pilosa.QueryResponse, offset, limit uint64) *pilosa.QueryResponse {
+	other := *resp
+	other.Results = make([]interface{}, len(resp.Results))
+	for i, result := range resp.Results {
+		if row, ok := result.(*pilosa.Row); ok && row != nil {
+			other.Results[i] = newPagedRow(row, offset, limit)
+		} else {
+			other.Results[i] = result
+		}
+	}
+	return &other
+}
+
+// maxBitpackedLength is the largest number of columns a bitpacked row may
+// span, so that a row with columns in distant shards can't make the node
+// allocate a huge bitmap.
+const maxBitpackedLength = 128 * pilosa.ShardWidth
+
+// bitpackedRow is a row result encoded as a dense bitmap. Bit i of Bits,
+// counting from the least significant bit of the first byte, is set if column
+// Base+i is set. The bitmap covers every shard from the first to the last
+// shard containing a set column.
+type bitpackedRow struct {
+	Attrs  map[string]interface{} `json:"attrs"`
+	Base   uint64                 `json:"base"`
+	Length uint64                 `json:"length"`
+	Bits   []byte                 `json:"bits"`
+}
+
+// newBitpackedRow returns row encoded as a dense bitmap. It returns an error
+// if the bitmap would span more than maxBitpackedLength columns.
+func newBitpackedRow(row *pilosa.Row) (*bitpackedRow, error) {
+	bp := &bitpackedRow{Attrs: row.Attrs, Bits: []byte{}}
+	if bp.Attrs == nil {
+		bp.Attrs = make(map[string]interface{})
+	}
+
+	// Columns are visited in order, so the first is the lowest and the last
+	// the highest.
+	var first, last uint64
+	var found bool
+	row.ForEach(func(col uint64) {
+		if !found {
+			first, found = col, true
+		}
+		last = col
+	})
+	if !found {
+		return bp, nil
+	}
+	bp.Base = (first / pilosa.ShardWidth) * pilosa.ShardWidth
+	bp.Length = (last/pilosa.ShardWidth+1)*pilosa.ShardWidth - bp.Base
+	if bp.Length > maxBitpackedLength {
+		return nil, fmt.Errorf("bitpacked row would span %d shards, more than the maximum of %d", bp.Length/pilosa.ShardWidth, maxBitpackedLength/pilosa.ShardWidth)
+	}
+	bp.Bits = make([]byte, bp.Length/8)
+	row.ForEach(func(col uint64) {
+		i := col - bp.Base
+		bp.Bits[i/8] |= 1 << (i % 8)
+	})
+	return bp, nil
+}
+
+// bitpackQueryResponse returns a copy of resp with row results encoded as
+// dense bitmaps.
+func bitpackQueryResponse(resp *pilosa.QueryResponse) (*pilosa.QueryResponse, error) {
+	other := *resp
+	other.Results = make([]interface{}, len(resp.Results))
+	for i, result := range resp.Results {
+		if row, ok := result.(*pilosa.Row); ok && row != nil {
+			bp, err := newBitpackedRow(row)
+			if err != nil {
+				return nil, err
+			}
+			other.Results[i] = bp
+		} else {
+			other.Results[i] = result
+		}
+	}
+	return &other, nil
+}
+
+// rangesRow is a row result encoded as a list of contiguous, inclusive
+// [start, end] column ranges, in ascending order.
+type rangesRow struct {
+	Attrs  map[string]interface{} `json:"attrs"`
+	Ranges [][2]uint64            `json:"ranges"`
+}
+
+// newRangesRow returns row encoded as a list of contiguous column ranges.
+// Isolated columns are returned as ranges with equal start and end.
+func newRangesRow(row *pilosa.Row) *rangesRow {
+	rr := &rangesRow{Attrs: row.Attrs, Ranges: [][2]uint64{}}
+	if rr.Attrs == nil {
+		rr.Attrs = make(map[string]interface{})
+	}
+
+	for _, col := range row.Columns() {
+		if n := len(rr.Ranges); n > 0 && rr.Ranges[n-1][1]+1 == col {
+			rr.Ranges[n-1][1] = col
+			continue
+		}
+		rr.Ranges = append(rr.Ranges, [2]uint64{col, col})
+	}
+	return rr
+}
+
+// rangeQueryResponse returns a copy of resp with row results encoded as lists
+// of contiguous column ranges.
+func rangeQueryResponse(resp *pilosa.QueryResponse) *pilosa.QueryResponse {
+	other := *resp
+	other.Results = make([]interface{}, len(resp.Results))
+	for i, result := range resp.Results {
+		if row, ok := result.(*pilosa.Row); ok && row != nil {
+			other.Results[i] = newRangesRow(row)
+		} else {
+			other.Results[i] = result
+		}
+	}
+	return &other
+}
+
+// queryResultTypeName returns the name used for the type of a query result in
+// typed JSON responses.
+func queryResultTypeName(result interface{}) string {
+	switch result.(type) {
+	case *pilosa.Row:
+		return "row"
+	case *bitpackedRow:
+		return "bitpacked"
+	case *rangesRow:
+		return "ranges"
+	case *pagedRow:
+		return "row"
+	case []pilosa.Pair:
+		return "pairs"
+	case pilosa.Pair:
+		return "pair"
+	case pilosa.ValCount:
+		return "valcount"
+	case uint64:
+		return "count"
+	case bool:
+		return "changed"
+	case pilosa.RowIdentifiers:
+		return "rows"
+	case []pilosa.GroupCount:
+		return "groupcounts"
+	case pilosa.OverlapCount:
+		return "overlap"
+	case pilosa.VennCount:
+		return "venn"
+	case []bool:
+		return "bools"
+	case pilosa.BucketDelta:
+		return "delta"
+	case []pilosa.ColumnTime:
+		return "columntimes"
+	case []pilosa.RowTime:
+		return "rowtimes"
+	case []pilosa.PercentileCount:
+		return "percentiles"
+	case []pilosa.BucketCount:
+		return "buckets"
+	case nil:
+		return "nil"
+	default:
+		return fmt.Sprintf("%T", result)
+	}
 }
 
 // handlePostImport handles /import requests.
 func (h *Handler) handlePostImport(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") == "text/csv" {
+		h.handlePostImportCSV(w, r)
+		return
+	}
+
 	// Verify that request is only communicating over protobufs.
 	if r.Header.Get("Content-Type") != "application/x-protobuf" {
-		http.Error(w, "Unsupported media type", http.StatusUnsupportedMediaType)
+		writeError(w, http.StatusUnsupportedMediaType, ErrCodeUnsupportedMediaType, "Unsupported media type")
 		return
 	} else if r.Header.Get("Accept") != "application/x-protobuf" {
-		http.Error(w, "Not acceptable", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "Not acceptable")
 		return
 	}
 	indexName := mux.Vars(r)["index"]
@@ -1090,10 +2169,12 @@ func (h *Handler) handlePostImport(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
 	doClear := q.Get("clear") == "true"
 	doIgnoreKeyCheck := q.Get("ignoreKeyCheck") == "true"
+	remote := q.Get("remote") == "true" && h.fromPeer(r)
 
 	opts := []pilosa.ImportOption{
 		pilosa.OptImportOptionsClear(doClear),
 		pilosa.OptImportOptionsIgnoreKeyCheck(doIgnoreKeyCheck),
+		pilosa.OptImportOptionsRemote(remote),
 	}
 
 	// Get index and field type to determine how to handle the
@@ -1104,9 +2185,9 @@ func (h *Handler) handlePostImport(w http.ResponseWriter, r *http.Request) {
 		case pilosa.ErrIndexNotFound:
 			fallthrough
 		case pilosa.ErrFieldNotFound:
-			http.Error(w, err.Error(), http.StatusNotFound)
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, err.Error())
 		default:
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		}
 		return
 	}
@@ -1114,7 +2195,7 @@ func (h *Handler) handlePostImport(w http.ResponseWriter, r *http.Request) {
 	// Read entire body.
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 		return
 	}
 
@@ -1124,16 +2205,24 @@ func (h *Handler) handlePostImport(w http.ResponseWriter, r *http.Request) {
 		// Marshal into request object.
 		req := &pilosa.ImportValueRequest{}
 		if err := h.api.Serializer.Unmarshal(body, req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+		if !h.checkImportBatch(w, len(req.ColumnIDs), len(req.ColumnKeys)) {
 			return
 		}
 
 		if err := h.api.ImportValue(r.Context(), req, opts...); err != nil {
 			switch errors.Cause(err) {
 			case pilosa.ErrClusterDoesNotOwnShard:
-				http.Error(w, err.Error(), http.StatusPreconditionFailed)
+				writeError(w, http.StatusPreconditionFailed, ErrCodePreconditionFailed, err.Error())
+			case pilosa.ErrDraining:
+				w.Header().Set("Retry-After", h.retryAfterSeconds())
+				writeError(w, http.StatusServiceUnavailable, ErrCodeUnavailable, err.Error())
+			case pilosa.ErrNodeStarting:
+				writeError(w, http.StatusServiceUnavailable, ErrCodeUnavailable, err.Error())
 			default:
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 			}
 			return
 		}
@@ -1142,16 +2231,28 @@ func (h *Handler) handlePostImport(w http.ResponseWriter, r *http.Request) {
 		// Marshal into request object.
 		req := &pilosa.ImportRequest{}
 		if err := h.api.Serializer.Unmarshal(body, req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+		if !h.checkImportBatch(w, len(req.ColumnIDs), len(req.ColumnKeys)) {
 			return
 		}
 
 		if err := h.api.Import(r.Context(), req, opts...); err != nil {
+			if _, ok := err.(pilosa.BadRequestError); ok {
+				writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+				return
+			}
 			switch errors.Cause(err) {
 			case pilosa.ErrClusterDoesNotOwnShard:
-				http.Error(w, err.Error(), http.StatusPreconditionFailed)
+				writeError(w, http.StatusPreconditionFailed, ErrCodePreconditionFailed, err.Error())
+			case pilosa.ErrDraining:
+				w.Header().Set("Retry-After", h.retryAfterSeconds())
+				writeError(w, http.StatusServiceUnavailable, ErrCodeUnavailable, err.Error())
+			case pilosa.ErrNodeStarting:
+				writeError(w, http.StatusServiceUnavailable, ErrCodeUnavailable, err.Error())
 			default:
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 			}
 			return
 		}
@@ -1160,7 +2261,7 @@ func (h *Handler) handlePostImport(w http.ResponseWriter, r *http.Request) {
 	// Marshal response object.
 	buf, e := h.api.Serializer.Marshal(&pilosa.ImportResponse{Err: ""})
 	if e != nil {
-		http.Error(w, fmt.Sprintf("marshal import response"), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("marshal import response"))
 		return
 	}
 
@@ -1171,13 +2272,169 @@ func (h *Handler) handlePostImport(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// csvImportBatchSize is the number of bits read from a CSV import before they
+// are imported.
+const csvImportBatchSize = 100000
+
+// importCSVResponse is the response to a CSV import.
+type importCSVResponse struct {
+	Imported int `json:"imported"`
+	Errors   int `json:"errors"`
+}
+
+// handlePostImportCSV handles imports with a text/csv body of
+// "row,column[,timestamp]" records. The body is read and imported in batches
+// so that large files don't have to be held in memory. Malformed records are
+// skipped and counted.
+func (h *Handler) handlePostImportCSV(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
+		return
+	}
+	indexName := mux.Vars(r)["index"]
+	fieldName := mux.Vars(r)["field"]
+	opts := []pilosa.ImportOption{
+		pilosa.OptImportOptionsClear(r.URL.Query().Get("clear") == "true"),
+	}
+
+	index, err := h.api.Index(r.Context(), indexName)
+	if err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeIndexNotFound, err.Error())
+		return
+	}
+	field := index.Field(fieldName)
+	if field == nil {
+		writeError(w, http.StatusNotFound, ErrCodeFieldNotFound, pilosa.ErrFieldNotFound.Error())
+		return
+	} else if field.Type() == pilosa.FieldTypeInt {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "CSV imports are not supported for int fields")
+		return
+	}
+	useRowKeys, useColumnKeys := field.Options().Keys, index.Keys()
+
+	var resp importCSVResponse
+	bits := make([]pilosa.Bit, 0, csvImportBatchSize)
+	importBits := func() error {
+		if len(bits) == 0 {
+			return nil
+		}
+		if err := h.api.ImportBits(r.Context(), indexName, fieldName, bits, opts...); err != nil {
+			return err
+		}
+		resp.Imported += len(bits)
+		h.logger.Debugf("csv import: index=%s, field=%s, imported=%d, errors=%d", indexName, fieldName, resp.Imported, resp.Errors)
+		bits = bits[:0]
+		return nil
+	}
+
+	cr := csv.NewReader(r.Body)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if _, ok := err.(*csv.ParseError); ok {
+			resp.Errors++
+			continue
+		} else if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "reading csv: "+err.Error())
+			return
+		}
+
+		// Ignore blank rows.
+		if len(record) == 1 && record[0] == "" {
+			continue
+		}
+
+		bit, ok := parseCSVBit(record, useRowKeys, useColumnKeys)
+		if !ok {
+			resp.Errors++
+			continue
+		}
+		bits = append(bits, bit)
+
+		if len(bits) == csvImportBatchSize {
+			if err := importBits(); err != nil {
+				h.writeImportCSVError(w, err)
+				return
+			}
+		}
+	}
+	if err := importBits(); err != nil {
+		h.writeImportCSVError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.logger.Printf("writing csv import response: %v", err)
+	}
+}
+
+// parseCSVBit parses a "row,column[,timestamp]" CSV record. It returns false
+// if the record is malformed.
+func parseCSVBit(record []string, useRowKeys, useColumnKeys bool) (bit pilosa.Bit, ok bool) {
+	if len(record) < 2 || len(record) > 3 {
+		return bit, false
+	}
+
+	var err error
+	if useRowKeys {
+		bit.RowKey = record[0]
+	} else if bit.RowID, err = strconv.ParseUint(record[0], 10, 64); err != nil {
+		return bit, false
+	}
+	if useColumnKeys {
+		bit.ColumnKey = record[1]
+	} else if bit.ColumnID, err = strconv.ParseUint(record[1], 10, 64); err != nil {
+		return bit, false
+	}
+	if len(record) == 3 && record[2] != "" {
+		t, err := time.Parse(pilosa.TimeFormat, record[2])
+		if err != nil {
+			return bit, false
+		}
+		bit.Timestamp = t.UnixNano()
+	}
+	return bit, true
+}
+
+// writeImportCSVError writes the response for a CSV import which failed.
+func (h *Handler) writeImportCSVError(w http.ResponseWriter, err error) {
+	switch errors.Cause(err) {
+	case pilosa.ErrDraining:
+		w.Header().Set("Retry-After", h.retryAfterSeconds())
+		writeError(w, http.StatusServiceUnavailable, ErrCodeUnavailable, err.Error())
+	case pilosa.ErrNodeStarting:
+		writeError(w, http.StatusServiceUnavailable, ErrCodeUnavailable, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+	}
+}
+
+// checkImportBatch writes a 413 response and returns false if an import
+// request containing the given number of column IDs or keys exceeds the
+// configured maximum batch size.
+func (h *Handler) checkImportBatch(w http.ResponseWriter, idN, keyN int) bool {
+	n := idN
+	if keyN > n {
+		n = keyN
+	}
+	if h.maxImportBatch > 0 && n > h.maxImportBatch {
+		writeError(w, http.StatusRequestEntityTooLarge, ErrCodeTooLarge, fmt.Sprintf("import batch of %d columns exceeds maximum of %d; split the batch or use import-roaring", n, h.maxImportBatch))
+		return false
+	}
+	return true
+}
+
 // handleGetExport handles /export requests.
 func (h *Handler) handleGetExport(w http.ResponseWriter, r *http.Request) {
 	switch r.Header.Get("Accept") {
 	case "text/csv":
 		h.handleGetExportCSV(w, r)
 	default:
-		http.Error(w, "Not acceptable", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "Not acceptable")
 	}
 }
 
@@ -1188,7 +2445,7 @@ func (h *Handler) handleGetExportCSV(w http.ResponseWriter, r *http.Request) {
 
 	shard, err := strconv.ParseUint(q.Get("shard"), 10, 64)
 	if err != nil {
-		http.Error(w, "invalid shard", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "invalid shard")
 		return
 	}
 
@@ -1197,9 +2454,9 @@ func (h *Handler) handleGetExportCSV(w http.ResponseWriter, r *http.Request) {
 		case pilosa.ErrFragmentNotFound:
 			break
 		case pilosa.ErrClusterDoesNotOwnShard:
-			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+			writeError(w, http.StatusPreconditionFailed, ErrCodePreconditionFailed, err.Error())
 		default:
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		}
 		return
 	}
@@ -1208,7 +2465,7 @@ func (h *Handler) handleGetExportCSV(w http.ResponseWriter, r *http.Request) {
 // handleGetFragmentNodes handles /internal/fragment/nodes requests.
 func (h *Handler) handleGetFragmentNodes(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
 	q := r.URL.Query()
@@ -1217,18 +2474,19 @@ func (h *Handler) handleGetFragmentNodes(w http.ResponseWriter, r *http.Request)
 	// Read shard parameter.
 	shard, err := strconv.ParseUint(q.Get("shard"), 10, 64)
 	if err != nil {
-		http.Error(w, "shard should be an unsigned integer", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "shard should be an unsigned integer")
 		return
 	}
 
 	// Retrieve fragment owner nodes.
 	nodes, err := h.api.ShardNodes(r.Context(), index, shard)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 		return
 	}
 
 	// Write to response.
+	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(nodes); err != nil {
 		h.logger.Printf("json write error: %s", err)
 	}
@@ -1237,7 +2495,7 @@ func (h *Handler) handleGetFragmentNodes(w http.ResponseWriter, r *http.Request)
 // handleGetNodes handles /internal/nodes requests.
 func (h *Handler) handleGetNodes(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
 
@@ -1245,6 +2503,7 @@ func (h *Handler) handleGetNodes(w http.ResponseWriter, r *http.Request) {
 	nodes := h.api.Hosts(r.Context())
 
 	// Write to response.
+	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(nodes); err != nil {
 		h.logger.Printf("json write error: %s", err)
 	}
@@ -1255,11 +2514,11 @@ func (h *Handler) handleGetFragmentBlockData(w http.ResponseWriter, r *http.Requ
 	buf, err := h.api.FragmentBlockData(r.Context(), r.Body)
 	if err != nil {
 		if _, ok := err.(pilosa.BadRequestError); ok {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 		} else if errors.Cause(err) == pilosa.ErrFragmentNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, err.Error())
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		}
 		return
 	}
@@ -1276,28 +2535,29 @@ func (h *Handler) handleGetFragmentBlockData(w http.ResponseWriter, r *http.Requ
 // handleGetFragmentBlocks handles GET /internal/fragment/blocks requests.
 func (h *Handler) handleGetFragmentBlocks(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
 	// Read shard parameter.
 	q := r.URL.Query()
 	shard, err := strconv.ParseUint(q.Get("shard"), 10, 64)
 	if err != nil {
-		http.Error(w, "shard required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "shard required")
 		return
 	}
 
 	blocks, err := h.api.FragmentBlocks(r.Context(), q.Get("index"), q.Get("field"), q.Get("view"), shard)
 	if err != nil {
 		if errors.Cause(err) == pilosa.ErrFragmentNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, err.Error())
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		}
 		return
 	}
 
 	// Encode response.
+	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(getFragmentBlocksResponse{
 		Blocks: blocks,
 	}); err != nil {
@@ -1315,13 +2575,13 @@ func (h *Handler) handleGetFragmentData(w http.ResponseWriter, r *http.Request)
 	q := r.URL.Query()
 	shard, err := strconv.ParseUint(q.Get("shard"), 10, 64)
 	if err != nil {
-		http.Error(w, "shard required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "shard required")
 		return
 	}
 	// Retrieve fragment data from holder.
 	f, err := h.api.FragmentData(r.Context(), q.Get("index"), q.Get("field"), q.Get("view"), shard)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, err.Error())
 		return
 	}
 	// Stream fragment to response body.
@@ -1333,9 +2593,10 @@ func (h *Handler) handleGetFragmentData(w http.ResponseWriter, r *http.Request)
 // handleGetVersion handles /version requests.
 func (h *Handler) handleGetVersion(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
 	err := json.NewEncoder(w).Encode(struct {
 		Version string `json:"version"`
 	}{
@@ -1374,27 +2635,28 @@ func parseUint64Slice(s string) ([]uint64, error) {
 
 func (h *Handler) handlePostClusterResizeSetCoordinator(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
 	// Decode request.
 	var req setCoordinatorRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		http.Error(w, "decoding request "+err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "decoding request "+err.Error())
 		return
 	}
 
 	oldNode, newNode, err := h.api.SetCoordinator(r.Context(), req.ID)
 	if err != nil {
 		if errors.Cause(err) == pilosa.ErrNodeIDNotExists {
-			http.Error(w, "setting new coordinator: "+err.Error(), http.StatusNotFound)
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, "setting new coordinator: "+err.Error())
 		} else {
-			http.Error(w, "setting new coordinator: "+err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, "setting new coordinator: "+err.Error())
 		}
 		return
 	}
 	// Encode response.
+	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(setCoordinatorResponse{
 		Old: oldNode,
 		New: newNode,
@@ -1415,28 +2677,29 @@ type setCoordinatorResponse struct {
 // handlePostClusterResizeRemoveNode handles POST /cluster/resize/remove-node request.
 func (h *Handler) handlePostClusterResizeRemoveNode(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
 	// Decode request.
 	var req removeNodeRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 		return
 	}
 
 	removeNode, err := h.api.RemoveNode(req.ID)
 	if err != nil {
 		if errors.Cause(err) == pilosa.ErrNodeIDNotExists {
-			http.Error(w, "removing node: "+err.Error(), http.StatusNotFound)
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, "removing node: "+err.Error())
 		} else {
-			http.Error(w, "removing node: "+err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, "removing node: "+err.Error())
 		}
 		return
 	}
 
 	// Encode response.
+	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(removeNodeResponse{
 		Remove: removeNode,
 	}); err != nil {
@@ -1455,7 +2718,7 @@ type removeNodeResponse struct {
 // handlePostClusterResizeAbort handles POST /cluster/resize/abort request.
 func (h *Handler) handlePostClusterResizeAbort(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
 	err := h.api.ResizeAbort()
@@ -1463,16 +2726,17 @@ func (h *Handler) handlePostClusterResizeAbort(w http.ResponseWriter, r *http.Re
 	if err != nil {
 		switch errors.Cause(err) {
 		case pilosa.ErrNodeNotCoordinator:
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 			return
 		case pilosa.ErrResizeNotRunning:
 			msg = err.Error()
 		default:
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 			return
 		}
 	}
 	// Encode response.
+	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(clusterResizeAbortResponse{
 		Info: msg,
 	}); err != nil {
@@ -1487,30 +2751,63 @@ type clusterResizeAbortResponse struct {
 func (h *Handler) handleRecalculateCaches(w http.ResponseWriter, r *http.Request) {
 	err := h.api.RecalculateCaches(r.Context())
 	if err != nil {
-		http.Error(w, "recalculating caches: "+err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "recalculating caches: "+err.Error())
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// handlePostClusterDrain handles POST /cluster/drain requests, which mark the
+// node as draining.
+func (h *Handler) handlePostClusterDrain(w http.ResponseWriter, r *http.Request) {
+	if err := h.api.SetDraining(r.Context(), true); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "draining: "+err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDeleteClusterDrain handles DELETE /cluster/drain requests, which
+// return a draining node to normal service.
+func (h *Handler) handleDeleteClusterDrain(w http.ResponseWriter, r *http.Request) {
+	if err := h.api.SetDraining(r.Context(), false); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "undraining: "+err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeDrainingHeader writes a 503 status with a Retry-After header for a
+// request rejected because the node is draining.
+func (h *Handler) writeDrainingHeader(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", h.retryAfterSeconds())
+	w.WriteHeader(http.StatusServiceUnavailable)
+}
+
+// retryAfterSeconds returns the drain Retry-After value in whole seconds.
+func (h *Handler) retryAfterSeconds() string {
+	return strconv.Itoa(int((h.drainRetryAfter + time.Second - 1) / time.Second))
+}
+
 func (h *Handler) handlePostClusterMessage(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
-		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "JSON only acceptable response")
 		return
 	}
 	// Verify that request is only communicating over protobufs.
 	if r.Header.Get("Content-Type") != "application/x-protobuf" {
-		http.Error(w, "Unsupported media type", http.StatusUnsupportedMediaType)
+		writeError(w, http.StatusUnsupportedMediaType, ErrCodeUnsupportedMediaType, "Unsupported media type")
 		return
 	}
 
 	err := h.api.ClusterMessage(r.Context(), r.Body)
 	if err != nil {
 		// TODO this was the previous behavior, but perhaps not everything is a bad request
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(defaultClusterMessageResponse{}); err != nil {
 		h.logger.Printf("response encoding error: %s", err)
 	}
@@ -1522,22 +2819,23 @@ func (h *Handler) handlePostTranslateData(w http.ResponseWriter, r *http.Request
 	// Parse offsets for all indexes and fields from POST body.
 	offsets := make(pilosa.TranslateOffsetMap)
 	if err := json.NewDecoder(r.Body).Decode(&offsets); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 
 	// Stream all translation data.
 	rd, err := h.api.GetTranslateEntryReader(r.Context(), offsets)
 	if errors.Cause(err) == pilosa.ErrNotImplemented {
-		http.Error(w, err.Error(), http.StatusNotImplemented)
+		writeError(w, http.StatusNotImplemented, ErrCodeNotImplemented, err.Error())
 		return
 	} else if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 	defer rd.Close()
 
 	// Flush header so client can continue.
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.(http.Flusher).Flush()
 
@@ -1612,6 +2910,9 @@ func GetHTTPClient(t *tls.Config) *http.Client {
 		IdleConnTimeout:       90 * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
+		// Query responses are gzip compressed for clients that accept it;
+		// don't spend CPU compressing responses between nodes.
+		DisableCompression: true,
 	}
 	if t != nil {
 		transport.TLSClientConfig = t
@@ -1623,10 +2924,10 @@ func GetHTTPClient(t *tls.Config) *http.Client {
 func (h *Handler) handlePostImportRoaring(w http.ResponseWriter, r *http.Request) {
 	// Verify that request is only communicating over protobufs.
 	if r.Header.Get("Content-Type") != "application/x-protobuf" {
-		http.Error(w, "Unsupported media type", http.StatusUnsupportedMediaType)
+		writeError(w, http.StatusUnsupportedMediaType, ErrCodeUnsupportedMediaType, "Unsupported media type")
 		return
 	} else if r.Header.Get("Accept") != "application/x-protobuf" {
-		http.Error(w, "Not acceptable", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "Not acceptable")
 		return
 	}
 
@@ -1648,7 +2949,7 @@ func (h *Handler) handlePostImportRoaring(w http.ResponseWriter, r *http.Request
 	span.LogKV("bodySize", len(body))
 	span.Finish()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 		return
 	}
 
@@ -1657,14 +2958,14 @@ func (h *Handler) handlePostImportRoaring(w http.ResponseWriter, r *http.Request
 	err = h.api.Serializer.Unmarshal(body, req)
 	span.Finish()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 		return
 	}
 
 	urlVars := mux.Vars(r)
 	shard, err := strconv.ParseUint(urlVars["shard"], 10, 64)
 	if err != nil {
-		http.Error(w, "shard should be an unsigned integer", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "shard should be an unsigned integer")
 		return
 	}
 
@@ -1675,6 +2976,10 @@ func (h *Handler) handlePostImportRoaring(w http.ResponseWriter, r *http.Request
 		resp.Err = err.Error()
 		if _, ok := err.(pilosa.BadRequestError); ok {
 			w.WriteHeader(http.StatusBadRequest)
+		} else if errors.Cause(err) == pilosa.ErrDraining {
+			h.writeDrainingHeader(w)
+		} else if errors.Cause(err) == pilosa.ErrNodeStarting {
+			w.WriteHeader(http.StatusServiceUnavailable)
 		} else {
 			w.WriteHeader(http.StatusInternalServerError)
 		}
@@ -1683,7 +2988,7 @@ func (h *Handler) handlePostImportRoaring(w http.ResponseWriter, r *http.Request
 	// Marshal response object.
 	buf, err := h.api.Serializer.Marshal(resp)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("marshal import response: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("marshal import response: %v", err))
 		return
 	}
 
@@ -1698,16 +3003,16 @@ func (h *Handler) handlePostImportRoaring(w http.ResponseWriter, r *http.Request
 func (h *Handler) handlePostTranslateKeys(w http.ResponseWriter, r *http.Request) {
 	// Verify that request is only communicating over protobufs.
 	if r.Header.Get("Content-Type") != "application/x-protobuf" {
-		http.Error(w, "Unsupported media type", http.StatusUnsupportedMediaType)
+		writeError(w, http.StatusUnsupportedMediaType, ErrCodeUnsupportedMediaType, "Unsupported media type")
 		return
 	} else if r.Header.Get("Accept") != "application/x-protobuf" {
-		http.Error(w, "Not acceptable", http.StatusNotAcceptable)
+		writeError(w, http.StatusNotAcceptable, ErrCodeNotAcceptable, "Not acceptable")
 		return
 	}
 
 	buf, err := h.api.TranslateKeys(r.Body)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("translate keys: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("translate keys: %v", err))
 	}
 
 	// Write response.
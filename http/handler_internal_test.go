@@ -17,11 +17,15 @@ package http
 import (
 	"bytes"
 	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/pilosa/pilosa/v2"
+	"github.com/pilosa/pilosa/v2/logger"
 )
 
 // Test custom UnmarshalJSON for postIndexRequest object
@@ -178,3 +182,93 @@ func TestFieldOptionValidation(t *testing.T) {
 		}
 	}
 }
+
+// Ensure streamed JSON query responses match encoding the response directly.
+func TestWriteJSONQueryResponse(t *testing.T) {
+	// A row spanning a few shards, with sparse and dense containers.
+	row := pilosa.NewRow()
+	for col := uint64(0); col < 3*pilosa.ShardWidth; col += 7 {
+		row.SetBit(col)
+	}
+	for col := uint64(pilosa.ShardWidth + 1000); col < pilosa.ShardWidth+200000; col++ {
+		row.SetBit(col)
+	}
+	row.Attrs = map[string]interface{}{"a": "<b>", "n": int64(3)}
+
+	keyed := pilosa.NewRow(1, 2)
+	keyed.Keys = []string{"x", "y"}
+
+	tests := map[string]*pilosa.QueryResponse{
+		"Row":      {Results: []interface{}{row}},
+		"Mixed":    {Results: []interface{}{uint64(10), pilosa.NewRow(), row, true, keyed, nil}},
+		"Attrs":    {Results: []interface{}{row}, ColumnAttrSets: []*pilosa.ColumnAttrSet{{ID: 1, Attrs: map[string]interface{}{"c": "d"}}}},
+		"Empty":    {Results: []interface{}{}},
+		"Nil":      {},
+		"NilRow":   {Results: []interface{}{(*pilosa.Row)(nil)}},
+		"PairsRow": {Results: []interface{}{[]pilosa.Pair{{ID: 1, Count: 2}}, row}},
+	}
+	for name, resp := range tests {
+		t.Run(name, func(t *testing.T) {
+			var exp bytes.Buffer
+			if err := json.NewEncoder(&exp).Encode(resp); err != nil {
+				t.Fatal(err)
+			}
+			var got bytes.Buffer
+			if err := (&Handler{}).writeJSONQueryResponse(&got, resp); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Bytes(), exp.Bytes()) {
+				t.Fatalf("streamed response differs: got %d bytes, expected %d bytes", got.Len(), exp.Len())
+			}
+		})
+	}
+}
+
+// Ensure a panicking handler is answered with a 500 and doesn't stop the
+// server.
+func TestHandler_RecoverPanic(t *testing.T) {
+	h := &Handler{logger: logger.NopLogger}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		var m map[string]interface{}
+		_ = m["x"].(string)
+	})
+	mux.HandleFunc("/partial", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("partial"))
+		panic("late")
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	srv := httptest.NewServer(h.recoverPanic(mux))
+	defer srv.Close()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(body)
+	}
+
+	if code, body := get("/panic"); code != http.StatusInternalServerError {
+		t.Fatalf("unexpected status code: %d", code)
+	} else if !strings.HasPrefix(body, `{"error":{"code":"internal","message":"internal error: interface conversion`) {
+		t.Fatalf("unexpected body: %s", body)
+	}
+
+	// A response which has already started is left as it is.
+	if code, body := get("/partial"); code != http.StatusOK || body != "partial" {
+		t.Fatalf("unexpected response: %d %s", code, body)
+	}
+
+	if code, body := get("/ok"); code != http.StatusOK || body != "ok" {
+		t.Fatalf("unexpected response after panic: %d %s", code, body)
+	}
+}
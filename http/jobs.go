@@ -0,0 +1,106 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pilosa/pilosa/v2"
+	uuid "github.com/satori/go.uuid"
+)
+
+// queryJob is a query run in the background for an async query request.
+type queryJob struct {
+	done     bool
+	resp     pilosa.QueryResponse
+	err      error
+	finished time.Time
+}
+
+// queryJobs tracks async query jobs. The result of a finished job is kept
+// for ttl, after which it is evicted. At most max jobs run at once.
+type queryJobs struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	running int
+	jobs    map[string]*queryJob
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+}
+
+// newQueryJobs returns a queryJobs which keeps results for ttl and runs at
+// most max jobs at once. A max of zero means no limit.
+func newQueryJobs(ttl time.Duration, max int) *queryJobs {
+	return &queryJobs{
+		ttl:  ttl,
+		max:  max,
+		jobs: make(map[string]*queryJob),
+		now:  time.Now,
+	}
+}
+
+// start runs fn in a new goroutine and returns the id of its job. It returns
+// false, without running fn, if max jobs are already running.
+func (q *queryJobs) start(fn func() (pilosa.QueryResponse, error)) (string, bool) {
+	q.mu.Lock()
+	if q.max > 0 && q.running >= q.max {
+		q.mu.Unlock()
+		return "", false
+	}
+	id := uuid.NewV4().String()
+	q.evict()
+	job := &queryJob{}
+	q.jobs[id] = job
+	q.running++
+	q.mu.Unlock()
+
+	go func() {
+		resp, err := fn()
+
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		job.done, job.resp, job.err, job.finished = true, resp, err, q.now()
+		q.running--
+	}()
+
+	return id, true
+}
+
+// get returns a copy of the job with the given id, and false if there is no
+// such job or its result has been evicted.
+func (q *queryJobs) get(id string) (queryJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.evict()
+	job, ok := q.jobs[id]
+	if !ok {
+		return queryJob{}, false
+	}
+	return *job, true
+}
+
+// evict removes finished jobs whose results have outlived the ttl. The caller
+// must hold q.mu.
+func (q *queryJobs) evict() {
+	now := q.now()
+	for id, job := range q.jobs {
+		if job.done && now.Sub(job.finished) > q.ttl {
+			delete(q.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pilosa/pilosa/v2"
+)
+
+func TestQueryJobs(t *testing.T) {
+	var mu sync.Mutex
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	jobs := newQueryJobs(time.Minute, 0)
+	jobs.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+
+	release := make(chan struct{})
+	id, _ := jobs.start(func() (pilosa.QueryResponse, error) {
+		<-release
+		return pilosa.QueryResponse{Results: []interface{}{uint64(3)}}, nil
+	})
+
+	// The job is pending until the query returns, however long that takes.
+	if job, ok := jobs.get(id); !ok || job.done {
+		t.Fatalf("expected pending job, got: %+v, %v", job, ok)
+	}
+	advance(time.Hour)
+	if job, ok := jobs.get(id); !ok || job.done {
+		t.Fatalf("expected pending job after an hour, got: %+v, %v", job, ok)
+	}
+
+	close(release)
+	var job queryJob
+	for deadline := time.Now().Add(10 * time.Second); !job.done; {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for job to finish")
+		}
+		time.Sleep(time.Millisecond)
+		job, _ = jobs.get(id)
+	}
+	if job.err != nil || !reflect.DeepEqual(job.resp.Results, []interface{}{uint64(3)}) {
+		t.Fatalf("unexpected job result: %+v", job)
+	}
+
+	// The result is kept for the ttl, and then evicted.
+	advance(time.Minute)
+	if _, ok := jobs.get(id); !ok {
+		t.Fatal("expected job to be kept for the ttl")
+	}
+	advance(time.Second)
+	if _, ok := jobs.get(id); ok {
+		t.Fatal("expected job to be evicted after the ttl")
+	}
+
+	if _, ok := jobs.get("unknown"); ok {
+		t.Fatal("expected unknown job not to be found")
+	}
+}
+
+func TestQueryJobs_Max(t *testing.T) {
+	jobs := newQueryJobs(time.Minute, 2)
+
+	release := make(chan struct{})
+	fn := func() (pilosa.QueryResponse, error) {
+		<-release
+		return pilosa.QueryResponse{}, nil
+	}
+	var ids []string
+	for i := 0; i < 2; i++ {
+		id, ok := jobs.start(fn)
+		if !ok {
+			t.Fatalf("expected job %d to start", i)
+		}
+		ids = append(ids, id)
+	}
+	if _, ok := jobs.start(fn); ok {
+		t.Fatal("expected job over the limit not to start")
+	}
+
+	// Once the running jobs finish, new jobs may start again.
+	close(release)
+	for _, id := range ids {
+		for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(time.Millisecond) {
+			if job, _ := jobs.get(id); job.done {
+				break
+			} else if time.Now().After(deadline) {
+				t.Fatal("timed out waiting for job to finish")
+			}
+		}
+	}
+	if _, ok := jobs.start(fn); !ok {
+		t.Fatal("expected job to start once others finished")
+	}
+}
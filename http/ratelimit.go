@@ -0,0 +1,94 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter limits the rate of requests from each client with a token
+// bucket per client. A bucket holds up to one second's worth of requests, so
+// an idle client may send a burst of that size.
+type rateLimiter struct {
+	mu      sync.Mutex
+	perSec  float64
+	buckets map[string]*tokenBucket
+
+	// lastSweep is when idle buckets were last removed.
+	lastSweep time.Time
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+}
+
+// tokenBucket holds the tokens available to a client as of last.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a rateLimiter which allows perSec requests per
+// second from each client.
+func newRateLimiter(perSec int) *rateLimiter {
+	return &rateLimiter{
+		perSec:  float64(perSec),
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// allow takes a token from the bucket for key. If the bucket is empty, it
+// returns false and how long until a token is available.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.perSec, last: now}
+		l.buckets[key] = b
+	}
+
+	// Refill the bucket for the time since it was last used.
+	b.tokens += now.Sub(b.last).Seconds() * l.perSec
+	if b.tokens > l.perSec {
+		b.tokens = l.perSec
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.perSec * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep removes the buckets of clients which have been idle long enough for
+// their buckets to refill, at most once a minute. The caller must hold l.mu.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= time.Second {
+			delete(l.buckets, key)
+		}
+	}
+}
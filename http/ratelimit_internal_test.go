@@ -0,0 +1,54 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(4)
+	l.now = func() time.Time { return now }
+
+	// An idle client may send a second's worth of requests at once.
+	for i := 0; i < 4; i++ {
+		if ok, _ := l.allow("a"); !ok {
+			t.Fatalf("request %d: expected to be allowed", i)
+		}
+	}
+	if ok, wait := l.allow("a"); ok {
+		t.Fatal("expected request over the limit to be rejected")
+	} else if wait != 250*time.Millisecond {
+		t.Fatalf("unexpected wait: %s", wait)
+	}
+
+	// Tokens are refilled at the rate limit.
+	now = now.Add(250 * time.Millisecond)
+	if ok, _ := l.allow("a"); !ok {
+		t.Fatal("expected request to be allowed after refill")
+	} else if ok, _ := l.allow("a"); ok {
+		t.Fatal("expected second request to be rejected after refill of one token")
+	}
+
+	// Idle clients are forgotten.
+	now = now.Add(time.Minute)
+	if ok, _ := l.allow("b"); !ok {
+		t.Fatal("expected request from other client to be allowed")
+	} else if _, ok := l.buckets["a"]; ok {
+		t.Fatal("expected idle client's bucket to be removed")
+	}
+}
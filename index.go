@@ -59,6 +59,7 @@ type Index struct {
 
 	logger        logger.Logger
 	snapshotQueue chan *fragment
+	backend       Storage
 
 	// Used for notifying holder when a field is added.
 	holder *Holder
@@ -463,6 +464,7 @@ func (i *Index) newField(path, name string) (*Field, error) {
 	f.broadcaster = i.broadcaster
 	f.rowAttrStore = i.newAttrStore(filepath.Join(f.path, ".data"))
 	f.snapshotQueue = i.snapshotQueue
+	f.backend = i.backend
 	f.OpenTranslateStore = i.OpenTranslateStore
 	return f, nil
 }
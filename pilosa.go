@@ -66,6 +66,18 @@ var (
 	ErrNodeNotCoordinator = errors.New("node is not the coordinator")
 	ErrResizeNotRunning   = errors.New("no resize job currently running")
 
+	// ErrDraining is returned for client requests to a node which is being
+	// drained before it is removed from the cluster.
+	ErrDraining = errors.New("node is draining")
+
+	// ErrNodeStarting is returned for queries and imports sent to a node
+	// which hasn't finished loading its data.
+	ErrNodeStarting = errors.New("node is starting")
+
+	// ErrWarmingUp is returned for queries rejected to limit the rate of
+	// queries accepted while a node warms up after starting.
+	ErrWarmingUp = errors.New("node is warming up")
+
 	ErrNotImplemented            = errors.New("not implemented")
 	ErrFieldsArgumentRequired    = errors.New("fields argument required")
 	ErrExpectedFieldListArgument = errors.New("expected field list argument")
@@ -15,6 +15,13 @@
 package pilosa
 
 import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -63,3 +70,62 @@ func (s *memAttrStore) SetBulkAttrs(m map[uint64]map[string]interface{}) error {
 }
 func (s *memAttrStore) Blocks() ([]AttrBlock, error)                                  { return nil, nil }
 func (s *memAttrStore) BlockData(i uint64) (map[uint64]map[string]interface{}, error) { return nil, nil }
+
+// memStorage represents an in-memory implementation of the Storage interface.
+type memStorage struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMemStorage() *memStorage { return &memStorage{data: make(map[string][]byte)} }
+
+func (s *memStorage) Create(key string) (io.WriteCloser, error) {
+	return &memStorageWriter{s: s, key: key}, nil
+}
+
+func (s *memStorage) Open(key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buf, ok := s.data[key]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: key, Err: os.ErrNotExist}
+	}
+	return ioutil.NopCloser(bytes.NewReader(buf)), nil
+}
+
+func (s *memStorage) List(prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for key := range s.data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *memStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data[key]; !ok {
+		return &os.PathError{Op: "remove", Path: key, Err: os.ErrNotExist}
+	}
+	delete(s.data, key)
+	return nil
+}
+
+// memStorageWriter buffers writes and stores them on Close.
+type memStorageWriter struct {
+	bytes.Buffer
+	s   *memStorage
+	key string
+}
+
+func (w *memStorageWriter) Close() error {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+	w.s.data[w.key] = w.Bytes()
+	return nil
+}
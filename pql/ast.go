@@ -243,6 +243,29 @@ func (q *Query) WriteCallN() int {
 	return n
 }
 
+// writeCalls are the names of calls which modify data.
+var writeCalls = map[string]struct{}{
+	"Set":            {},
+	"Clear":          {},
+	"ClearRow":       {},
+	"Store":          {},
+	"Materialize":    {},
+	"Refresh":        {},
+	"SetRowAttrs":    {},
+	"SetColumnAttrs": {},
+}
+
+// HasWriteCall returns true if any call in the query, at any depth, modifies
+// data.
+func (q *Query) HasWriteCall() bool {
+	for _, call := range q.Calls {
+		if call.HasWriteCall() {
+			return true
+		}
+	}
+	return false
+}
+
 // String returns a string representation of the query.
 func (q *Query) String() string {
 	a := make([]string, len(q.Calls))
@@ -477,6 +500,25 @@ func (c *Call) HasConditionArg() bool {
 	return false
 }
 
+// HasWriteCall returns true if c, its children, or a call passed as one of
+// its arguments modifies data.
+func (c *Call) HasWriteCall() bool {
+	if _, ok := writeCalls[c.Name]; ok {
+		return true
+	}
+	for _, child := range c.Children {
+		if child.HasWriteCall() {
+			return true
+		}
+	}
+	for _, v := range c.Args {
+		if call, ok := v.(*Call); ok && call.HasWriteCall() {
+			return true
+		}
+	}
+	return false
+}
+
 // Condition represents an operation & value.
 // When used in an argument map it represents a binary expression.
 type Condition struct {
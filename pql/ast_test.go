@@ -67,3 +67,35 @@ func TestCondition_Value(t *testing.T) {
 		}
 	})
 }
+
+// Ensure write calls are found at any depth of a query.
+func TestQuery_HasWriteCall(t *testing.T) {
+	for _, tt := range []struct {
+		query string
+		exp   bool
+	}{
+		{`Row(f=1)`, false},
+		{`Count(Intersect(Row(f=1), Row(g=2)))`, false},
+		{`GroupBy(Rows(f), filter=Row(g=1))`, false},
+		{`PreviewSet(Row(f=1), f=2)`, false},
+		{`Set(1, f=1)`, true},
+		{`Clear(1, f=1)`, true},
+		{`ClearRow(f=1)`, true},
+		{`Store(Row(f=1), f=2)`, true},
+		{`Materialize(Row(f=1), f=2)`, true},
+		{`Refresh(f=2)`, true},
+		{`SetRowAttrs(f, 1, x=1)`, true},
+		{`SetColumnAttrs(1, x=1)`, true},
+		{`Row(f=1) ClearRow(f=1)`, true},
+		{`Count(Store(Row(f=1), f=2))`, true},
+		{`Options(ClearRow(f=1), shards=[0])`, true},
+		{`GroupBy(Rows(f), filter=Store(Row(f=1), f=2))`, true},
+	} {
+		q, err := pql.ParseString(tt.query)
+		if err != nil {
+			t.Fatalf("parsing %s: %v", tt.query, err)
+		} else if got := q.HasWriteCall(); got != tt.exp {
+			t.Errorf("%s: got %v, expected %v", tt.query, got, tt.exp)
+		}
+	}
+}
@@ -326,6 +326,40 @@ func (r *Row) Columns() []uint64 {
 	return a
 }
 
+// ColumnsPage returns up to limit columns of r, in order, after skipping the
+// first offset columns. Segments which fall entirely before offset are
+// skipped using their counts. A limit of zero returns every column after
+// offset.
+func (r *Row) ColumnsPage(offset, limit uint64) []uint64 {
+	a := []uint64{}
+	for i := range r.segments {
+		if n := r.segments[i].Count(); offset >= n {
+			offset -= n
+			continue
+		}
+		itr := r.segments[i].data.Iterator()
+		for v, eof := itr.Next(); !eof; v, eof = itr.Next() {
+			if offset > 0 {
+				offset--
+				continue
+			}
+			a = append(a, v)
+			if uint64(len(a)) == limit {
+				return a
+			}
+		}
+	}
+	return a
+}
+
+// ForEach executes fn for each column in r, in order. Unlike Columns, it
+// doesn't allocate a slice of every column.
+func (r *Row) ForEach(fn func(uint64)) {
+	for i := range r.segments {
+		r.segments[i].data.ForEach(fn)
+	}
+}
+
 // rowSegment holds a subset of a row.
 // This could point to a mmapped roaring bitmap or an in-memory bitmap. The
 // width of the segment will always match the shard width.
@@ -54,6 +54,7 @@ type Server struct { // nolint: maligned
 	diagnostics      *diagnosticsCollector
 	executor         *executor
 	executorPoolSize int
+	importPoolSize   int
 	hosts            []string
 	clusterDisabled  bool
 	serializer       Serializer
@@ -69,6 +70,9 @@ type Server struct { // nolint: maligned
 	metricInterval      time.Duration
 	diagnosticInterval  time.Duration
 	maxWritesPerRequest int
+	maxRangeBuckets     int
+	queryTimeout        time.Duration
+	setValidation       SetValidation
 	isCoordinator       bool
 	syncer              holderSyncer
 
@@ -122,6 +126,15 @@ func OptServerAttrStoreFunc(af func(string) AttrStore) ServerOption {
 	}
 }
 
+// OptServerStorage is a functional option on Server
+// used to specify where fragment caches are persisted.
+func OptServerStorage(st Storage) ServerOption {
+	return func(s *Server) error {
+		s.holder.Storage = st
+		return nil
+	}
+}
+
 // OptServerAntiEntropyInterval is a functional option on Server
 // used to set the anti-entropy interval.
 func OptServerAntiEntropyInterval(interval time.Duration) ServerOption {
@@ -149,6 +162,33 @@ func OptServerMaxWritesPerRequest(n int) ServerOption {
 	}
 }
 
+// OptServerMaxRangeBuckets is a functional option on Server
+// used to set the maximum number of time buckets a range query may cover.
+func OptServerMaxRangeBuckets(n int) ServerOption {
+	return func(s *Server) error {
+		s.maxRangeBuckets = n
+		return nil
+	}
+}
+
+// OptServerQueryTimeout is a functional option on Server
+// used to set the maximum time a query may run for.
+func OptServerQueryTimeout(d time.Duration) ServerOption {
+	return func(s *Server) error {
+		s.queryTimeout = d
+		return nil
+	}
+}
+
+// OptServerSetValidation is a functional option on Server
+// used to set the checks on the row and column IDs of Set() calls and imports.
+func OptServerSetValidation(v SetValidation) ServerOption {
+	return func(s *Server) error {
+		s.setValidation = v
+		return nil
+	}
+}
+
 // OptServerMetricInterval is a functional option on Server
 // used to set the interval between metric samples.
 func OptServerMetricInterval(dur time.Duration) ServerOption {
@@ -193,6 +233,15 @@ func OptServerExecutorPoolSize(size int) ServerOption {
 	}
 }
 
+// OptServerImportWorkerPoolSize is a functional option on Server
+// used to set the number of goroutines processing importRoaring jobs.
+func OptServerImportWorkerPoolSize(size int) ServerOption {
+	return func(s *Server) error {
+		s.importPoolSize = size
+		return nil
+	}
+}
+
 // OptServerPrimaryTranslateStore has been deprecated.
 func OptServerPrimaryTranslateStore(store TranslateStore) ServerOption {
 	return func(s *Server) error {
@@ -322,12 +371,14 @@ func NewServer(opts ...ServerOption) (*Server, error) {
 		}
 	}
 
-	// set up executor after server opts have been processed
-	executorOpts := []executorOption{optExecutorInternalQueryClient(s.defaultClient)}
-	if s.executorPoolSize > 0 {
-		executorOpts = append(executorOpts, optExecutorWorkerPoolSize(s.executorPoolSize))
-	}
-	s.executor = newExecutor(executorOpts...)
+	// Resolve worker pool sizes in one place so that every pool is sized
+	// the same way, then set up executor after server opts have been processed.
+	s.executorPoolSize = resolvePoolSize(s.executorPoolSize)
+	s.importPoolSize = resolvePoolSize(s.importPoolSize)
+	s.executor = newExecutor(
+		optExecutorInternalQueryClient(s.defaultClient),
+		optExecutorWorkerPoolSize(s.executorPoolSize),
+	)
 
 	// s.holder.translateFile.logger = s.logger
 
@@ -373,6 +424,9 @@ func NewServer(opts ...ServerOption) (*Server, error) {
 	s.executor.Node = node
 	s.executor.Cluster = s.cluster
 	s.executor.MaxWritesPerRequest = s.maxWritesPerRequest
+	s.executor.MaxRangeBuckets = s.maxRangeBuckets
+	s.executor.QueryTimeout = s.queryTimeout
+	s.executor.SetValidation = s.setValidation
 	s.cluster.broadcaster = s
 	s.cluster.maxWritesPerRequest = s.maxWritesPerRequest
 	s.holder.broadcaster = s
@@ -389,6 +443,30 @@ func (s *Server) InternalClient() InternalClient {
 	return s.defaultClient
 }
 
+// maxPoolSizePerCPU bounds the size of each worker pool relative to the
+// number of CPUs.
+const maxPoolSizePerCPU = 16
+
+// resolvePoolSize returns the worker pool size to use for a configured size.
+// Zero (or less) defaults to runtime.NumCPU(), and sizes are clamped to at
+// most maxPoolSizePerCPU goroutines per CPU.
+func resolvePoolSize(size int) int {
+	numCPU := runtime.NumCPU()
+	if size <= 0 {
+		return numCPU
+	} else if max := numCPU * maxPoolSizePerCPU; size > max {
+		return max
+	}
+	return size
+}
+
+// concurrencyInfo reports the resolved worker pool sizes.
+type concurrencyInfo struct {
+	NumCPU           int `json:"numCPU"`
+	ExecutorPoolSize int `json:"executorPoolSize"`
+	ImportPoolSize   int `json:"importPoolSize"`
+}
+
 // UpAndDown brings the server up minimally and shuts it down
 // again; basically, it exists for testing holder open and close.
 func (s *Server) UpAndDown() error {
@@ -416,6 +494,7 @@ func (s *Server) UpAndDown() error {
 // Open opens and initializes the server.
 func (s *Server) Open() error {
 	s.logger.Printf("open server")
+	s.logger.Printf("worker pools: cpus=%d executor=%d import=%d", runtime.NumCPU(), s.executorPoolSize, s.importPoolSize)
 
 	// Log startup
 	err := s.holder.logStartup()
@@ -63,6 +63,30 @@ type Config struct {
 	// SetRowAttrs & SetColumnAttrs.
 	MaxWritesPerRequest int `toml:"max-writes-per-request"`
 
+	// MaxRangeBuckets limits the number of time buckets a time range in a
+	// query may expand to. Zero means no limit.
+	MaxRangeBuckets int `toml:"max-range-buckets"`
+
+	// QueryTimeout is how long a query may run for before it is aborted.
+	// Zero means no limit.
+	QueryTimeout toml.Duration `toml:"query-timeout"`
+
+	// MaxImportBatch limits the number of columns which can be sent in a
+	// single import request. Zero means no limit.
+	MaxImportBatch int `toml:"max-import-batch"`
+
+	// ShutdownTimeout is how long to wait for in-flight HTTP requests to
+	// complete on shutdown before the rest of the server is closed.
+	ShutdownTimeout toml.Duration `toml:"shutdown-timeout"`
+
+	// DrainRetryAfter is the Retry-After sent to clients whose requests are
+	// rejected while the node is draining.
+	DrainRetryAfter toml.Duration `toml:"drain-retry-after"`
+
+	// WarmupPeriod is the period over which the share of client queries
+	// accepted by a node ramps up once it is ready. Zero disables the ramp.
+	WarmupPeriod toml.Duration `toml:"warmup-period"`
+
 	// LogPath configures where Pilosa will write logs.
 	LogPath string `toml:"log-path"`
 
@@ -73,8 +97,52 @@ type Config struct {
 	Handler struct {
 		// CORS Allowed Origins
 		AllowedOrigins []string `toml:"allowed-origins"`
+		// ExcludeColumns excludes columns from row results of queries which
+		// don't set the excludeColumns query argument.
+		ExcludeColumns bool `toml:"exclude-columns"`
+		// PageLimit is the number of columns returned for each row when a
+		// query sets the offset argument but not limit. Zero means no
+		// limit.
+		PageLimit uint64 `toml:"page-limit"`
+		// QueryResultTTL is how long the result of an async query is kept
+		// once the query has finished.
+		QueryResultTTL toml.Duration `toml:"query-result-ttl"`
+		// MaxAsyncQueries limits the number of async queries running at
+		// once. Zero means no limit.
+		MaxAsyncQueries int `toml:"max-async-queries"`
+		// AsyncQueryTimeout is the maximum time an async query may run
+		// for. Zero means no limit.
+		AsyncQueryTimeout toml.Duration `toml:"async-query-timeout"`
+		// RateLimitPerSec limits the number of import and write query
+		// requests per second accepted from each client IP. Zero means no
+		// limit.
+		RateLimitPerSec int `toml:"rate-limit-per-sec"`
+		// APIKey is the bearer token required by requests which modify
+		// data. Empty means no authentication.
+		APIKey string `toml:"api-key"`
+		// AuthReads requires the API key for requests which only read
+		// data too.
+		AuthReads bool `toml:"auth-reads"`
+		// EnablePprof serves the pprof profiling endpoints under
+		// /debug/pprof/.
+		EnablePprof bool `toml:"enable-pprof"`
+		// MaxRequestBytes limits the size of request bodies, except for
+		// imports and queries with an uploaded bitmap. Zero means no
+		// limit.
+		MaxRequestBytes int64 `toml:"max-request-bytes"`
 	} `toml:"handler"`
 
+	// SetValidation rejects Set() calls and imports whose row and column IDs
+	// look like they were swapped.
+	SetValidation struct {
+		// Enabled turns the checks on.
+		Enabled bool `toml:"enabled"`
+		// MaxRowID is the largest row ID accepted. Zero means no limit.
+		MaxRowID uint64 `toml:"max-row-id"`
+		// MaxColumnID is the largest column ID accepted. Zero means no limit.
+		MaxColumnID uint64 `toml:"max-column-id"`
+	} `toml:"set-validation"`
+
 	// MaxMapCount puts an in-process limit on the number of mmaps. After this
 	// is exhausted, Pilosa will fall back to reading the file into memory
 	// normally.
@@ -91,14 +159,16 @@ type Config struct {
 	TLS TLSConfig `toml:"tls"`
 
 	// WorkerPoolSize controls how many goroutines are created for
-	// processing queries. Defaults to runtime.NumCPU(). It is
+	// processing queries. Defaults to runtime.NumCPU(), and is clamped
+	// to at most 16 goroutines per CPU. It is
 	// intentionally not defined as a flag... only exposed here so
 	// that we can limit the size while running tests in CI so we
 	// don't exhaust the goroutine limit.
 	WorkerPoolSize int
 
 	// ImportWorkerPoolSize controls how many goroutines are created for
-	// processing importRoaring jobs. Defaults to runtime.NumCPU(). It is
+	// processing importRoaring jobs. Defaults to runtime.NumCPU(), and is clamped
+	// to at most 16 goroutines per CPU. It is
 	// intentionally not defined as a flag... only exposed here so
 	// that we can limit the size while running tests in CI so we
 	// don't exhaust the goroutine limit.
@@ -162,6 +232,7 @@ func NewConfig() *Config {
 		DataDir:             "~/.pilosa",
 		Bind:                ":10101",
 		MaxWritesPerRequest: 5000,
+		MaxRangeBuckets:     50000,
 
 		// We default these Max File/Map counts very high. This is basically a
 		// backwards compatibility thing where we don't want to cause different
@@ -177,6 +248,14 @@ func NewConfig() *Config {
 		ImportWorkerPoolSize: runtime.NumCPU(),
 	}
 
+	c.QueryTimeout = toml.Duration(30 * time.Second)
+	c.ShutdownTimeout = toml.Duration(30 * time.Second)
+	c.DrainRetryAfter = toml.Duration(30 * time.Second)
+	c.Handler.QueryResultTTL = toml.Duration(10 * time.Minute)
+	c.Handler.MaxAsyncQueries = 16
+	c.Handler.PageLimit = 1000
+	c.Handler.MaxRequestBytes = 32 << 20
+
 	// Cluster config.
 	c.Cluster.Disabled = false
 	c.Cluster.ReplicaN = 1
@@ -15,6 +15,8 @@
 package server_test
 
 import (
+	"io/ioutil"
+	"log"
 	"reflect"
 	"testing"
 	"time"
@@ -62,3 +64,22 @@ func TestDuration(t *testing.T) {
 		t.Fatalf("Unexpected marshalled value %v", v)
 	}
 }
+
+func TestGetTLSConfig(t *testing.T) {
+	logger := log.New(ioutil.Discard, "", 0)
+	const cert, key = "./testdata/certs/localhost.crt", "./testdata/certs/localhost.key"
+
+	if c, err := server.GetTLSConfig(&server.TLSConfig{}, logger); err != nil || c != nil {
+		t.Fatalf("unexpected config without certificate: %v, %v", c, err)
+	}
+	if c, err := server.GetTLSConfig(&server.TLSConfig{CertificatePath: cert, CertificateKeyPath: key}, logger); err != nil || c == nil {
+		t.Fatalf("unexpected config with certificate: %v, %v", c, err)
+	}
+
+	// Setting only one of the certificate and key is a mistake.
+	for _, c := range []server.TLSConfig{{CertificatePath: cert}, {CertificateKeyPath: key}} {
+		if _, err := server.GetTLSConfig(&c, logger); err == nil || err.Error() != "tls certificate and key must be set together" {
+			t.Fatalf("unexpected error for %+v: %v", c, err)
+		}
+	}
+}
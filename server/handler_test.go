@@ -16,6 +16,7 @@ package server_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/hex"
 	"encoding/json"
@@ -23,8 +24,10 @@ import (
 	"io"
 	"io/ioutil"
 	"math"
+	"mime/multipart"
 	gohttp "net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"strings"
 	"testing"
@@ -34,8 +37,10 @@ import (
 	"github.com/pilosa/pilosa/v2/boltdb"
 	"github.com/pilosa/pilosa/v2/encoding/proto"
 	"github.com/pilosa/pilosa/v2/http"
+	"github.com/pilosa/pilosa/v2/roaring"
 	"github.com/pilosa/pilosa/v2/server"
 	"github.com/pilosa/pilosa/v2/test"
+	"github.com/pilosa/pilosa/v2/toml"
 )
 
 func TestHandler_PostSchemaCluster(t *testing.T) {
@@ -345,7 +350,7 @@ func TestHandler_Endpoints(t *testing.T) {
 		h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i0/query?shards=a,b", strings.NewReader("Count(Row(f0=30))")))
 		if w.Code != gohttp.StatusBadRequest {
 			t.Fatalf("unexpected status code: %d", w.Code)
-		} else if body := w.Body.String(); body != `{"error":"invalid shard argument"}`+"\n" {
+		} else if body := w.Body.String(); body != `{"error":{"code":"bad-request","message":"invalid shard argument"}}`+"\n" {
 			t.Fatalf("unexpected body: %q", body)
 		}
 	})
@@ -355,7 +360,7 @@ func TestHandler_Endpoints(t *testing.T) {
 		h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i0/query?shards=0,1&db=sample", strings.NewReader("Count(Row(f0=30))")))
 		if w.Code != gohttp.StatusBadRequest {
 			t.Fatalf("unexpected status code: %d", w.Code)
-		} else if body := w.Body.String(); body != `{"error":"db is not a valid argument"}`+"\n" {
+		} else if body := w.Body.String(); body != `{"error":{"code":"bad-request","message":"db is not a valid argument"}}`+"\n" {
 			t.Fatalf("unexpected body: %q", body)
 		}
 	})
@@ -492,6 +497,25 @@ func TestHandler_Endpoints(t *testing.T) {
 		}
 	})
 
+	t.Run("Query typed JSON", func(t *testing.T) {
+		for query, exp := range map[string]string{
+			`Count(Row(f0=30))`:               `{"type":"count","value":3}`,
+			`TopN(f0, n=2)`:                   `{"type":"pairs","value":[{"id":30,"count":3},{"id":31,"count":1}]}`,
+			`Row(f0=31)`:                      `{"type":"row","value":{"attrs":{},"columns":[1]}}`,
+			`Rows(f0)`:                        `{"type":"rows","value":{"rows":[30,31]}}`,
+			`Overlap(Row(f0=30), Row(f0=31))`: `{"type":"overlap","value":{"a_only":3,"b_only":1,"both":0,"a_total":3,"b_total":1}}`,
+			`Clear(99, f0=99)`:                `{"type":"changed","value":false}`,
+		} {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i0/query?typed=true", strings.NewReader(query)))
+			if w.Code != gohttp.StatusOK {
+				t.Fatalf("unexpected status code for %s: %d", query, w.Code)
+			} else if body := w.Body.String(); body != `{"results":[`+exp+`]}`+"\n" {
+				t.Fatalf("unexpected body for %s: %q", query, body)
+			}
+		}
+	})
+
 	t.Run("Query Pairs protobuf", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		r := test.MustNewHTTPRequest("POST", "/index/i0/query", strings.NewReader(`TopN(f0, n=2)`))
@@ -514,11 +538,34 @@ func TestHandler_Endpoints(t *testing.T) {
 		h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i0/query", strings.NewReader(`Row(row=30)`)))
 		if w.Code != gohttp.StatusBadRequest {
 			t.Fatalf("unexpected status code: %d", w.Code)
-		} else if body := w.Body.String(); body != `{"error":"executing: map reduce: row: field not found"}`+"\n" {
+		} else if body := w.Body.String(); body != `{"error":{"code":"field-not-found","message":"executing: map reduce: row: field not found"}}`+"\n" {
 			t.Fatalf("unexpected body: %q", body)
 		}
 	})
 
+	t.Run("Query parse err JSON", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i0/query", strings.NewReader(`Row(f0=`)))
+		res := w.Result()
+		if res.StatusCode != gohttp.StatusBadRequest {
+			t.Fatalf("unexpected status code: %d", res.StatusCode)
+		} else if ct := res.Header.Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("unexpected header: %q", ct)
+		}
+
+		// The body must hold exactly one JSON object, with an error and no
+		// results.
+		dec := json.NewDecoder(res.Body)
+		var body map[string]interface{}
+		if err := dec.Decode(&body); err != nil {
+			t.Fatal(err)
+		} else if _, ok := body["error"].(map[string]interface{}); !ok || len(body) != 1 {
+			t.Fatalf("unexpected body: %v", body)
+		} else if dec.More() {
+			t.Fatalf("unexpected trailing data: %q", w.Body.String())
+		}
+	})
+
 	t.Run("Query err protobuf", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		r := test.MustNewHTTPRequest("POST", "/index/i0/query", strings.NewReader(`Row(row=30)`))
@@ -644,7 +691,7 @@ func TestHandler_Endpoints(t *testing.T) {
 
 	t.Run("Method not allowed", func(t *testing.T) {
 		w := httptest.NewRecorder()
-		h.ServeHTTP(w, test.MustNewHTTPRequest("GET", "/index/i0/query", nil))
+		h.ServeHTTP(w, test.MustNewHTTPRequest("DELETE", "/index/i0/query", nil))
 		if w.Code != gohttp.StatusMethodNotAllowed {
 			t.Fatalf("invalid status: %d", w.Code)
 		}
@@ -655,7 +702,7 @@ func TestHandler_Endpoints(t *testing.T) {
 		h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/idx0/query?shards=0,1", strings.NewReader("bad_fn(")))
 		if w.Code != gohttp.StatusBadRequest {
 			t.Fatalf("unexpected status code: %d", w.Code)
-		} else if body := w.Body.String(); body != `{"error":"parsing: parsing: \nparse error near IDENT (line 1 symbol 1 - line 1 symbol 4):\n\"bad\"\n"}`+"\n" {
+		} else if body := w.Body.String(); body != `{"error":{"code":"bad-request","message":"parsing: parsing: \nparse error near IDENT (line 1 symbol 1 - line 1 symbol 4):\n\"bad\"\n"}}`+"\n" {
 			t.Fatalf("unexpected body: %s", body)
 		}
 	})
@@ -875,7 +922,7 @@ func TestHandler_Endpoints(t *testing.T) {
 		h.ServeHTTP(w, r)
 		if w.Code != gohttp.StatusConflict {
 			t.Errorf("unexpected status code: %d", w.Code)
-		} else if w.Body.String() != `{"success":false,"error":{"message":"creating index: index already exists"}}`+"\n" {
+		} else if w.Body.String() != `{"error":{"code":"conflict","message":"creating index: index already exists"}}`+"\n" {
 			t.Errorf("unexpected body: %q", w.Body.String())
 		}
 
@@ -895,7 +942,7 @@ func TestHandler_Endpoints(t *testing.T) {
 		h.ServeHTTP(w, r)
 		if w.Code != gohttp.StatusConflict {
 			t.Errorf("unexpected status code: %d", w.Code)
-		} else if w.Body.String() != `{"success":false,"error":{"message":"creating field: field already exists"}}`+"\n" {
+		} else if w.Body.String() != `{"error":{"code":"conflict","message":"creating field: field already exists"}}`+"\n" {
 			t.Errorf("unexpected body: %q", w.Body.String())
 		}
 
@@ -915,7 +962,7 @@ func TestHandler_Endpoints(t *testing.T) {
 		h.ServeHTTP(w, r)
 		if w.Code != gohttp.StatusNotFound {
 			t.Errorf("unexpected status code: %d", w.Code)
-		} else if w.Body.String() != `{"success":false,"error":{"message":"deleting field: fld1: field not found"}}`+"\n" {
+		} else if w.Body.String() != `{"error":{"code":"not-found","message":"deleting field: fld1: field not found"}}`+"\n" {
 			t.Errorf("unexpected body: %q", w.Body.String())
 		}
 
@@ -935,7 +982,7 @@ func TestHandler_Endpoints(t *testing.T) {
 		h.ServeHTTP(w, r)
 		if w.Code != gohttp.StatusNotFound {
 			t.Errorf("unexpected status code: %d", w.Code)
-		} else if w.Body.String() != `{"success":false,"error":{"message":"deleting index: idx1: index not found"}}`+"\n" {
+		} else if w.Body.String() != `{"error":{"code":"not-found","message":"deleting index: idx1: index not found"}}`+"\n" {
 			t.Errorf("unexpected body: %q", w.Body.String())
 		}
 	})
@@ -1084,6 +1131,1465 @@ func TestClusterTranslator(t *testing.T) {
 	}
 }
 
+// Ensure an import request larger than the configured maximum batch is
+// rejected before any bits are written.
+func TestHandler_ImportMaxBatch(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1, []server.CommandOption{
+		func(m *server.Command) error {
+			m.Config.MaxImportBatch = 2
+			return nil
+		},
+	})
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+
+	doImport := func(colIDs []uint64) *httptest.ResponseRecorder {
+		msg := pilosa.ImportRequest{
+			Index:     "i",
+			Field:     "f",
+			RowIDs:    make([]uint64, len(colIDs)),
+			ColumnIDs: colIDs,
+		}
+		data, err := proto.Serializer{}.Marshal(&msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		httpReq := test.MustNewHTTPRequest("POST", "/index/i/field/f/import", bytes.NewBuffer(data))
+		httpReq.Header.Set("Content-Type", "application/x-protobuf")
+		httpReq.Header.Set("Accept", "application/x-protobuf")
+		h.ServeHTTP(w, httpReq)
+		return w
+	}
+
+	if w := doImport([]uint64{1, 2, 3}); w.Code != gohttp.StatusRequestEntityTooLarge {
+		t.Fatalf("unexpected status code: %d", w.Code)
+	}
+	if resp := cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: "Count(Row(f=0))"}); resp.Results[0] != uint64(0) {
+		t.Fatalf("unexpected count after rejected import: %v", resp.Results[0])
+	}
+
+	if w := doImport([]uint64{1, 2}); w.Code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code: %d, body: %s", w.Code, w.Body.String())
+	}
+	if resp := cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: "Count(Row(f=0))"}); resp.Results[0] != uint64(2) {
+		t.Fatalf("unexpected count after import: %v", resp.Results[0])
+	}
+}
+
+// Ensure bits can be imported from a CSV body, skipping malformed records.
+func TestHandler_ImportCSV(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1)
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f", pilosa.OptFieldTypeTime(pilosa.TimeQuantum("YMD")))
+
+	doImport := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := test.MustNewHTTPRequest("POST", "/index/i/field/f/import", strings.NewReader(body))
+		r.Header.Set("Content-Type", "text/csv")
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("WellFormed", func(t *testing.T) {
+		w := doImport(fmt.Sprintf("1,10\n1,%d\n\n2,10\n", pilosa.ShardWidth+1))
+		if w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code: %d, body: %s", w.Code, w.Body.String())
+		} else if body := w.Body.String(); body != `{"imported":3,"errors":0}`+"\n" {
+			t.Fatalf("unexpected body: %s", body)
+		}
+		resp := cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: "Row(f=1) Row(f=2)"})
+		if cols := resp.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(cols, []uint64{10, pilosa.ShardWidth + 1}) {
+			t.Fatalf("unexpected columns for row 1: %v", cols)
+		} else if cols := resp.Results[1].(*pilosa.Row).Columns(); !reflect.DeepEqual(cols, []uint64{10}) {
+			t.Fatalf("unexpected columns for row 2: %v", cols)
+		}
+	})
+
+	t.Run("BadRows", func(t *testing.T) {
+		w := doImport("3,20\nx,21\n3\n3,22,notatime\n3,-23\n3,24,2019-01-02T15:04,extra\n3,25,2019-01-02T15:04\n")
+		if w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code: %d, body: %s", w.Code, w.Body.String())
+		} else if body := w.Body.String(); body != `{"imported":2,"errors":5}`+"\n" {
+			t.Fatalf("unexpected body: %s", body)
+		}
+		resp := cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: "Row(f=3) Row(f=3, from=2019-01-02T00:00, to=2019-01-03T00:00)"})
+		if cols := resp.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(cols, []uint64{20, 25}) {
+			t.Fatalf("unexpected columns: %v", cols)
+		} else if cols := resp.Results[1].(*pilosa.Row).Columns(); !reflect.DeepEqual(cols, []uint64{25}) {
+			t.Fatalf("unexpected columns in time range: %v", cols)
+		}
+	})
+}
+
+// Ensure import requests over the per-client rate limit are rejected.
+func TestHandler_MaxRequestBytes(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1, []server.CommandOption{
+		func(m *server.Command) error {
+			m.Config.Handler.MaxRequestBytes = 256
+			return nil
+		},
+	})
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+
+	query := func(n int) *gohttp.Request {
+		q := "Count(Row(f=1))"
+		return test.MustNewHTTPRequest("POST", "/index/i/query", strings.NewReader(q+strings.Repeat(" ", n-len(q))))
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, query(256))
+	if w.Code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code at limit: %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, query(257))
+	if w.Code != gohttp.StatusRequestEntityTooLarge {
+		t.Fatalf("unexpected status code over limit: %d", w.Code)
+	} else if body := strings.TrimSpace(w.Body.String()); body != `{"error":{"code":"too-large","message":"request body of 257 bytes exceeds maximum of 256"}}` {
+		t.Fatalf("unexpected body: %s", body)
+	}
+
+	// A body without a length is cut off at the limit.
+	r := query(1000)
+	r.ContentLength = -1
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != gohttp.StatusBadRequest {
+		t.Fatalf("unexpected status code for unsized body: %d", w.Code)
+	} else if body := w.Body.String(); !strings.Contains(body, "request body too large") {
+		t.Fatalf("unexpected body for unsized body: %s", body)
+	}
+
+	// Imports aren't limited.
+	req := &pilosa.ImportRequest{Index: "i", Field: "f"}
+	for col := uint64(0); col < 1000; col++ {
+		req.RowIDs = append(req.RowIDs, 1)
+		req.ColumnIDs = append(req.ColumnIDs, col)
+	}
+	data, err := proto.Serializer{}.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(data) <= 256 {
+		t.Fatalf("import body of %d bytes is within the limit", len(data))
+	}
+	r = test.MustNewHTTPRequest("POST", "/index/i/field/f/import", bytes.NewBuffer(data))
+	r.Header.Set("Content-Type", "application/x-protobuf")
+	r.Header.Set("Accept", "application/x-protobuf")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != gohttp.StatusOK {
+		t.Fatalf("unexpected import status code: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandler_ImportRateLimit(t *testing.T) {
+	const limit = 5
+	cluster := test.MustRunCluster(t, 1, []server.CommandOption{
+		func(m *server.Command) error {
+			m.Config.Handler.RateLimitPerSec = limit
+			return nil
+		},
+	})
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+
+	data, err := proto.Serializer{}.Marshal(&pilosa.ImportRequest{Index: "i", Field: "f", RowIDs: []uint64{1}, ColumnIDs: []uint64{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doImport := func(remoteAddr string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		httpReq := test.MustNewHTTPRequest("POST", "/index/i/field/f/import", bytes.NewReader(data))
+		httpReq.Header.Set("Content-Type", "application/x-protobuf")
+		httpReq.Header.Set("Accept", "application/x-protobuf")
+		httpReq.RemoteAddr = remoteAddr
+		h.ServeHTTP(w, httpReq)
+		return w
+	}
+
+	// Each request comes from a new port, as it would over new connections.
+	for i := 0; i < limit; i++ {
+		if w := doImport(fmt.Sprintf("192.0.2.1:%d", 10000+i)); w.Code != gohttp.StatusOK {
+			t.Fatalf("request %d: unexpected status code: %d, body: %s", i, w.Code, w.Body.String())
+		}
+	}
+	w := doImport("192.0.2.1:20000")
+	if w.Code != gohttp.StatusTooManyRequests {
+		t.Fatalf("unexpected status code: %d, body: %s", w.Code, w.Body.String())
+	} else if w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("unexpected Retry-After: %q", w.Header().Get("Retry-After"))
+	}
+
+	// Other clients have their own limit.
+	if w := doImport("192.0.2.2:10000"); w.Code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code for other client: %d, body: %s", w.Code, w.Body.String())
+	}
+
+	// Clients can't get around the limit by marking their imports as
+	// forwarded from another node.
+	w = httptest.NewRecorder()
+	httpReq := test.MustNewHTTPRequest("POST", "/index/i/field/f/import?remote=true", bytes.NewReader(data))
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("Accept", "application/x-protobuf")
+	httpReq.RemoteAddr = "192.0.2.1:20001"
+	h.ServeHTTP(w, httpReq)
+	if w.Code != gohttp.StatusTooManyRequests {
+		t.Fatalf("unexpected status code for remote import: %d, body: %s", w.Code, w.Body.String())
+	}
+
+	// Queries which write share the limit, while queries which only read
+	// aren't limited.
+	doQuery := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		httpReq := test.MustNewHTTPRequest("POST", "/index/i/query", strings.NewReader(query))
+		httpReq.RemoteAddr = "192.0.2.3:10000"
+		h.ServeHTTP(w, httpReq)
+		return w
+	}
+	for i := 0; i < limit; i++ {
+		if w := doQuery(fmt.Sprintf("Set(%d, f=2)", i)); w.Code != gohttp.StatusOK {
+			t.Fatalf("write %d: unexpected status code: %d, body: %s", i, w.Code, w.Body.String())
+		}
+	}
+	if w := doQuery("Count(Row(f=2)) Set(10, f=2)"); w.Code != gohttp.StatusTooManyRequests {
+		t.Fatalf("unexpected status code for write over the limit: %d, body: %s", w.Code, w.Body.String())
+	}
+	if w := doQuery("Count(Row(f=2))"); w.Code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code for read: %d, body: %s", w.Code, w.Body.String())
+	}
+}
+
+// Ensure imports which nodes forward to each other aren't rate limited.
+func TestHandler_ImportRateLimitCluster(t *testing.T) {
+	cluster := test.MustRunCluster(t, 2, []server.CommandOption{
+		func(m *server.Command) error {
+			m.Config.Handler.RateLimitPerSec = 1
+			return nil
+		},
+	})
+	defer cluster.Close()
+	cluster.CreateField(t, "i", pilosa.IndexOptions{}, "f")
+
+	// A single import fans out into a request per shard to each owner, all
+	// from the same IP. The other node learns of new shards
+	// asynchronously, so query them explicitly.
+	var bits []pilosa.Bit
+	var shards []uint64
+	for shard := uint64(0); shard < 10; shard++ {
+		bits = append(bits, pilosa.Bit{RowID: 1, ColumnID: shard * pilosa.ShardWidth})
+		shards = append(shards, shard)
+	}
+	if err := cluster[0].API.ImportBits(context.Background(), "i", "f", bits); err != nil {
+		t.Fatal(err)
+	} else if resp := cluster[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: "Count(Row(f=1))", Shards: shards}); resp.Results[0] != uint64(10) {
+		t.Fatalf("unexpected count: %v", resp.Results[0])
+	}
+
+	// Writes which the coordinator forwards to the owner of their shard
+	// aren't limited either.
+	for _, shard := range shards {
+		cluster[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf("Set(%d, f=2)", shard*pilosa.ShardWidth)})
+	}
+	if resp := cluster[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: "Count(Row(f=2))", Shards: shards}); resp.Results[0] != uint64(10) {
+		t.Fatalf("unexpected count of writes: %v", resp.Results[0])
+	}
+}
+
+// Ensure requests which modify data require the API key when one is set.
+func TestHandler_APIKey(t *testing.T) {
+	newCluster := func(key string, authReads bool) test.Cluster {
+		return test.MustRunCluster(t, 1, []server.CommandOption{
+			func(m *server.Command) error {
+				m.Config.Handler.APIKey = key
+				m.Config.Handler.AuthReads = authReads
+				return nil
+			},
+		})
+	}
+	do := func(h gohttp.Handler, method, path, auth string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := test.MustNewHTTPRequest(method, path, strings.NewReader(""))
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("Authorized", func(t *testing.T) {
+		cluster := newCluster("secret", false)
+		defer cluster.Close()
+		h := cluster[0].Handler.(*http.Handler).Handler
+
+		if w := do(h, "POST", "/index/i", "Bearer secret"); w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code: %d, body: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		cluster := newCluster("secret", false)
+		defer cluster.Close()
+		h := cluster[0].Handler.(*http.Handler).Handler
+
+		for _, auth := range []string{"", "Bearer wrong", "secret"} {
+			w := do(h, "POST", "/index/i", auth)
+			if w.Code != gohttp.StatusUnauthorized {
+				t.Fatalf("auth %q: unexpected status code: %d, body: %s", auth, w.Code, w.Body.String())
+			} else if w.Header().Get("WWW-Authenticate") == "" {
+				t.Fatalf("auth %q: expected WWW-Authenticate header", auth)
+			}
+		}
+		if _, err := cluster[0].API.Index(context.Background(), "i"); err == nil {
+			t.Fatal("expected index not to be created")
+		}
+
+		// Reads stay open.
+		if w := do(h, "GET", "/schema", ""); w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code for read: %d, body: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("GetQuery", func(t *testing.T) {
+		cluster := newCluster("secret", false)
+		defer cluster.Close()
+		cmd := cluster[0]
+		h := cmd.Handler.(*http.Handler).Handler
+		cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+		cmd.MustCreateField(t, "i", "f")
+		cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: "Set(1, f=10) Set(2, f=11)"})
+
+		path := func(query string) string { return "/index/i/query?query=" + url.QueryEscape(query) }
+		if w := do(h, "GET", path("Count(Row(f=10))"), ""); w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code for read: %d, body: %s", w.Code, w.Body.String())
+		}
+		for _, write := range []string{"ClearRow(f=10)", "Count(Store(Row(f=11), f=10))"} {
+			if w := do(h, "GET", path(write), ""); w.Code != gohttp.StatusUnauthorized {
+				t.Fatalf("%s: unexpected status code: %d, body: %s", write, w.Code, w.Body.String())
+			}
+		}
+		if n := cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: "Count(Row(f=10))"}).Results[0]; n != uint64(1) {
+			t.Fatalf("unexpected count after unauthorized writes: %d", n)
+		}
+	})
+
+	t.Run("AuthReads", func(t *testing.T) {
+		cluster := newCluster("secret", true)
+		defer cluster.Close()
+		h := cluster[0].Handler.(*http.Handler).Handler
+
+		if w := do(h, "GET", "/schema", ""); w.Code != gohttp.StatusUnauthorized {
+			t.Fatalf("unexpected status code: %d, body: %s", w.Code, w.Body.String())
+		} else if w := do(h, "GET", "/schema", "Bearer secret"); w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code: %d, body: %s", w.Code, w.Body.String())
+		} else if w := do(h, "GET", "/version", ""); w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code for version: %d, body: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		cluster := newCluster("", true)
+		defer cluster.Close()
+		h := cluster[0].Handler.(*http.Handler).Handler
+
+		if w := do(h, "POST", "/index/i", ""); w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code: %d, body: %s", w.Code, w.Body.String())
+		}
+	})
+}
+
+// Ensure nodes send the API key to each other.
+func TestHandler_APIKeyCluster(t *testing.T) {
+	cluster := test.MustRunCluster(t, 3, []server.CommandOption{
+		func(m *server.Command) error {
+			m.Config.Handler.APIKey = "secret"
+			m.Config.Handler.AuthReads = true
+			return nil
+		},
+	})
+	defer cluster.Close()
+	cluster.CreateField(t, "i", pilosa.IndexOptions{}, "f")
+
+	// Set bits in enough shards that some are owned by other nodes.
+	var sets strings.Builder
+	for shard := uint64(0); shard < 10; shard++ {
+		fmt.Fprintf(&sets, "Set(%d, f=1)", shard*pilosa.ShardWidth)
+	}
+	cluster.Query(t, "i", sets.String())
+	if resp := cluster.Query(t, "i", "Count(Row(f=1))"); resp.Results[0] != uint64(10) {
+		t.Fatalf("unexpected count: %v", resp.Results[0])
+	}
+}
+
+// Ensure /metrics reports runtime metrics and request counts in the
+// Prometheus text format when the prometheus stats client is used.
+func TestHandler_Metrics(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1, []server.CommandOption{
+		func(m *server.Command) error {
+			m.Config.Metric.Service = "prometheus"
+			return nil
+		},
+	})
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i/query", strings.NewReader("Count(Row(f=1))")))
+		if w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code: %d, body: %s", w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("GET", "/metrics", nil))
+	if w.Code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code: %d, body: %s", w.Code, w.Body.String())
+	}
+
+	// Find the value of each metric line, keyed by name and labels.
+	metrics := make(map[string]string)
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if i := strings.LastIndex(line, " "); i > 0 && !strings.HasPrefix(line, "#") {
+			metrics[line[:i]] = line[i+1:]
+		}
+	}
+	for _, name := range []string{"go_goroutines", "go_memstats_heap_alloc_bytes", "go_gc_duration_seconds_count"} {
+		if _, ok := metrics[name]; !ok {
+			t.Fatalf("expected metric %s", name)
+		}
+	}
+	if v := metrics[fmt.Sprintf(`pilosa_Count{NodeID=%q,index="i"}`, cmd.API.Node().ID)]; v != "2" {
+		t.Fatalf("unexpected Count() calls: %q", v)
+	}
+	var found bool
+	for name, v := range metrics {
+		if strings.HasPrefix(name, "pilosa_http_request_count{") && strings.Contains(name, `path="/index/{index}/query"`) {
+			if v != "2" {
+				t.Fatalf("unexpected query request count: %q", v)
+			}
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected query request count")
+	}
+}
+
+// Ensure a draining node rejects client requests but still serves other nodes.
+func TestHandler_Drain(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1, []server.CommandOption{
+		func(m *server.Command) error {
+			m.Config.DrainRetryAfter = toml.Duration(5 * time.Second)
+			return nil
+		},
+	})
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+	cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: "Set(1, f=1)"})
+
+	getDraining := func() bool {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("GET", "/info", nil))
+		var info struct {
+			Draining bool `json:"draining"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+			t.Fatal(err)
+		}
+		return info.Draining
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/cluster/drain", nil))
+	if w.Code != gohttp.StatusNoContent {
+		t.Fatalf("unexpected status code: %d", w.Code)
+	} else if !getDraining() {
+		t.Fatal("expected info to report draining")
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i/query", strings.NewReader("Set(2, f=1)")))
+	if w.Code != gohttp.StatusServiceUnavailable {
+		t.Fatalf("unexpected status code: %d", w.Code)
+	} else if ra := w.Header().Get("Retry-After"); ra != "5" {
+		t.Fatalf("unexpected Retry-After: %q", ra)
+	}
+
+	data, err := proto.Serializer{}.Marshal(&pilosa.ImportRequest{Index: "i", Field: "f", RowIDs: []uint64{1}, ColumnIDs: []uint64{3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	r := test.MustNewHTTPRequest("POST", "/index/i/field/f/import", bytes.NewBuffer(data))
+	r.Header.Set("Content-Type", "application/x-protobuf")
+	r.Header.Set("Accept", "application/x-protobuf")
+	h.ServeHTTP(w, r)
+	if w.Code != gohttp.StatusServiceUnavailable {
+		t.Fatalf("unexpected import status code: %d", w.Code)
+	}
+
+	// Clients can't get around draining by marking their imports as
+	// forwarded from another node.
+	w = httptest.NewRecorder()
+	r = test.MustNewHTTPRequest("POST", "/index/i/field/f/import?remote=true", bytes.NewBuffer(data))
+	r.Header.Set("Content-Type", "application/x-protobuf")
+	r.Header.Set("Accept", "application/x-protobuf")
+	r.RemoteAddr = "192.0.2.1:10000"
+	h.ServeHTTP(w, r)
+	if w.Code != gohttp.StatusServiceUnavailable {
+		t.Fatalf("unexpected remote import status code: %d", w.Code)
+	}
+
+	// Queries from other nodes are still served while draining.
+	if resp, err := cmd.API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: "Count(Row(f=1))", Remote: true, Shards: []uint64{0}}); err != nil {
+		t.Fatal(err)
+	} else if resp.Results[0] != uint64(1) {
+		t.Fatalf("unexpected count while draining: %v", resp.Results[0])
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("DELETE", "/cluster/drain", nil))
+	if w.Code != gohttp.StatusNoContent {
+		t.Fatalf("unexpected status code: %d", w.Code)
+	} else if getDraining() {
+		t.Fatal("expected info to report not draining")
+	}
+
+	if resp := cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: "Count(Row(f=1))"}); resp.Results[0] != uint64(1) {
+		t.Fatalf("unexpected count after draining: %v", resp.Results[0])
+	}
+}
+
+// Ensure imports forwarded from another node are accepted while draining.
+func TestHandler_DrainForwardedImport(t *testing.T) {
+	cluster := test.MustRunCluster(t, 2)
+	defer cluster.Close()
+	cluster.CreateField(t, "i", pilosa.IndexOptions{}, "f")
+	cluster.CreateField(t, "k", pilosa.IndexOptions{Keys: true}, "f")
+
+	if err := cluster[1].API.SetDraining(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Import into enough shards that some are owned by the draining node.
+	// The other node learns of new shards asynchronously, so query them
+	// explicitly.
+	var bits []pilosa.Bit
+	var shards []uint64
+	for shard := uint64(0); shard < 10; shard++ {
+		bits = append(bits, pilosa.Bit{RowID: 1, ColumnID: shard * pilosa.ShardWidth})
+		shards = append(shards, shard)
+	}
+	if err := cluster[0].API.ImportBits(context.Background(), "i", "f", bits); err != nil {
+		t.Fatal(err)
+	} else if resp := cluster[0].MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: "Count(Row(f=1))", Shards: shards}); resp.Results[0] != uint64(10) {
+		t.Fatalf("unexpected count: %v", resp.Results[0])
+	}
+
+	// Keyed imports are translated by the coordinator and forwarded.
+	req := &pilosa.ImportRequest{Index: "k", Field: "f"}
+	for i := 0; i < 100; i++ {
+		req.RowIDs = append(req.RowIDs, 1)
+		req.ColumnKeys = append(req.ColumnKeys, fmt.Sprintf("c%d", i))
+	}
+	if err := cluster[0].API.Import(context.Background(), req); err != nil {
+		t.Fatal(err)
+	} else if resp := cluster[0].MustQuery(t, &pilosa.QueryRequest{Index: "k", Query: "Count(Row(f=1))", Shards: []uint64{0}}); resp.Results[0] != uint64(100) {
+		t.Fatalf("unexpected keyed count: %v", resp.Results[0])
+	}
+
+	// Client imports sent to the draining node are still rejected.
+	if err := cluster[1].API.ImportBits(context.Background(), "i", "f", bits); err != pilosa.ErrDraining {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestHandler_QueryWithBitmap(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1)
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+	cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf("Set(1, f=1) Set(2, f=1) Set(%d, f=1)", pilosa.ShardWidth+1)})
+
+	newRequest := func(query string, bm *roaring.Bitmap) *gohttp.Request {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		if err := mw.WriteField("query", query); err != nil {
+			t.Fatal(err)
+		}
+		if bm != nil {
+			part, err := mw.CreateFormFile("bitmap", "bitmap")
+			if err != nil {
+				t.Fatal(err)
+			} else if _, err := bm.WriteTo(part); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := mw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		r := test.MustNewHTTPRequest("POST", "/index/i/query/with-bitmap", &body)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		return r
+	}
+
+	bm := roaring.NewBitmap(1, 3, pilosa.ShardWidth+1, pilosa.ShardWidth+5)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, newRequest("Intersect(Uploaded(), Row(f=1)) Count(Uploaded())", bm))
+	if w.Code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+	} else if body, exp := strings.TrimSpace(w.Body.String()), fmt.Sprintf(`{"results":[{"attrs":{},"columns":[1,%d]},4]}`, pilosa.ShardWidth+1); body != exp {
+		t.Fatalf("unexpected body: %s, expected: %s", body, exp)
+	}
+
+	// Columns 1 and ShardWidth+1 are already set; nothing is written.
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, newRequest("PreviewSet(Uploaded(), f=1) Count(Row(f=1))", bm))
+	if w.Code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+	} else if body, exp := strings.TrimSpace(w.Body.String()), `{"results":[2,3]}`; body != exp {
+		t.Fatalf("unexpected body: %s, expected: %s", body, exp)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, newRequest("Count(Uploaded())", nil))
+	if w.Code != gohttp.StatusBadRequest {
+		t.Fatalf("unexpected status code without bitmap: %d", w.Code)
+	}
+}
+
+func TestHandler_ExcludeColumnsDefault(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1, []server.CommandOption{
+		func(m *server.Command) error {
+			m.Config.Handler.ExcludeColumns = true
+			return nil
+		},
+	})
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+	cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: "Set(1, f=1)"})
+
+	for _, tt := range []struct {
+		url string
+		exp string
+	}{
+		{url: "/index/i/query", exp: `{"results":[{"attrs":{},"columns":[]}]}`},
+		{url: "/index/i/query?excludeColumns=false", exp: `{"results":[{"attrs":{},"columns":[1]}]}`},
+		{url: "/index/i/query?excludeColumns=true", exp: `{"results":[{"attrs":{},"columns":[]}]}`},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("POST", tt.url, strings.NewReader("Row(f=1)")))
+		if w.Code != gohttp.StatusOK {
+			t.Fatalf("%s: unexpected status code: %d", tt.url, w.Code)
+		} else if body := strings.TrimSpace(w.Body.String()); body != tt.exp {
+			t.Fatalf("%s: unexpected body: %s, expected: %s", tt.url, body, tt.exp)
+		}
+	}
+}
+
+func TestHandler_NotReady(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1)
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+	cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: "Set(1, f=1)"})
+
+	getReady := func() bool {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("GET", "/info", nil))
+		var info struct {
+			Ready bool `json:"ready"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+			t.Fatal(err)
+		}
+		return info.Ready
+	}
+	if !getReady() {
+		t.Fatal("expected info to report ready")
+	}
+
+	// A closed holder is in the same state as one which is still loading.
+	holder := cmd.Server.Holder()
+	if err := holder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i/query", strings.NewReader("Count(Row(f=1))")))
+	if w.Code != gohttp.StatusServiceUnavailable {
+		t.Fatalf("unexpected status code: %d", w.Code)
+	} else if body := w.Body.String(); !strings.Contains(body, pilosa.ErrNodeStarting.Error()) {
+		t.Fatalf("unexpected body: %s", body)
+	} else if getReady() {
+		t.Fatal("expected info to report not ready")
+	}
+
+	if err := holder.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i/query", strings.NewReader("Count(Row(f=1))")))
+	if w.Code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code after ready: %d", w.Code)
+	} else if body := strings.TrimSpace(w.Body.String()); body != `{"results":[1]}` {
+		t.Fatalf("unexpected body after ready: %s", body)
+	} else if !getReady() {
+		t.Fatal("expected info to report ready")
+	}
+}
+
+func TestHandler_Healthz(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1)
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+
+	check := func(expCode int, expBody string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("GET", "/healthz", nil))
+		if w.Code != expCode {
+			t.Fatalf("unexpected status code: %d, expected: %d", w.Code, expCode)
+		} else if body := strings.TrimSpace(w.Body.String()); body != expBody {
+			t.Fatalf("unexpected body: %s, expected: %s", body, expBody)
+		}
+	}
+	check(gohttp.StatusOK, `{"status":"ok"}`)
+
+	// A closed holder is in the same state as one which is still loading.
+	holder := cmd.Server.Holder()
+	if err := holder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	check(gohttp.StatusServiceUnavailable, `{"error":{"code":"unavailable","message":"node is starting"}}`)
+	if err := holder.Open(); err != nil {
+		t.Fatal(err)
+	}
+	check(gohttp.StatusOK, `{"status":"ok"}`)
+
+	for _, method := range []string{"POST", "DELETE"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest(method, "/cluster/drain", nil))
+		if w.Code != gohttp.StatusNoContent {
+			t.Fatalf("%s drain: unexpected status code: %d", method, w.Code)
+		}
+		if method == "POST" {
+			check(gohttp.StatusServiceUnavailable, `{"error":{"code":"unavailable","message":"node is draining"}}`)
+		}
+	}
+	check(gohttp.StatusOK, `{"status":"ok"}`)
+}
+
+func TestHandler_Pprof(t *testing.T) {
+	for _, enable := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Enabled=%v", enable), func(t *testing.T) {
+			cluster := test.MustRunCluster(t, 1, []server.CommandOption{
+				func(m *server.Command) error {
+					m.Config.Handler.EnablePprof = enable
+					return nil
+				},
+			})
+			defer cluster.Close()
+			h := cluster[0].Handler.(*http.Handler).Handler
+
+			expCode := gohttp.StatusNotFound
+			if enable {
+				expCode = gohttp.StatusOK
+			}
+			for _, path := range []string{"/debug/pprof/", "/debug/pprof/goroutine?debug=1"} {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, test.MustNewHTTPRequest("GET", path, nil))
+				if w.Code != expCode {
+					t.Fatalf("%s: unexpected status code: %d, expected: %d", path, w.Code, expCode)
+				} else if enable && !strings.Contains(w.Body.String(), "goroutine") {
+					t.Fatalf("%s: unexpected body: %s", path, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_QueryBitpacked(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1)
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+	exp := []uint64{pilosa.ShardWidth + 1, pilosa.ShardWidth + 3, 3*pilosa.ShardWidth - 1}
+	for _, col := range exp {
+		cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf("Set(%d, f=1)", col)})
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i/query?format=bitpacked", strings.NewReader("Row(f=1) Count(Row(f=1))")))
+	if w.Code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Results []json.RawMessage `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	} else if len(resp.Results) != 2 || string(resp.Results[1]) != "3" {
+		t.Fatalf("unexpected results: %s", w.Body.String())
+	}
+	var row struct {
+		Base   uint64 `json:"base"`
+		Length uint64 `json:"length"`
+		Bits   []byte `json:"bits"`
+	}
+	if err := json.Unmarshal(resp.Results[0], &row); err != nil {
+		t.Fatal(err)
+	} else if row.Base != pilosa.ShardWidth || row.Length != 2*pilosa.ShardWidth || uint64(len(row.Bits)) != row.Length/8 {
+		t.Fatalf("unexpected range: base=%d length=%d bytes=%d", row.Base, row.Length, len(row.Bits))
+	}
+
+	// Decode the set bits back into columns.
+	var columns []uint64
+	for i := uint64(0); i < row.Length; i++ {
+		if row.Bits[i/8]&(1<<(i%8)) != 0 {
+			columns = append(columns, row.Base+i)
+		}
+	}
+	if !reflect.DeepEqual(columns, exp) {
+		t.Fatalf("unexpected columns: %v, expected: %v", columns, exp)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i/query?format=sparse", strings.NewReader("Row(f=1)")))
+	if w.Code != gohttp.StatusBadRequest {
+		t.Fatalf("unexpected status code for invalid format: %d", w.Code)
+	}
+
+	// Rows spanning too many shards are rejected rather than allocating a
+	// bitmap covering every shard in between.
+	cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf("Set(0, f=2) Set(%d, f=2) Set(0, f=3) Set(%d, f=3)", 127*pilosa.ShardWidth, 100000*pilosa.ShardWidth)})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i/query?format=bitpacked", strings.NewReader("Count(Row(f=2)) Row(f=2)")))
+	if w.Code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code for row at the limit: %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i/query?format=bitpacked", strings.NewReader("Row(f=3)")))
+	if w.Code != gohttp.StatusBadRequest {
+		t.Fatalf("unexpected status code for row over the limit: %d %s", w.Code, w.Body.String())
+	} else if !strings.Contains(w.Body.String(), http.ErrCodeBadRequest) {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+}
+
+func TestHandler_QueryRanges(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1)
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+
+	// Mix dense runs, including one crossing a shard boundary, with isolated
+	// columns.
+	var sets strings.Builder
+	for col := uint64(100); col < 300; col++ {
+		fmt.Fprintf(&sets, "Set(%d, f=1)\n", col)
+	}
+	for col := uint64(pilosa.ShardWidth - 50); col < pilosa.ShardWidth+50; col++ {
+		fmt.Fprintf(&sets, "Set(%d, f=1)\n", col)
+	}
+	for _, col := range []uint64{5, 7, 302, 2*pilosa.ShardWidth + 1} {
+		fmt.Fprintf(&sets, "Set(%d, f=1)\n", col)
+	}
+	cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: sets.String()})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i/query", strings.NewReader("Row(f=1)")))
+	if w.Code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+	}
+	var explicit struct {
+		Results []struct {
+			Columns []uint64 `json:"columns"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &explicit); err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i/query?format=ranges", strings.NewReader("Row(f=1) Count(Row(f=1))")))
+	if w.Code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Results []json.RawMessage `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	} else if len(resp.Results) != 2 || string(resp.Results[1]) != "304" {
+		t.Fatalf("unexpected results: %s", w.Body.String())
+	}
+	var row struct {
+		Ranges [][2]uint64 `json:"ranges"`
+	}
+	if err := json.Unmarshal(resp.Results[0], &row); err != nil {
+		t.Fatal(err)
+	}
+	expRanges := [][2]uint64{{5, 5}, {7, 7}, {100, 299}, {302, 302}, {pilosa.ShardWidth - 50, pilosa.ShardWidth + 49}, {2*pilosa.ShardWidth + 1, 2*pilosa.ShardWidth + 1}}
+	if !reflect.DeepEqual(row.Ranges, expRanges) {
+		t.Fatalf("unexpected ranges: %v, expected: %v", row.Ranges, expRanges)
+	}
+
+	// Expand the ranges back into columns.
+	var columns []uint64
+	for _, r := range row.Ranges {
+		for col := r[0]; col <= r[1]; col++ {
+			columns = append(columns, col)
+		}
+	}
+	if !reflect.DeepEqual(columns, explicit.Results[0].Columns) {
+		t.Fatalf("unexpected columns: %v, expected: %v", columns, explicit.Results[0].Columns)
+	}
+}
+
+func TestHandler_QueryTotal(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1)
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+	cmd.MustCreateField(t, "i", "g")
+	cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Set(1, g=0) Set(2, g=0) Set(1, f=1) Set(2, f=1) Set(2, f=2) Set(3, f=3)`})
+	if err := cmd.RecalculateCaches(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		url string
+		exp string
+	}{
+		{url: "/index/i/query?include_total=true", exp: `{"results":[[{"id":1,"count":2}]],"total":2}`},
+		{url: "/index/i/query?include_total=true&typed=true", exp: `{"results":[{"type":"pairs","value":[{"id":1,"count":2}]}],"total":2}`},
+		{url: "/index/i/query", exp: `{"results":[[{"id":1,"count":2}]]}`},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("POST", tt.url, strings.NewReader("TopN(f, Row(g=0), n=1)")))
+		if w.Code != gohttp.StatusOK {
+			t.Fatalf("%s: unexpected status code: %d %s", tt.url, w.Code, w.Body.String())
+		} else if body := strings.TrimSpace(w.Body.String()); body != tt.exp {
+			t.Fatalf("%s: unexpected body: %s", tt.url, body)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i/query?include_total=true", strings.NewReader("Count(Row(g=0))")))
+	if w.Code != gohttp.StatusBadRequest {
+		t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandler_QueryPage(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1, []server.CommandOption{
+		func(m *server.Command) error {
+			m.Config.Handler.PageLimit = 3
+			return nil
+		},
+	})
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+
+	// Five columns in each of the first two shards.
+	var sets strings.Builder
+	for _, base := range []uint64{0, pilosa.ShardWidth} {
+		for col := base + 1; col <= base+5; col++ {
+			fmt.Fprintf(&sets, "Set(%d, f=1)\n", col)
+		}
+	}
+	cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: sets.String()})
+
+	sw := uint64(pilosa.ShardWidth)
+	for _, tt := range []struct {
+		args string
+		exp  string
+	}{
+		{args: "offset=3&limit=4", exp: fmt.Sprintf(`[4,5,%d,%d]`, sw+1, sw+2)},
+		{args: "offset=10", exp: `[]`},
+		{args: "offset=100&limit=2", exp: `[]`},
+		{args: "offset=8", exp: fmt.Sprintf(`[%d,%d]`, sw+4, sw+5)},
+		{args: "offset=0", exp: `[1,2,3]`},
+		{args: "limit=2", exp: `[1,2]`},
+		{args: "offset=7&limit=0", exp: fmt.Sprintf(`[%d,%d,%d]`, sw+3, sw+4, sw+5)},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i/query?"+tt.args, strings.NewReader("Row(f=1) Count(Row(f=1))")))
+		exp := fmt.Sprintf(`{"results":[{"attrs":{},"columns":%s,"count":10},10]}`, tt.exp)
+		if w.Code != gohttp.StatusOK {
+			t.Fatalf("%s: unexpected status code: %d %s", tt.args, w.Code, w.Body.String())
+		} else if body := strings.TrimSpace(w.Body.String()); body != exp {
+			t.Fatalf("%s: unexpected body: %s, expected: %s", tt.args, body, exp)
+		}
+	}
+
+	for _, args := range []string{"offset=-1", "limit=x", "offset=1&format=ranges"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i/query?"+args, strings.NewReader("Row(f=1)")))
+		if w.Code != gohttp.StatusBadRequest {
+			t.Fatalf("%s: unexpected status code: %d", args, w.Code)
+		}
+	}
+}
+
+func TestHandler_GetQuery(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1)
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+	cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf("Set(1, f=10) Set(2, f=10) Set(%d, f=10)", pilosa.ShardWidth+1)})
+
+	query := "Count(Row(f=10))"
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i/query", strings.NewReader(query)))
+	if w.Code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+	}
+	postBody := w.Body.String()
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("GET", "/index/i/query?query="+url.QueryEscape(query), nil))
+	if w.Code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+	} else if body := w.Body.String(); body != postBody || body != `{"results":[3]}`+"\n" {
+		t.Fatalf("unexpected body: %q, POST body: %q", body, postBody)
+	}
+
+	for _, write := range []string{
+		"Set(3, f=10)",
+		"Clear(1, f=10)",
+		"ClearRow(f=10)",
+		"Store(Row(f=11), f=10)",
+		"Materialize(Row(f=11), f=10)",
+		"Refresh(f=10)",
+		"SetRowAttrs(f, 10, x=1)",
+		"SetColumnAttrs(1, x=1)",
+		"Count(Row(f=10)) ClearRow(f=10)",
+		"Count(Store(Row(f=11), f=10))",
+		"Options(ClearRow(f=10), shards=[0])",
+	} {
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("GET", "/index/i/query?query="+url.QueryEscape(write), nil))
+		if w.Code != gohttp.StatusBadRequest {
+			t.Fatalf("%s: unexpected status code for write: %d %s", write, w.Code, w.Body.String())
+		} else if n := cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: query}).Results[0]; n != uint64(3) {
+			t.Fatalf("%s: unexpected count after rejected write: %d", write, n)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("GET", "/index/i/query", nil))
+	if w.Code != gohttp.StatusBadRequest {
+		t.Fatalf("unexpected status code without query: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandler_PostQueries(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1)
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateIndex(t, "j", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+	cmd.MustCreateField(t, "j", "g")
+	cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: "Set(1, f=10) Set(2, f=10)"})
+	cmd.MustQuery(t, &pilosa.QueryRequest{Index: "j", Query: "Set(3, g=20)"})
+
+	t.Run("Ordering", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/queries", strings.NewReader(`[
+			{"index": "j", "query": "Count(Row(g=20))"},
+			{"index": "i", "query": "Count(Row(f=10))"},
+			{"index": "i", "query": "Row(f=10)"}
+		]`)))
+		if w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+		} else if body := w.Body.String(); body != `[{"results":[1]},{"results":[2]},{"results":[{"attrs":{},"columns":[1,2]}]}]`+"\n" {
+			t.Fatalf("unexpected body: %q", body)
+		}
+	})
+
+	t.Run("MixedErrors", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/queries", strings.NewReader(`[
+			{"index": "i", "query": "Count(Row(f=10))"},
+			{"index": "missing", "query": "Count(Row(f=10))"},
+			{"index": "i", "query": "Row(f="},
+			{"index": "j", "query": "Count(Row(g=20))"}
+		]`)))
+		if w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+		}
+
+		var resps []struct {
+			Results []interface{}
+			Error   *http.Error
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resps); err != nil {
+			t.Fatalf("decoding %q: %s", w.Body.String(), err)
+		} else if len(resps) != 4 {
+			t.Fatalf("unexpected number of responses: %s", w.Body.String())
+		}
+		if resps[0].Error != nil || !reflect.DeepEqual(resps[0].Results, []interface{}{float64(2)}) {
+			t.Fatalf("unexpected first response: %+v", resps[0])
+		} else if resps[1].Error == nil || resps[1].Error.Code != http.ErrCodeIndexNotFound {
+			t.Fatalf("unexpected second response: %+v", resps[1])
+		} else if resps[2].Error == nil || resps[2].Error.Code != http.ErrCodeBadRequest || !strings.Contains(resps[2].Error.Message, "parsing") {
+			t.Fatalf("unexpected third response: %+v", resps[2])
+		} else if resps[3].Error != nil || !reflect.DeepEqual(resps[3].Results, []interface{}{float64(1)}) {
+			t.Fatalf("unexpected fourth response: %+v", resps[3])
+		}
+	})
+
+	t.Run("InvalidBody", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/queries", strings.NewReader(`{"index": "i"}`)))
+		if w.Code != gohttp.StatusBadRequest {
+			t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestHandler_QueryAsync(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1)
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+	cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: "Set(1, f=10) Set(2, f=10)"})
+
+	// getResult polls the job's result until it is no longer pending.
+	getResult := func(t *testing.T, id string) *httptest.ResponseRecorder {
+		t.Helper()
+		for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, test.MustNewHTTPRequest("GET", "/query/result?job="+url.QueryEscape(id), nil))
+			if w.Code != gohttp.StatusAccepted {
+				return w
+			} else if body := w.Body.String(); body != `{"status":"pending"}`+"\n" {
+				t.Fatalf("unexpected pending body: %q", body)
+			}
+		}
+		t.Fatal("timed out waiting for async query")
+		return nil
+	}
+
+	startJob := func(t *testing.T, query string) string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i/query/async", strings.NewReader(query)))
+		if w.Code != gohttp.StatusAccepted {
+			t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			JobID string `json:"job_id"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		} else if resp.JobID == "" {
+			t.Fatalf("missing job id: %s", w.Body.String())
+		}
+		return resp.JobID
+	}
+
+	t.Run("Completed", func(t *testing.T) {
+		w := getResult(t, startJob(t, "Count(Row(f=10))"))
+		if w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+		} else if body := w.Body.String(); body != `{"results":[2]}`+"\n" {
+			t.Fatalf("unexpected body: %q", body)
+		}
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		w := getResult(t, startJob(t, "Count(Row(nosuchfield=10))"))
+		if w.Code != gohttp.StatusBadRequest {
+			t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+		} else if !strings.Contains(w.Body.String(), http.ErrCodeFieldNotFound) {
+			t.Fatalf("unexpected body: %s", w.Body.String())
+		}
+	})
+
+	t.Run("UnknownJob", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("GET", "/query/result?job=unknown", nil))
+		if w.Code != gohttp.StatusNotFound {
+			t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+		}
+	})
+}
+
+// Ensure async queries are bound by the async query timeout rather than the
+// query timeout.
+func TestHandler_QueryAsyncTimeout(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		asyncTimeout time.Duration
+		code         int
+	}{
+		{name: "QueryTimeout", code: gohttp.StatusOK},
+		{name: "AsyncQueryTimeout", asyncTimeout: time.Nanosecond, code: gohttp.StatusGatewayTimeout},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := test.MustNewCluster(t, 1)
+			cluster[0].Config.QueryTimeout = toml.Duration(time.Nanosecond)
+			cluster[0].Config.Handler.AsyncQueryTimeout = toml.Duration(tt.asyncTimeout)
+			if err := cluster.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer cluster.Close()
+			cmd := cluster[0]
+			h := cmd.Handler.(*http.Handler).Handler
+			cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+			cmd.MustCreateField(t, "i", "f")
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i/query/async", strings.NewReader("Count(Row(f=10))")))
+			if w.Code != gohttp.StatusAccepted {
+				t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+			}
+			var resp struct {
+				JobID string `json:"job_id"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(time.Millisecond) {
+				if time.Now().After(deadline) {
+					t.Fatal("timed out waiting for async query")
+				}
+				w = httptest.NewRecorder()
+				h.ServeHTTP(w, test.MustNewHTTPRequest("GET", "/query/result?job="+url.QueryEscape(resp.JobID), nil))
+				if w.Code != gohttp.StatusAccepted {
+					break
+				}
+			}
+			if w.Code != tt.code {
+				t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_QueryExplain(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1)
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+	cmd.MustCreateField(t, "i", "g")
+	cmd.MustCreateField(t, "i", "v", pilosa.OptFieldTypeInt(0, 100))
+	cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf("Set(1, f=1) Set(%d, g=3)", 2*pilosa.ShardWidth+1)})
+
+	explain := func(url, query string) (int, []byte) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("POST", url, strings.NewReader(query)))
+		return w.Code, w.Body.Bytes()
+	}
+
+	code, body := explain("/index/i/query/explain", "Count(Union(Intersect(Row(f=1), Row(v > 10)), Row(g=3)))")
+	if code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code: %d %s", code, body)
+	}
+	var plan pilosa.QueryPlan
+	if err := json.Unmarshal(body, &plan); err != nil {
+		t.Fatal(err)
+	}
+	exp := pilosa.QueryPlan{
+		Calls: []*pilosa.CallPlan{{
+			Name: "Count",
+			Children: []*pilosa.CallPlan{{
+				Name: "Union",
+				Children: []*pilosa.CallPlan{
+					{
+						Name: "Intersect",
+						Children: []*pilosa.CallPlan{
+							{Name: "Row", Args: map[string]interface{}{"f": float64(1)}, Fields: []string{"f"}},
+							{Name: "Row", Args: map[string]interface{}{"v": "> 10"}, Fields: []string{"v"}},
+						},
+					},
+					{Name: "Row", Args: map[string]interface{}{"g": float64(3)}, Fields: []string{"g"}},
+				},
+			}},
+		}},
+		Shards: []uint64{0, 2},
+		Nodes:  map[string][]uint64{cmd.API.Node().ID: {0, 2}},
+	}
+	if !reflect.DeepEqual(plan, exp) {
+		t.Fatalf("unexpected plan: %s", body)
+	}
+
+	// Explained queries aren't executed, and shards can be narrowed.
+	if code, body := explain("/index/i/query/explain?shards=2", "Set(5, f=9) Row(f=9)"); code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code: %d %s", code, body)
+	} else if err := json.Unmarshal(body, &plan); err != nil {
+		t.Fatal(err)
+	} else if len(plan.Calls) != 2 || plan.Calls[0].Name != "Set" || !reflect.DeepEqual(plan.Shards, []uint64{2}) {
+		t.Fatalf("unexpected plan: %s", body)
+	}
+	resp := cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: "Count(Row(f=9))"})
+	if resp.Results[0] != uint64(0) {
+		t.Fatalf("explained Set() was executed: count=%v", resp.Results[0])
+	}
+
+	if code, body := explain("/index/i/query/explain", "Row(f="); code != gohttp.StatusBadRequest {
+		t.Fatalf("unexpected status code for invalid query: %d %s", code, body)
+	}
+	if code, body := explain("/index/missing/query/explain", "Row(f=1)"); code != gohttp.StatusBadRequest || !strings.Contains(string(body), `"code":"index-not-found"`) {
+		t.Fatalf("unexpected response for missing index: %d %s", code, body)
+	}
+}
+
+func TestHandler_ContentTypeJSON(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1)
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+
+	for _, tt := range []struct {
+		method, path, body string
+	}{
+		{method: "GET", path: "/status"},
+		{method: "GET", path: "/info"},
+		{method: "GET", path: "/schema"},
+		{method: "GET", path: "/version"},
+		{method: "GET", path: "/index/i"},
+		{method: "POST", path: "/index/i/field/f", body: "{}"},
+		{method: "POST", path: "/index/i/query", body: "Count(Row(f=1))"},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		res := w.Result()
+		if res.StatusCode != gohttp.StatusOK {
+			t.Fatalf("%s %s: unexpected status code: %d %s", tt.method, tt.path, res.StatusCode, w.Body.String())
+		} else if ct := res.Header.Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("%s %s: unexpected Content-Type: %q", tt.method, tt.path, ct)
+		}
+	}
+}
+
+func TestHandler_ErrorJSON(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1)
+	defer cluster.Close()
+	h := cluster[0].Handler.(*http.Handler).Handler
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/missing/query", strings.NewReader("Count(Row(f=1))")))
+	res := w.Result()
+	if res.StatusCode != gohttp.StatusBadRequest {
+		t.Fatalf("unexpected status code: %d %s", res.StatusCode, w.Body.String())
+	} else if ct := res.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("unexpected Content-Type: %q", ct)
+	}
+
+	var body map[string]map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding error response %q: %s", w.Body.String(), err)
+	} else if len(body) != 1 || len(body["error"]) != 2 {
+		t.Fatalf("unexpected error response: %s", w.Body.String())
+	} else if code := body["error"]["code"]; code != http.ErrCodeIndexNotFound {
+		t.Fatalf("unexpected error code: %q", code)
+	} else if msg := body["error"]["message"]; !strings.Contains(msg, pilosa.ErrIndexNotFound.Error()) {
+		t.Fatalf("unexpected error message: %q", msg)
+	}
+}
+
+func TestHandler_QueryGzip(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1)
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+	var sets strings.Builder
+	for col := 0; col < 1000; col++ {
+		fmt.Fprintf(&sets, "Set(%d, f=1)\n", col*3)
+	}
+	cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: sets.String()})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i/query", strings.NewReader("Row(f=1)")))
+	if w.Code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+	} else if enc := w.Header().Get("Content-Encoding"); enc != "" {
+		t.Fatalf("unexpected Content-Encoding without Accept-Encoding: %q", enc)
+	}
+	plain := w.Body.String()
+
+	w = httptest.NewRecorder()
+	r := test.MustNewHTTPRequest("POST", "/index/i/query", strings.NewReader("Row(f=1)"))
+	r.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(w, r)
+	if w.Code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+	} else if enc := w.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Fatalf("unexpected Content-Encoding: %q", enc)
+	} else if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("unexpected Content-Type: %q", ct)
+	} else if w.Body.Len() >= len(plain) {
+		t.Fatalf("compressed body is not smaller: %d >= %d", w.Body.Len(), len(plain))
+	}
+
+	gr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(gr)
+	if err != nil {
+		t.Fatal(err)
+	} else if string(body) != plain {
+		t.Fatalf("unexpected decompressed body: %q, expected: %q", body, plain)
+	}
+}
+
 func mustJSONDecode(t *testing.T, r io.Reader) (ret map[string]interface{}) {
 	dec := json.NewDecoder(r)
 	err := dec.Decode(&ret)
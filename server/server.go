@@ -257,6 +257,8 @@ func (m *Command) SetupServer() error {
 		TLSConfig, err = GetTLSConfig(&m.Config.TLS, m.logger.Logger())
 		if err != nil {
 			return errors.Wrap(err, "get tls config")
+		} else if TLSConfig == nil {
+			return errors.New("https bind address requires a tls certificate and key")
 		}
 	}
 
@@ -284,6 +286,9 @@ func (m *Command) SetupServer() error {
 	m.listenURI = uri
 
 	c := http.GetHTTPClient(TLSConfig)
+	if m.Config.Handler.APIKey != "" {
+		c = http.WithAPIKey(c, m.Config.Handler.APIKey)
+	}
 
 	// Get advertise address as uri.
 	advertiseURI, err := pilosa.AddressWithDefaults(m.Config.Advertise)
@@ -311,9 +316,17 @@ func (m *Command) SetupServer() error {
 		pilosa.OptServerDataDir(m.Config.DataDir),
 		pilosa.OptServerReplicaN(m.Config.Cluster.ReplicaN),
 		pilosa.OptServerMaxWritesPerRequest(m.Config.MaxWritesPerRequest),
+		pilosa.OptServerMaxRangeBuckets(m.Config.MaxRangeBuckets),
+		pilosa.OptServerQueryTimeout(time.Duration(m.Config.QueryTimeout)),
+		pilosa.OptServerSetValidation(pilosa.SetValidation{
+			Enabled:     m.Config.SetValidation.Enabled,
+			MaxRowID:    m.Config.SetValidation.MaxRowID,
+			MaxColumnID: m.Config.SetValidation.MaxColumnID,
+		}),
 		pilosa.OptServerMetricInterval(time.Duration(m.Config.Metric.PollInterval)),
 		pilosa.OptServerDiagnosticsInterval(diagnosticsInterval),
 		pilosa.OptServerExecutorPoolSize(m.Config.WorkerPoolSize),
+		pilosa.OptServerImportWorkerPoolSize(m.Config.ImportWorkerPoolSize),
 		pilosa.OptServerOpenTranslateStore(boltdb.OpenTranslateStore),
 		pilosa.OptServerOpenTranslateReader(http.GetOpenTranslateReaderFunc(c)),
 		pilosa.OptServerLogger(m.logger),
@@ -338,18 +351,34 @@ func (m *Command) SetupServer() error {
 
 	m.API, err = pilosa.NewAPI(
 		pilosa.OptAPIServer(m.Server),
-		pilosa.OptAPIImportWorkerPoolSize(m.Config.ImportWorkerPoolSize),
+		pilosa.OptAPIWarmupPeriod(time.Duration(m.Config.WarmupPeriod)),
 	)
 	if err != nil {
 		return errors.Wrap(err, "new api")
 	}
 
+	closeTimeout := m.closeTimeout
+	if closeTimeout == 0 {
+		closeTimeout = time.Duration(m.Config.ShutdownTimeout)
+	}
+
 	m.Handler, err = http.NewHandler(
 		http.OptHandlerAllowedOrigins(m.Config.Handler.AllowedOrigins),
 		http.OptHandlerAPI(m.API),
 		http.OptHandlerLogger(m.logger),
 		http.OptHandlerListener(m.ln),
-		http.OptHandlerCloseTimeout(m.closeTimeout),
+		http.OptHandlerCloseTimeout(closeTimeout),
+		http.OptHandlerMaxImportBatch(m.Config.MaxImportBatch),
+		http.OptHandlerDrainRetryAfter(time.Duration(m.Config.DrainRetryAfter)),
+		http.OptHandlerExcludeColumns(m.Config.Handler.ExcludeColumns),
+		http.OptHandlerPageLimit(m.Config.Handler.PageLimit),
+		http.OptHandlerPprof(m.Config.Handler.EnablePprof),
+		http.OptHandlerMaxRequestBytes(m.Config.Handler.MaxRequestBytes),
+		http.OptHandlerQueryResultTTL(time.Duration(m.Config.Handler.QueryResultTTL)),
+		http.OptHandlerMaxAsyncQueries(m.Config.Handler.MaxAsyncQueries),
+		http.OptHandlerAsyncQueryTimeout(time.Duration(m.Config.Handler.AsyncQueryTimeout)),
+		http.OptHandlerRateLimit(m.Config.Handler.RateLimitPerSec),
+		http.OptHandlerAPIKey(m.Config.Handler.APIKey, m.Config.Handler.AuthReads),
 	)
 	return errors.Wrap(err, "new handler")
 }
@@ -397,8 +426,13 @@ func (m *Command) GossipTransport() *gossip.Transport {
 // Close shuts down the server.
 func (m *Command) Close() error {
 	defer close(m.done)
+
+	// Stop accepting new requests and let in-flight requests drain before
+	// closing the server; otherwise a write can arrive after the holder has
+	// been closed and be lost.
+	handlerErr := m.Handler.Close()
+
 	eg := errgroup.Group{}
-	eg.Go(m.Handler.Close)
 	eg.Go(m.Server.Close)
 	eg.Go(m.API.Close)
 	if m.gossipMemberSet != nil {
@@ -412,6 +446,9 @@ func (m *Command) Close() error {
 	}
 
 	err := eg.Wait()
+	if err == nil {
+		err = handlerErr
+	}
 	return errors.Wrap(err, "closing everything")
 }
 
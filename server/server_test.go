@@ -22,10 +22,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math/rand"
+	gohttp "net/http"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"testing/quick"
 	"time"
@@ -300,6 +302,113 @@ func TestMain_GroupBy(t *testing.T) {
 	}
 }
 
+// Ensure protobuf and JSON query responses contain the same results.
+func TestMain_QueryProtobufJSON(t *testing.T) {
+	m := test.MustRunCommand()
+	defer m.Close()
+
+	m.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m.MustCreateField(t, "i", "f")
+	m.MustCreateField(t, "i", "v", pilosa.OptFieldTypeInt(0, 1000))
+
+	if _, err := m.Query("i", "", `
+		Set(1, f=10)
+		Set(2, f=10)
+		Set(2, f=11)
+		Set(3, f=11)
+		Set(4, f=11)
+		Set(1, v=100)
+		Set(2, v=200)
+	`); err != nil {
+		t.Fatal(err)
+	}
+	m.MustRecalculateCaches(t)
+
+	for _, query := range []string{
+		`Row(f=10)`,
+		`Count(Row(f=11))`,
+		`TopN(f)`,
+		`Sum(field=v)`,
+		`Overlap(Row(f=10), Row(f=11))`,
+	} {
+		t.Run(query, func(t *testing.T) {
+			jsonBody, err := m.Query("i", "", query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := m.QueryProtobuf("i", query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pbBody, err := json.Marshal(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, exp := string(pbBody), strings.TrimSpace(jsonBody); got != exp {
+				t.Fatalf("protobuf result %s does not match JSON result %s", got, exp)
+			}
+		})
+	}
+}
+
+// Ensure that writes issued while the server is shutting down are either
+// rejected or persisted.
+func TestMain_SetDuringShutdown(t *testing.T) {
+	m := test.MustRunCommand()
+	defer m.Close()
+
+	m.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m.MustCreateField(t, "i", "f")
+
+	url := m.URL() + "/index/i/query"
+	acked := make(chan uint64, 100000)
+	started := make(chan struct{}, 1)
+	var wg sync.WaitGroup
+	for w := uint64(0); w < 8; w++ {
+		wg.Add(1)
+		go func(w uint64) {
+			defer wg.Done()
+			for col := w; ; col += 8 {
+				resp, err := gohttp.Post(url, "application/json", strings.NewReader(fmt.Sprintf(`Set(%d, f=1)`, col)))
+				if err != nil {
+					return
+				}
+				ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode != gohttp.StatusOK {
+					return
+				}
+				acked <- col
+				select {
+				case started <- struct{}{}:
+				default:
+				}
+			}
+		}(w)
+	}
+
+	<-started
+	if err := m.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	close(acked)
+
+	res, err := m.API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Row(f=1)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols := make(map[uint64]struct{})
+	for _, col := range res.Results[0].(*pilosa.Row).Columns() {
+		cols[col] = struct{}{}
+	}
+	for col := range acked {
+		if _, ok := cols[col]; !ok {
+			t.Fatalf("acknowledged set of column %d was lost on shutdown", col)
+		}
+	}
+}
+
 // Ensure the host can be parsed.
 func TestConfig_Parse_Host(t *testing.T) {
 	if c, err := ParseConfig(`bind = "local"`); err != nil {
@@ -116,6 +116,9 @@ func (kpr *keypairReloader) GetClientCertificateFunc() func(*tls.CertificateRequ
 }
 
 func GetTLSConfig(tlsConfig *TLSConfig, logger *log.Logger) (TLSConfig *tls.Config, err error) {
+	if (tlsConfig.CertificatePath == "") != (tlsConfig.CertificateKeyPath == "") {
+		return nil, errors.New("tls certificate and key must be set together")
+	}
 	if tlsConfig.CertificatePath != "" && tlsConfig.CertificateKeyPath != "" {
 		kpr, err := NewKeypairReloader(tlsConfig.CertificatePath, tlsConfig.CertificateKeyPath, logger)
 		if err != nil {
@@ -60,3 +60,44 @@ func TestMonitorAntiEntropyZero(t *testing.T) {
 		t.Fatalf("monitorAntiEntropy should have returned immediately with duration 0")
 	}
 }
+
+func TestServerPoolSizes(t *testing.T) {
+	td, err := ioutil.TempDir(*TempDir, "")
+	if err != nil {
+		t.Fatalf("getting temp dir: %v", err)
+	}
+	numCPU := runtime.NumCPU()
+
+	t.Run("Default", func(t *testing.T) {
+		s, err := NewServer(OptServerDataDir(td))
+		if err != nil {
+			t.Fatalf("making new server: %v", err)
+		} else if s.executorPoolSize != numCPU {
+			t.Fatalf("unexpected executor pool size: %d, expected: %d", s.executorPoolSize, numCPU)
+		} else if s.importPoolSize != numCPU {
+			t.Fatalf("unexpected import pool size: %d, expected: %d", s.importPoolSize, numCPU)
+		}
+	})
+
+	t.Run("Override", func(t *testing.T) {
+		s, err := NewServer(OptServerDataDir(td),
+			OptServerExecutorPoolSize(3),
+			OptServerImportWorkerPoolSize(numCPU*maxPoolSizePerCPU+1))
+		if err != nil {
+			t.Fatalf("making new server: %v", err)
+		} else if s.executorPoolSize != 3 || s.executor.workerPoolSize != 3 {
+			t.Fatalf("unexpected executor pool size: %d/%d", s.executorPoolSize, s.executor.workerPoolSize)
+		} else if s.importPoolSize != numCPU*maxPoolSizePerCPU {
+			t.Fatalf("unexpected import pool size: %d", s.importPoolSize)
+		}
+
+		api, err := NewAPI(OptAPIServer(s))
+		if err != nil {
+			t.Fatalf("making new api: %v", err)
+		}
+		defer api.Close()
+		if info := api.Info().Concurrency; info != (concurrencyInfo{NumCPU: numCPU, ExecutorPoolSize: 3, ImportPoolSize: numCPU * maxPoolSizePerCPU}) {
+			t.Fatalf("unexpected concurrency info: %+v", info)
+		}
+	})
+}
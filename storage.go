@@ -0,0 +1,111 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Storage persists blobs of data by key. It is used for data which is always
+// read and written in full, such as fragment caches, so that it is not tied
+// to the local filesystem. Fragment data itself is memory mapped and is
+// always stored in local files.
+type Storage interface {
+	// Create returns a writer which replaces any data stored at key.
+	Create(key string) (io.WriteCloser, error)
+
+	// Open returns a reader for the data stored at key. If there is no data
+	// stored at key, the returned error satisfies os.IsNotExist.
+	Open(key string) (io.ReadCloser, error)
+
+	// List returns the sorted keys which start with prefix.
+	List(prefix string) ([]string, error)
+
+	// Delete removes the data stored at key.
+	Delete(key string) error
+}
+
+// fileStorage is the default Storage, which uses keys as paths on the local
+// filesystem.
+type fileStorage struct{}
+
+// Create creates or truncates the file at key.
+func (fileStorage) Create(key string) (io.WriteCloser, error) {
+	return os.Create(key)
+}
+
+// Open opens the file at key for reading.
+func (fileStorage) Open(key string) (io.ReadCloser, error) {
+	return os.Open(key)
+}
+
+// List returns the paths of files in the directory of prefix which start
+// with prefix.
+func (fileStorage) List(prefix string) ([]string, error) {
+	dir := filepath.Dir(prefix)
+	fis, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading directory")
+	}
+
+	var keys []string
+	for _, fi := range fis {
+		if fi.IsDir() {
+			continue
+		}
+		if key := filepath.Join(dir, fi.Name()); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Delete removes the file at key.
+func (fileStorage) Delete(key string) error {
+	return os.Remove(key)
+}
+
+// readStorage reads all data stored at key.
+func readStorage(s Storage, key string) ([]byte, error) {
+	r, err := s.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// writeStorage replaces the data stored at key with buf.
+func writeStorage(s Storage, key string, buf []byte) error {
+	w, err := s.Create(key)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buf); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,63 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Ensure the local file storage can create, list, read and delete keys.
+func TestFileStorage(t *testing.T) {
+	dir, err := ioutil.TempDir(*TempDir, "pilosa-storage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var st Storage = fileStorage{}
+	for _, name := range []string{"0.cache", "1.cache", "other"} {
+		if err := writeStorage(st, filepath.Join(dir, name), []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if keys, err := st.List(dir + "/"); err != nil {
+		t.Fatal(err)
+	} else if len(keys) != 3 {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+	if keys, err := st.List(filepath.Join(dir, "1")); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(keys, []string{filepath.Join(dir, "1.cache")}) {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+
+	if buf, err := readStorage(st, filepath.Join(dir, "other")); err != nil {
+		t.Fatal(err)
+	} else if string(buf) != "other" {
+		t.Fatalf("unexpected data: %q", buf)
+	}
+
+	if err := st.Delete(filepath.Join(dir, "other")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readStorage(st, filepath.Join(dir, "other")); !os.IsNotExist(err) {
+		t.Fatalf("expected not exist error, got: %v", err)
+	}
+}
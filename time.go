@@ -40,13 +40,17 @@ func (q TimeQuantum) HasDay() bool { return strings.ContainsRune(string(q), 'D')
 // HasHour returns true if the quantum contains a 'H' unit.
 func (q TimeQuantum) HasHour() bool { return strings.ContainsRune(string(q), 'H') }
 
+// HasMinute returns true if the quantum contains a 'I' unit.
+func (q TimeQuantum) HasMinute() bool { return strings.ContainsRune(string(q), 'I') }
+
 // Valid returns true if q is a valid time quantum value.
 func (q TimeQuantum) Valid() bool {
 	switch q {
-	case "Y", "YM", "YMD", "YMDH",
-		"M", "MD", "MDH",
-		"D", "DH",
-		"H",
+	case "Y", "YM", "YMD", "YMDH", "YMDHI",
+		"M", "MD", "MDH", "MDHI",
+		"D", "DH", "DHI",
+		"H", "HI",
+		"I",
 		"":
 		return true
 	default:
@@ -82,6 +86,8 @@ func viewByTimeUnit(name string, t time.Time, unit rune) string {
 		return fmt.Sprintf("%s_%s", name, t.Format("20060102"))
 	case 'H':
 		return fmt.Sprintf("%s_%s", name, t.Format("2006010215"))
+	case 'I':
+		return fmt.Sprintf("%s_%s", name, t.Format("200601021504"))
 	default:
 		return ""
 	}
@@ -109,12 +115,23 @@ func viewsByTimeRange(name string, start, end time.Time, q TimeQuantum) []string
 	hasMonth := q.HasMonth()
 	hasDay := q.HasDay()
 	hasHour := q.HasHour()
+	hasMinute := q.HasMinute()
 
 	var results []string
 
 	// Walk up from smallest units to largest units.
-	if hasHour || hasDay || hasMonth {
+	if hasMinute || hasHour || hasDay || hasMonth {
 		for t.Before(end) {
+			if hasMinute {
+				if !nextHourGTE(t, end) {
+					break
+				} else if t.Minute() != 0 {
+					results = append(results, viewByTimeUnit(name, t, 'I'))
+					t = t.Add(time.Minute)
+					continue
+				}
+			}
+
 			if hasHour {
 				if !nextDayGTE(t, end) {
 					break
@@ -164,9 +181,12 @@ func viewsByTimeRange(name string, start, end time.Time, q TimeQuantum) []string
 		} else if hasDay && nextDayGTE(t, end) {
 			results = append(results, viewByTimeUnit(name, t, 'D'))
 			t = t.AddDate(0, 0, 1)
-		} else if hasHour {
+		} else if hasHour && (!hasMinute || nextHourGTE(t, end)) {
 			results = append(results, viewByTimeUnit(name, t, 'H'))
 			t = t.Add(time.Hour)
+		} else if hasMinute {
+			results = append(results, viewByTimeUnit(name, t, 'I'))
+			t = t.Add(time.Minute)
 		} else {
 			break
 		}
@@ -188,6 +208,78 @@ func addMonth(t time.Time) time.Time {
 	return t
 }
 
+// truncateTimeUnit returns the start of the time unit (Y, M, D, H or I) which
+// contains t.
+func truncateTimeUnit(t time.Time, unit rune) time.Time {
+	switch unit {
+	case 'Y':
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+	case 'M':
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	case 'D':
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	case 'H':
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case 'I':
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location())
+	}
+	return t
+}
+
+// finestTimeUnit returns the finest time unit (Y, M, D, H or I) of a quantum.
+func finestTimeUnit(q TimeQuantum) (rune, bool) {
+	switch {
+	case q.HasMinute():
+		return 'I', true
+	case q.HasHour():
+		return 'H', true
+	case q.HasDay():
+		return 'D', true
+	case q.HasMonth():
+		return 'M', true
+	case q.HasYear():
+		return 'Y', true
+	}
+	return 0, false
+}
+
+// addTimeUnit returns t advanced by a single time unit (Y, M, D, H or I).
+func addTimeUnit(t time.Time, unit rune) time.Time {
+	switch unit {
+	case 'Y':
+		return t.AddDate(1, 0, 0)
+	case 'M':
+		return addMonth(t)
+	case 'D':
+		return t.AddDate(0, 0, 1)
+	case 'H':
+		return t.Add(time.Hour)
+	case 'I':
+		return t.Add(time.Minute)
+	}
+	return t
+}
+
+// subTimeUnits returns t moved back by n time units (Y, M, D, H or I).
+func subTimeUnits(t time.Time, unit rune, n int) time.Time {
+	switch unit {
+	case 'Y':
+		return t.AddDate(-n, 0, 0)
+	case 'M':
+		if t.Day() > 28 {
+			t = time.Date(t.Year(), t.Month(), 1, t.Hour(), 0, 0, 0, t.Location())
+		}
+		return t.AddDate(0, -n, 0)
+	case 'D':
+		return t.AddDate(0, 0, -n)
+	case 'H':
+		return t.Add(-time.Duration(n) * time.Hour)
+	case 'I':
+		return t.Add(-time.Duration(n) * time.Minute)
+	}
+	return t
+}
+
 func nextYearGTE(t time.Time, end time.Time) bool {
 	next := t.AddDate(1, 0, 0)
 	if next.Year() == end.Year() {
@@ -216,6 +308,16 @@ func nextDayGTE(t time.Time, end time.Time) bool {
 	return end.After(next)
 }
 
+func nextHourGTE(t time.Time, end time.Time) bool {
+	next := t.Add(time.Hour)
+	y1, m1, d1 := next.Date()
+	y2, m2, d2 := end.Date()
+	if (y1 == y2) && (m1 == m2) && (d1 == d2) && (next.Hour() == end.Hour()) {
+		return true
+	}
+	return end.After(next)
+}
+
 // parseTime parses a string or int64 into a time.Time value.
 func parseTime(t interface{}) (time.Time, error) {
 	var err error
@@ -252,6 +354,8 @@ func minMaxViews(views []string, q TimeQuantum) (min string, max string) {
 		chars = 8
 	} else if q.HasHour() {
 		chars = 10
+	} else if q.HasMinute() {
+		chars = 12
 	}
 
 	// min: get the first view with the matching number of time chars.
@@ -281,7 +385,7 @@ func timeOfView(v string, adj bool) (time.Time, error) {
 		return time.Time{}, nil
 	}
 
-	layout := "2006010203"
+	layout := "200601021504"
 	timePart := viewTimePart(v)
 
 	switch len(timePart) {
@@ -321,6 +425,15 @@ func timeOfView(v string, adj bool) (time.Time, error) {
 			t = t.Add(time.Hour)
 		}
 		return t, nil
+	case 12: // minute
+		t, err := time.Parse(layout[:12], timePart)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if adj {
+			t = t.Add(time.Minute)
+		}
+		return t, nil
 	}
 
 	return time.Time{}, fmt.Errorf("invalid time format on view: %s", v)
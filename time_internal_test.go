@@ -62,6 +62,11 @@ func TestViewByTimeUnit(t *testing.T) {
 			t.Fatalf("unexpected name: %s", s)
 		}
 	})
+	t.Run("I", func(t *testing.T) {
+		if s := viewByTimeUnit("F", ts, 'I'); s != "F_200001020304" {
+			t.Fatalf("unexpected name: %s", s)
+		}
+	})
 }
 
 // Ensure all applicable field names can be generated when mutating a time bit.
@@ -75,6 +80,13 @@ func TestViewsByTime(t *testing.T) {
 		}
 	})
 
+	t.Run("YMDHI", func(t *testing.T) {
+		a := viewsByTime("F", ts, mustParseTimeQuantum("YMDHI"))
+		if !reflect.DeepEqual(a, []string{"F_2000", "F_200001", "F_20000102", "F_2000010203", "F_200001020304"}) {
+			t.Fatalf("unexpected names: %+v", a)
+		}
+	})
+
 	t.Run("D", func(t *testing.T) {
 		a := viewsByTime("F", ts, mustParseTimeQuantum("D"))
 		if !reflect.DeepEqual(a, []string{"F_20000102"}) {
@@ -163,6 +175,24 @@ func TestViewsByTimeRange(t *testing.T) {
 			t.Fatalf("unexpected fields: %#v", a)
 		}
 	})
+	t.Run("YMDHI", func(t *testing.T) {
+		a := viewsByTimeRange("F", mustParseTime("2000-12-31 22:58"), mustParseTime("2002-01-01 01:02"), mustParseTimeQuantum("YMDHI"))
+		if !reflect.DeepEqual(a, []string{"F_200012312258", "F_200012312259", "F_2000123123", "F_2001", "F_2002010100", "F_200201010100", "F_200201010101"}) {
+			t.Fatalf("unexpected fields: %#v", a)
+		}
+	})
+	t.Run("HI", func(t *testing.T) {
+		a := viewsByTimeRange("F", mustParseTime("2000-01-01 00:58"), mustParseTime("2000-01-01 03:01"), mustParseTimeQuantum("HI"))
+		if !reflect.DeepEqual(a, []string{"F_200001010058", "F_200001010059", "F_2000010101", "F_2000010102", "F_200001010300"}) {
+			t.Fatalf("unexpected fields: %#v", a)
+		}
+	})
+	t.Run("I", func(t *testing.T) {
+		a := viewsByTimeRange("F", mustParseTime("2000-01-01 00:58"), mustParseTime("2000-01-01 01:01"), mustParseTimeQuantum("I"))
+		if !reflect.DeepEqual(a, []string{"F_200001010058", "F_200001010059", "F_200001010100"}) {
+			t.Fatalf("unexpected fields: %#v", a)
+		}
+	})
 }
 
 func TestMinMaxViews(t *testing.T) {
@@ -259,10 +289,22 @@ func TestTimeOfView(t *testing.T) {
 				"invalid time format on view: foo",
 			},
 			{
-				"std_201902030801",
+				"std_2019020315",
+				time.Date(2019, 2, 3, 15, 0, 0, 0, time.UTC),
+				time.Date(2019, 2, 3, 16, 0, 0, 0, time.UTC),
+				"",
+			},
+			{
+				"std_201902030859",
+				time.Date(2019, 2, 3, 8, 59, 0, 0, time.UTC),
+				time.Date(2019, 2, 3, 9, 0, 0, 0, time.UTC),
+				"",
+			},
+			{
+				"std_20190203080159",
 				time.Time{},
 				time.Time{},
-				"invalid time format on view: std_201902030801",
+				"invalid time format on view: std_20190203080159",
 			},
 		}
 		for i, test := range tests {
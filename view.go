@@ -60,6 +60,7 @@ type view struct {
 	rowAttrStore  AttrStore
 	logger        logger.Logger
 	snapshotQueue chan *fragment
+	backend       Storage
 }
 
 // newView returns a new instance of View.
@@ -310,6 +311,9 @@ func (v *view) newFragment(path string, shard uint64) *fragment {
 	frag.Logger = v.logger
 	frag.stats = v.stats
 	frag.snapshotQueue = v.snapshotQueue
+	if v.backend != nil {
+		frag.backend = v.backend
+	}
 	if v.fieldType == FieldTypeMutex {
 		frag.mutexVector = newRowsVector(frag)
 	} else if v.fieldType == FieldTypeBool {
@@ -340,7 +344,7 @@ func (v *view) deleteFragment(shard uint64) error {
 	}
 
 	// Delete fragment cache file.
-	if err := os.Remove(fragment.cachePath()); err != nil {
+	if err := fragment.backend.Delete(fragment.cachePath()); err != nil {
 		v.logger.Printf("no cache file to delete for shard %d", shard)
 	}
 